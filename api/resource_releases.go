@@ -39,3 +39,45 @@ func (c *Client) GetAppReleases(ctx context.Context, appName string, limit int)
 
 	return data.App.Releases.Nodes, nil
 }
+
+// GetAppReleasesWithConfig is GetAppReleases plus the image and configuration each release deployed
+func (c *Client) GetAppReleasesWithConfig(ctx context.Context, appName string, limit int) ([]Release, error) {
+	query := `
+		query ($appName: String!, $limit: Int!) {
+			app(name: $appName) {
+				releases(first: $limit) {
+					nodes {
+						id
+						version
+						reason
+						description
+						status
+						stable
+						imageRef
+						config {
+							definition
+						}
+						user {
+							id
+							email
+							name
+						}
+						createdAt
+					}
+				}
+			}
+		}
+	`
+
+	req := c.NewRequest(query)
+
+	req.Var("appName", appName)
+	req.Var("limit", limit)
+
+	data, err := c.RunWithContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return data.App.Releases.Nodes, nil
+}
@@ -590,6 +590,8 @@ type Release struct {
 	Description        string
 	Status             string
 	DeploymentStrategy string
+	ImageRef           string
+	Config             *AppConfig
 	User               User
 	CreatedAt          time.Time
 }
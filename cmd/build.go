@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"path/filepath"
+
+	"github.com/sammccord/flyctl/cmdctx"
+	"github.com/sammccord/flyctl/docstrings"
+	"github.com/sammccord/flyctl/internal/build/imgsrc"
+	"github.com/sammccord/flyctl/internal/client"
+	"github.com/spf13/cobra"
+)
+
+func newBuildCommand(client *client.Client) *Command {
+	buildStrings := docstrings.Get("build")
+	cmd := BuildCommandKS(nil, nil, buildStrings, client)
+
+	contextStrings := docstrings.Get("build.context")
+	contextCmd := BuildCommandKS(cmd, runBuildContext, contextStrings, client, workingDirectoryFromArg(0))
+	contextCmd.Command.Args = cobra.MaximumNArgs(1)
+	contextCmd.AddStringFlag(StringFlagOpts{
+		Name:        "dockerfile",
+		Description: "Path to a Dockerfile, its <Dockerfile>.dockerignore is used when present",
+	})
+
+	return cmd
+}
+
+func runBuildContext(cmdCtx *cmdctx.CmdContext) error {
+	dockerfile := cmdCtx.Config.GetString("dockerfile")
+	if dockerfile != "" {
+		var err error
+		if dockerfile, err = filepath.Abs(dockerfile); err != nil {
+			return err
+		}
+	}
+	return printContextReport(cmdCtx, cmdCtx.WorkingDir, dockerfile)
+}
+
+// printContextReport reports on the build context in dir, using the ignore file for dockerfile when it's set
+func printContextReport(cmdCtx *cmdctx.CmdContext, dir, dockerfile string) error {
+	report, err := imgsrc.NewContextReport(dir, dockerfile)
+	if err != nil {
+		return err
+	}
+
+	if cmdCtx.OutputJSON() {
+		cmdCtx.WriteJSON(report)
+		return nil
+	}
+
+	report.Fprint(cmdCtx.Out)
+
+	return nil
+}
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/logrusorgru/aurora"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/sammccord/flyctl/cmdctx"
+	"github.com/sammccord/flyctl/docstrings"
+	"github.com/sammccord/flyctl/flyctl"
+	"github.com/sammccord/flyctl/internal/build/imgsrc/builtins"
+	"github.com/sammccord/flyctl/internal/client"
+	"github.com/spf13/cobra"
+)
+
+func newBuiltinsCommand(client *client.Client) *Command {
+	builtinsStrings := docstrings.Get("builtins")
+	cmd := BuildCommandKS(nil, nil, builtinsStrings, client)
+
+	listStrings := docstrings.Get("builtins.list")
+	BuildCommandKS(cmd, runListBuiltins, listStrings, client, optionalAppName)
+
+	showStrings := docstrings.Get("builtins.show")
+	showCmd := BuildCommandKS(cmd, runShowBuiltin, showStrings, client, optionalAppName)
+	showCmd.Args = cobra.ExactArgs(1)
+
+	showAppStrings := docstrings.Get("builtins.show-app")
+	BuildCommandKS(cmd, runShowAppBuiltin, showAppStrings, client, optionalAppName)
+
+	validateStrings := docstrings.Get("builtins.validate")
+	validateCmd := BuildCommandKS(cmd, runValidateBuiltins, validateStrings, client, optionalAppName)
+	validateCmd.Args = cobra.MaximumNArgs(1)
+
+	return cmd
+}
+
+// loadBuiltins merges the default builtins with those from --builtinsfile and the sources in fly.toml
+func loadBuiltins(ctx context.Context, cmdCtx *cmdctx.CmdContext) (*builtins.Catalog, error) {
+	sources := []string{}
+	if file := cmdCtx.GlobalConfig.GetString(flyctl.ConfigBuiltinsfile); file != "" {
+		file, err := filepath.Abs(file)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, file)
+	}
+	if cmdCtx.AppConfig != nil {
+		sources = append(sources, cmdCtx.AppConfig.BuiltinSources()...)
+	}
+
+	catalog, err := builtins.Load(ctx, filepath.Dir(cmdCtx.ConfigFile), sources...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error loading builtins")
+	}
+	return catalog, nil
+}
+
+func runListBuiltins(cmdCtx *cmdctx.CmdContext) error {
+	catalog, err := loadBuiltins(cmdCtx.Command.Context(), cmdCtx)
+	if err != nil {
+		return err
+	}
+	list := catalog.List()
+
+	if cmdCtx.OutputJSON() {
+		cmdCtx.WriteJSON(list)
+		return nil
+	}
+
+	table := tablewriter.NewWriter(cmdCtx.Out)
+	table.SetAutoWrapText(false)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetNoWhiteSpace(true)
+	table.SetTablePadding(" ")
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetRowSeparator("")
+	table.SetHeader([]string{"Name", "Description", "Source"})
+
+	for _, b := range list {
+		table.Append([]string{b.Name, b.Description, b.Source})
+	}
+
+	table.Render()
+
+	return nil
+}
+
+func runShowBuiltin(cmdCtx *cmdctx.CmdContext) error {
+	catalog, err := loadBuiltins(cmdCtx.Command.Context(), cmdCtx)
+	if err != nil {
+		return err
+	}
+
+	builtin, err := catalog.Get(cmdCtx.Args[0])
+	if err != nil {
+		return err
+	}
+
+	return showBuiltin(cmdCtx, builtin, nil)
+}
+
+func runShowAppBuiltin(cmdCtx *cmdctx.CmdContext) error {
+	if !cmdCtx.AppConfig.HasBuiltin() {
+		return errors.New("the app config doesn't use a builtin")
+	}
+
+	catalog, err := loadBuiltins(cmdCtx.Command.Context(), cmdCtx)
+	if err != nil {
+		return err
+	}
+
+	builtin, err := catalog.Get(cmdCtx.AppConfig.Build.Builtin)
+	if err != nil {
+		return err
+	}
+
+	return showBuiltin(cmdCtx, builtin, cmdCtx.AppConfig.Build.Settings)
+}
+
+func showBuiltin(cmdCtx *cmdctx.CmdContext, builtin *builtins.Builtin, settings map[string]interface{}) error {
+	dockerfile, err := builtin.GetVDockerfile(settings)
+	if err != nil {
+		return errors.Wrapf(err, "error rendering builtin %s", builtin.Name)
+	}
+
+	if cmdCtx.OutputJSON() {
+		cmdCtx.WriteJSON(map[string]interface{}{
+			"Builtin":    builtin,
+			"Settings":   builtin.ResolveSettings(settings),
+			"Dockerfile": dockerfile,
+		})
+		return nil
+	}
+
+	fmt.Fprintf(cmdCtx.Out, "Name: %s\n\n", builtin.Name)
+	fmt.Fprintf(cmdCtx.Out, "Description: %s\n\n", builtin.Description)
+	fmt.Fprintf(cmdCtx.Out, "Source: %s\n\n", builtin.Source)
+	if builtin.Details != "" {
+		fmt.Fprintf(cmdCtx.Out, "Details:\n%s\n\n", builtin.Details)
+	}
+
+	if len(builtin.Settings) > 0 {
+		resolved := builtin.ResolveSettings(settings)
+		fmt.Fprintln(cmdCtx.Out, "Settings:")
+		for _, s := range builtin.Settings {
+			fmt.Fprintf(cmdCtx.Out, "%s=%v (default %v)\n     %s\n", s.Name, resolved[s.Name], s.Default, s.Description)
+		}
+		fmt.Fprintln(cmdCtx.Out)
+	}
+
+	fmt.Fprintf(cmdCtx.Out, "Dockerfile (with settings):\n%s\n", dockerfile)
+
+	return nil
+}
+
+func runValidateBuiltins(cmdCtx *cmdctx.CmdContext) error {
+	ctx := cmdCtx.Command.Context()
+
+	var list []builtins.Builtin
+	if len(cmdCtx.Args) > 0 {
+		loaded, err := builtins.LoadSource(ctx, cmdCtx.WorkingDir, cmdCtx.Args[0])
+		if err != nil {
+			return err
+		}
+		if len(loaded) == 0 {
+			return fmt.Errorf("no builtins found in %s", cmdCtx.Args[0])
+		}
+		list = loaded
+	} else {
+		catalog, err := loadBuiltins(ctx, cmdCtx)
+		if err != nil {
+			return err
+		}
+		list = catalog.List()
+	}
+
+	invalid := 0
+	for _, b := range list {
+		problems := b.Validate()
+		if len(problems) == 0 {
+			fmt.Fprintf(cmdCtx.Out, "%s %s (%s)\n", aurora.Green("✓"), b.Name, b.Source)
+			continue
+		}
+
+		invalid++
+		fmt.Fprintf(cmdCtx.Out, "%s %s (%s)\n", aurora.Red("✗"), b.Name, b.Source)
+		for _, problem := range problems {
+			fmt.Fprintf(cmdCtx.Out, "    %s\n", problem)
+		}
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d builtins are invalid", invalid, len(list))
+	}
+	return nil
+}
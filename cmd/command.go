@@ -147,6 +147,34 @@ func (c *Command) AddStringSliceFlag(options StringSliceFlagOpts) {
 	}
 }
 
+// StringArrayFlagOpts - options a string array flag, values aren't split on commas
+type StringArrayFlagOpts struct {
+	Name        string
+	Shorthand   string
+	Description string
+	Default     []string
+	EnvName     string
+}
+
+// AddStringArrayFlag - add a string array flag to a command
+func (c *Command) AddStringArrayFlag(options StringArrayFlagOpts) {
+	fullName := namespace(c.Command) + "." + options.Name
+
+	if options.Shorthand != "" {
+		c.Flags().StringArrayP(options.Name, options.Shorthand, options.Default, options.Description)
+	} else {
+		c.Flags().StringArray(options.Name, options.Default, options.Description)
+	}
+
+	err := viper.BindPFlag(fullName, c.Flags().Lookup(options.Name))
+	checkErr(err)
+
+	if options.EnvName != "" {
+		err := viper.BindEnv(fullName, options.EnvName)
+		checkErr(err)
+	}
+}
+
 // Initializer - Retains Setup and PreRun functions
 type Initializer struct {
 	Setup  InitializerFn
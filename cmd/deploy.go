@@ -19,12 +19,17 @@ import (
 	"github.com/sammccord/flyctl/cmd/presenters"
 	"github.com/sammccord/flyctl/cmdctx"
 	"github.com/sammccord/flyctl/docstrings"
+	"github.com/sammccord/flyctl/flyctl"
+	"github.com/sammccord/flyctl/internal/build/attest"
+	"github.com/sammccord/flyctl/internal/build/gitsrc"
 	"github.com/sammccord/flyctl/internal/build/imgsrc"
+	"github.com/sammccord/flyctl/internal/build/vulnscan"
 	"github.com/sammccord/flyctl/internal/client"
 	"github.com/sammccord/flyctl/internal/cmdfmt"
 	"github.com/sammccord/flyctl/internal/cmdutil"
 	"github.com/sammccord/flyctl/internal/deployment"
 	"github.com/sammccord/flyctl/internal/flyerr"
+	"github.com/sammccord/flyctl/pkg/agent"
 	"github.com/sammccord/flyctl/pkg/logs"
 	"github.com/sammccord/flyctl/terminal"
 	"github.com/spf13/cobra"
@@ -33,7 +38,9 @@ import (
 
 func newDeployCommand(client *client.Client) *Command {
 	deployStrings := docstrings.Get("deploy")
-	cmd := BuildCommandKS(nil, runDeploy, deployStrings, client, workingDirectoryFromArg(0), requireSession, requireAppName)
+	source := &gitSource{}
+	cmd := BuildCommandKS(nil, runDeploy, deployStrings, client, workingDirectoryFromArg(0), requireSession, source.initializer, requireAppName)
+	source.cleanupOnExit(cmd)
 	cmd.AddStringFlag(StringFlagOpts{
 		Name:        "image",
 		Shorthand:   "i",
@@ -54,6 +61,18 @@ func newDeployCommand(client *client.Client) *Command {
 		Name:        "local-only",
 		Description: "Only perform builds locally using the local docker daemon",
 	})
+	cmd.AddBoolFlag(BoolFlagOpts{
+		Name:        "daemonless",
+		Description: "Build the image without Docker. Only Dockerfiles that copy files onto a base image are supported",
+	})
+	cmd.AddStringFlag(StringFlagOpts{
+		Name:        "image-out",
+		Description: "Write the image to an OCI layout directory, or a tarball when the path ends in .tar. Implies --daemonless",
+	})
+	cmd.AddBoolFlag(BoolFlagOpts{
+		Name:        "context-report",
+		Description: "Report the size and contents of the build context before building",
+	})
 	cmd.AddStringFlag(StringFlagOpts{
 		Name:        "strategy",
 		Description: "The strategy for replacing running instances. Options are canary, rolling, bluegreen, or immediate. Default is canary, or rolling when max-per-region is set.",
@@ -66,6 +85,42 @@ func newDeployCommand(client *client.Client) *Command {
 		Name:        "build-arg",
 		Description: "Set of build time variables in the form of NAME=VALUE pairs. Can be specified multiple times.",
 	})
+	cmd.AddStringSliceFlag(StringSliceFlagOpts{
+		Name:        "build-context",
+		Description: "Additional build contexts in the form of NAME=PATH, available to COPY --from=NAME. Can be specified multiple times.",
+	})
+	cmd.AddStringArrayFlag(StringArrayFlagOpts{
+		Name:        "build-secret",
+		Description: "Secret exposed to RUN --mount=type=secret during the build in the form of id=ID,src=PATH or id=ID,env=VAR. Can be specified multiple times.",
+	})
+	cmd.AddStringSliceFlag(StringSliceFlagOpts{
+		Name:        "platform",
+		Description: "Platforms to build for, like linux/amd64,linux/arm64. Building more than one pushes a manifest list, unless with --build-only. Overrides [build] platforms",
+	})
+	cmd.AddStringArrayFlag(StringArrayFlagOpts{
+		Name:        "cache-from",
+		Description: "Import build cache from type=registry[,ref=IMAGE]. The cache defaults to the app's cache image. Can be specified multiple times.",
+	})
+	cmd.AddStringArrayFlag(StringArrayFlagOpts{
+		Name:        "cache-to",
+		Description: "Export build cache to type=registry[,ref=IMAGE]. Can be specified multiple times.",
+	})
+	cmd.AddStringFlag(StringFlagOpts{
+		Name:        "sbom",
+		Description: "Generate a software bill of materials for the image, in spdx or cyclonedx format, and attach it to the pushed image",
+	})
+	cmd.AddBoolFlag(BoolFlagOpts{
+		Name:        "provenance",
+		Description: "Generate a SLSA provenance statement recording the git commit, builder and build args, and attach it to the pushed image",
+	})
+	cmd.AddStringFlag(StringFlagOpts{
+		Name:        "sbom-out",
+		Description: "Directory to save the SBOM and provenance statement to. Implies --sbom spdx unless --sbom or --provenance is set",
+	})
+	cmd.AddStringArrayFlag(StringArrayFlagOpts{
+		Name:        "ssh",
+		Description: "SSH agent socket or keys exposed to RUN --mount=type=ssh during the build in the form of default|ID[=SOCKET|KEY[,KEY]]. Can be specified multiple times.",
+	})
 	cmd.AddStringSliceFlag(StringSliceFlagOpts{
 		Name:        "env",
 		Shorthand:   "e",
@@ -83,14 +138,165 @@ func newDeployCommand(client *client.Client) *Command {
 		Name:        "no-cache",
 		Description: "Do not use the cache when building the image",
 	})
+	cmd.AddStringFlag(StringFlagOpts{
+		Name:        "scan-db",
+		Description: "Scan the image for vulnerabilities before deploying, using an OSV database snapshot: a directory or .zip of OSV JSON files",
+	})
+	cmd.AddStringFlag(StringFlagOpts{
+		Name:        "scan-severity",
+		Description: "Lowest severity of vulnerabilities that blocks the deployment: low, medium, high or critical",
+		Default:     "high",
+	})
+	cmd.AddStringSliceFlag(StringSliceFlagOpts{
+		Name:        "allow",
+		Description: "Vulnerability ID, like CVE-2021-44228, that doesn't block the deployment. Can be specified multiple times.",
+	})
+	cmd.AddBoolFlag(BoolFlagOpts{
+		Name:        "plan",
+		Description: "Show what the deployment would change and exit without deploying",
+	})
+	cmd.AddStringFlag(StringFlagOpts{
+		Name:        "plan-out",
+		Description: "Write the deployment plan to a file for use with --apply-plan. Implies --plan",
+	})
+	cmd.AddStringFlag(StringFlagOpts{
+		Name:        "apply-plan",
+		Description: "Deploy exactly the image and configuration recorded in a plan file",
+	})
+	cmd.AddBoolFlag(BoolFlagOpts{
+		Name:        "auto-rollback",
+		Description: "Redeploy the previous successful release if the release command or deployment fails",
+	})
+	cmd.AddStringFlag(StringFlagOpts{
+		Name:        "events",
+		Description: "Write deployment events to stdout in a machine readable format. Only ndjson is supported",
+	})
+	cmd.AddStringSliceFlag(StringSliceFlagOpts{
+		Name:        "smoke",
+		Description: "Path to check with an HTTP request after a successful deployment. Can be specified multiple times.",
+	})
+	cmd.AddIntFlag(IntFlagOpts{
+		Name:        "smoke-status",
+		Description: "Expected status code for --smoke checks",
+		Default:     200,
+	})
+	cmd.AddStringFlag(StringFlagOpts{
+		Name:        "smoke-body",
+		Description: "Regular expression the response body of --smoke checks must match",
+	})
+	cmd.AddStringFlag(StringFlagOpts{
+		Name:        "smoke-latency",
+		Description: "Latency budget for --smoke checks, e.g. 500ms",
+	})
+	cmd.AddBoolFlag(BoolFlagOpts{
+		Name:        "smoke-instances",
+		Description: "Run smoke checks against each instance over WireGuard instead of the app hostname",
+	})
+	cmd.AddBoolFlag(BoolFlagOpts{
+		Name:        "smoke-rollback",
+		Description: "Redeploy the previous successful release if smoke checks fail",
+	})
 
 	cmd.Command.Args = cobra.MaximumNArgs(1)
 
 	return cmd
 }
 
+// gitSource checks out the ref given with --git and points the command's working directory at it
+type gitSource struct {
+	checkout *gitsrc.Checkout
+}
+
+func (s *gitSource) initializer(cmd *Command) Initializer {
+	cmd.AddStringFlag(StringFlagOpts{
+		Name:        "git",
+		Description: "Deploy a clean checkout of a git repository, as <url>#<ref>[:<subdir>]. fly.toml is read from the checkout",
+	})
+
+	return Initializer{
+		// runs after requireSession so a missing token doesn't leave a checkout behind
+		PreRun: func(ctx *cmdctx.CmdContext) error {
+			spec := ctx.Config.GetString("git")
+			if spec == "" {
+				return nil
+			}
+			if len(ctx.Args) > 0 {
+				return errors.New("a working directory can't be used with --git, append :<subdir> to the ref instead")
+			}
+
+			src, err := gitsrc.ParseSource(spec)
+			if err != nil {
+				return err
+			}
+
+			cmdfmt.PrintBegin(ctx.Out, fmt.Sprintf("Checking out %s at %s", src.URL, src.Ref))
+			checkout, err := gitsrc.Clone(ctx.Command.Context(), src)
+			if err != nil {
+				return err
+			}
+			s.checkout = checkout
+			cmdfmt.PrintDone(ctx.Out, fmt.Sprintf("Checked out %s", checkout.SHA))
+
+			ctx.WorkingDir = checkout.Dir
+			terminal.Debugf("Working Directory: %s\n", ctx.WorkingDir)
+
+			// also tag the image with the commit, unless a label was given
+			if ctx.Config.GetString("image-label") == "" {
+				if err := ctx.Command.Flags().Set("image-label", "git-"+checkout.ShortSHA()); err != nil {
+					return err
+				}
+			}
+
+			// reload fly.toml and the app name from the checkout
+			return setupAppName(ctx)
+		},
+	}
+}
+
+// cleanupOnExit removes the checkout when the command exits, however far it got, including when a later
+// initializer fails
+func (s *gitSource) cleanupOnExit(cmd *Command) {
+	runE := cmd.RunE
+	cmd.RunE = func(c *cobra.Command, args []string) error {
+		defer s.cleanup()
+		return runE(c, args)
+	}
+}
+
+func (s *gitSource) cleanup() {
+	if s.checkout == nil {
+		return
+	}
+	if err := s.checkout.Remove(); err != nil {
+		terminal.Debugf("error removing git checkout %s: %v\n", s.checkout.Root, err)
+	}
+	s.checkout = nil
+}
+
 func runDeploy(cmdCtx *cmdctx.CmdContext) error {
-	ctx := cmdCtx.Command.Context()
+	ctx, err := withDeploymentEvents(cmdCtx.Command.Context(), cmdCtx)
+	if err != nil {
+		return err
+	}
+
+	staged, err := stagedSecretChanges(cmdCtx.AppName)
+	if err != nil {
+		return err
+	}
+
+	if path := cmdCtx.Config.GetString("apply-plan"); path != "" {
+		if len(staged) > 0 {
+			return errStagedSecretsBlockDeploy(cmdCtx.AppName, staged)
+		}
+		return runApplyDeploymentPlan(ctx, cmdCtx, path)
+	}
+
+	// a release would roll out without the staged secrets and need a second restart once they're applied
+	planOut := cmdCtx.Config.GetString("plan-out")
+	planOnly := cmdCtx.Config.GetBool("plan") || planOut != ""
+	if len(staged) > 0 && !planOnly && !cmdCtx.Config.GetBool("build-only") {
+		return errStagedSecretsBlockDeploy(cmdCtx.AppName, staged)
+	}
 
 	cmdCtx.Status("deploy", cmdctx.STITLE, "Deploying", cmdCtx.AppName)
 
@@ -133,10 +339,21 @@ func runDeploy(cmdCtx *cmdctx.CmdContext) error {
 	}
 
 	daemonType := imgsrc.NewDockerDaemonType(!cmdCtx.Config.GetBool("remote-only"), !cmdCtx.Config.GetBool("local-only"))
+	if cmdCtx.Config.GetBool("daemonless") || cmdCtx.Config.GetString("image-out") != "" {
+		daemonType = imgsrc.DockerDaemonTypeNone
+	}
 	resolver := imgsrc.NewResolver(daemonType, cmdCtx.Client.API(), cmdCtx.AppName, cmdCtx.IO)
 
 	var img *imgsrc.DeploymentImage
 
+	platforms := cmdCtx.Config.GetStringSlice("platform")
+	if len(platforms) == 0 {
+		platforms = cmdCtx.AppConfig.BuildPlatforms()
+	}
+	if platforms, err = imgsrc.ParsePlatforms(platforms); err != nil {
+		return err
+	}
+
 	var imageRef string
 	if ref := cmdCtx.Config.GetString("image"); ref != "" {
 		imageRef = ref
@@ -145,6 +362,10 @@ func runDeploy(cmdCtx *cmdctx.CmdContext) error {
 	}
 
 	if imageRef != "" {
+		if cmdCtx.Config.GetString("sbom") != "" || cmdCtx.Config.GetBool("provenance") || cmdCtx.Config.GetString("sbom-out") != "" {
+			return errors.New("SBOMs and provenance are only generated for images built by deploy, not for --image or [build] image")
+		}
+
 		opts := imgsrc.RefOptions{
 			AppName:    cmdCtx.AppName,
 			WorkingDir: cmdCtx.WorkingDir,
@@ -152,6 +373,7 @@ func runDeploy(cmdCtx *cmdctx.CmdContext) error {
 			Publish:    !cmdCtx.Config.GetBool("build-only"),
 			ImageRef:   imageRef,
 			ImageLabel: cmdCtx.Config.GetString("image-label"),
+			Platforms:  platforms,
 		}
 
 		img, err = resolver.ResolveReference(ctx, cmdCtx.IO, opts)
@@ -166,16 +388,43 @@ func runDeploy(cmdCtx *cmdctx.CmdContext) error {
 			Publish:    !cmdCtx.Config.GetBool("build-only"),
 			ImageLabel: cmdCtx.Config.GetString("image-label"),
 			NoCache:    cmdCtx.Config.GetBool("no-cache"),
+			OutputPath: cmdCtx.Config.GetString("image-out"),
+		}
+
+		// record the commit of --git checkouts whatever the image is tagged with
+		if cmdCtx.Config.GetString("git") != "" {
+			commit, err := gitsrc.Head(ctx, cmdCtx.WorkingDir)
+			if err != nil {
+				return errors.Wrap(err, "error reading the checked out commit")
+			}
+			opts.Labels = map[string]string{"org.opencontainers.image.revision": commit.SHA}
+		}
+
+		// the Dockerfile in fly.toml is relative to the build context when one is set, like in monorepos
+		dockerfileBase := filepath.Dir(cmdCtx.ConfigFile)
+		if buildContext := cmdCtx.AppConfig.BuildContext(); buildContext != "" {
+			opts.WorkingDir = filepath.Join(filepath.Dir(cmdCtx.ConfigFile), buildContext)
+			dockerfileBase = opts.WorkingDir
 		}
 
 		if dockerfilePath := cmdCtx.Config.GetString("dockerfile"); dockerfilePath != "" {
+			// with --git the Dockerfile is looked up in the checkout rather than the current directory
+			if cmdCtx.Config.GetString("git") != "" && !filepath.IsAbs(dockerfilePath) {
+				dockerfilePath = filepath.Join(cmdCtx.WorkingDir, dockerfilePath)
+			}
 			dockerfilePath, err := filepath.Abs(dockerfilePath)
 			if err != nil {
 				return err
 			}
 			opts.DockerfilePath = dockerfilePath
 		} else if dockerfilePath := cmdCtx.AppConfig.Dockerfile(); dockerfilePath != "" {
-			opts.DockerfilePath = filepath.Join(filepath.Dir(cmdCtx.ConfigFile), dockerfilePath)
+			opts.DockerfilePath = filepath.Join(dockerfileBase, dockerfilePath)
+		}
+
+		if cmdCtx.AppConfig.HasBuiltin() {
+			if opts.Builtins, err = loadBuiltins(ctx, cmdCtx); err != nil {
+				return err
+			}
 		}
 
 		if dockerBuildTarget := cmdCtx.Config.GetString("build-target"); dockerBuildTarget != "" {
@@ -190,6 +439,76 @@ func runDeploy(cmdCtx *cmdctx.CmdContext) error {
 		}
 		opts.ExtraBuildArgs = extraArgs
 
+		buildContexts, err := cmdutil.ParseKVStringsToMap(cmdCtx.Config.GetStringSlice("build-context"))
+		if err != nil {
+			return errors.Wrap(err, "invalid build-context")
+		}
+		for name, dir := range buildContexts {
+			if buildContexts[name], err = filepath.Abs(dir); err != nil {
+				return err
+			}
+		}
+		opts.BuildContexts = buildContexts
+
+		for _, spec := range cmdCtx.Config.GetStringSlice("build-secret") {
+			secret, err := imgsrc.ParseBuildSecret(spec)
+			if err != nil {
+				return err
+			}
+			opts.BuildSecrets = append(opts.BuildSecrets, secret)
+		}
+
+		for _, spec := range cmdCtx.Config.GetStringSlice("ssh") {
+			forward, err := imgsrc.ParseBuildSSH(spec)
+			if err != nil {
+				return err
+			}
+			opts.SSH = append(opts.SSH, forward)
+		}
+
+		opts.Platforms = platforms
+		if err := imgsrc.CheckDeployPlatforms(opts.Platforms); err != nil && !cmdCtx.Config.GetBool("build-only") {
+			return errors.Wrap(err, "use --build-only to build images that can't be deployed")
+		}
+
+		for _, spec := range cmdCtx.Config.GetStringSlice("cache-from") {
+			cache, err := imgsrc.ParseBuildCache(spec)
+			if err != nil {
+				return errors.Wrap(err, "invalid cache-from")
+			}
+			opts.CacheFrom = append(opts.CacheFrom, cache)
+		}
+
+		for _, spec := range cmdCtx.Config.GetStringSlice("cache-to") {
+			cache, err := imgsrc.ParseBuildCache(spec)
+			if err != nil {
+				return errors.Wrap(err, "invalid cache-to")
+			}
+			opts.CacheTo = append(opts.CacheTo, cache)
+		}
+
+		if format := cmdCtx.Config.GetString("sbom"); format != "" {
+			if opts.SBOM, err = attest.ParseFormat(format); err != nil {
+				return err
+			}
+		}
+		opts.Provenance = cmdCtx.Config.GetBool("provenance")
+		if out := cmdCtx.Config.GetString("sbom-out"); out != "" {
+			if opts.SBOMOut, err = filepath.Abs(out); err != nil {
+				return err
+			}
+			if opts.SBOM == "" && !opts.Provenance {
+				opts.SBOM = attest.FormatSPDX
+			}
+		}
+
+		if cmdCtx.Config.GetBool("context-report") {
+			if err := printContextReport(cmdCtx, opts.WorkingDir, opts.DockerfilePath); err != nil {
+				return errors.Wrap(err, "error reporting on the build context")
+			}
+			fmt.Fprintln(cmdCtx.Out)
+		}
+
 		img, err = resolver.BuildImage(ctx, cmdCtx.IO, opts)
 		if err != nil {
 			return err
@@ -206,12 +525,16 @@ func runDeploy(cmdCtx *cmdctx.CmdContext) error {
 	fmt.Fprintf(cmdCtx.Client.IO.Out, "Image: %s\n", img.Tag)
 	fmt.Fprintf(cmdCtx.Client.IO.Out, "Image size: %s\n", humanize.Bytes(uint64(img.Size)))
 
+	if path := cmdCtx.Config.GetString("scan-db"); path != "" {
+		if err := scanDeploymentImage(ctx, cmdCtx, resolver, img, path); err != nil {
+			return err
+		}
+	}
+
 	if cmdCtx.Config.GetBool("build-only") {
 		return nil
 	}
 
-	cmdfmt.PrintBegin(cmdCtx.Out, "Creating release")
-
 	input := api.DeployImageInput{
 		AppID: cmdCtx.AppName,
 		Image: img.Tag,
@@ -223,39 +546,416 @@ func runDeploy(cmdCtx *cmdctx.CmdContext) error {
 		input.Definition = api.DefinitionPtr(cmdCtx.AppConfig.Definition)
 	}
 
-	release, releaseCommand, err := cmdCtx.Client.API().DeployImage(ctx, input)
+	if planOnly {
+		plan, err := deployment.NewPlan(ctx, cmdCtx.Client.API(), deployment.PlanInput{
+			AppName:       cmdCtx.AppName,
+			Image:         img.Tag,
+			ImageID:       img.ID,
+			Definition:    cmdCtx.AppConfig.Definition,
+			Strategy:      cmdCtx.Config.GetString("strategy"),
+			StagedSecrets: staged,
+		})
+		if err != nil {
+			return err
+		}
+
+		return renderDeploymentPlan(cmdCtx, plan, planOut)
+	}
+
+	return createRelease(ctx, cmdCtx, input)
+}
+
+// scanDeploymentImage matches the packages in img against the vulnerability database at dbPath and fails
+// when any at or above --scan-severity weren't allowed
+func scanDeploymentImage(ctx context.Context, cmdCtx *cmdctx.CmdContext, resolver *imgsrc.Resolver, img *imgsrc.DeploymentImage, dbPath string) error {
+	threshold, err := vulnscan.ParseSeverity(cmdCtx.Config.GetString("scan-severity"))
+	if err != nil {
+		return errors.Wrap(err, "invalid scan-severity")
+	}
+
+	cmdfmt.PrintBegin(cmdCtx.Out, "Scanning image for vulnerabilities")
+
+	db, err := vulnscan.LoadDB(dbPath)
+	if err != nil {
+		return errors.Wrap(err, "error loading vulnerability database")
+	}
+
+	inv, err := resolver.Inventory(ctx, img, !cmdCtx.Config.GetBool("build-only"), cmdCtx.Config.GetString("image-out"))
+	if err != nil {
+		return errors.Wrap(err, "error reading packages from image")
+	}
+
+	report := vulnscan.Scan(db, inv, vulnscan.Policy{
+		Threshold: threshold,
+		Allow:     cmdCtx.Config.GetStringSlice("allow"),
+	})
+
+	if cmdCtx.OutputJSON() {
+		cmdCtx.WriteJSON(report)
+	} else {
+		report.Fprint(cmdCtx.Out)
+	}
+
+	if blocking := report.Blocking(); len(blocking) > 0 {
+		return fmt.Errorf("found %d vulnerabilities at or above %s severity, fix them or accept them with --allow", len(blocking), threshold)
+	}
+
+	cmdfmt.PrintDone(cmdCtx.Out, fmt.Sprintf("Scanning image done, checked %d packages against %d vulnerabilities", report.Packages, db.Entries))
+
+	return nil
+}
+
+func renderDeploymentPlan(cmdCtx *cmdctx.CmdContext, plan *deployment.Plan, planOut string) error {
+	if cmdCtx.OutputJSON() {
+		cmdCtx.WriteJSON(plan)
+	} else {
+		fmt.Fprintln(cmdCtx.Out)
+		plan.Fprint(cmdCtx.Out)
+	}
+
+	if planOut == "" {
+		return nil
+	}
+
+	if err := deployment.WritePlanFile(planOut, plan); err != nil {
+		return errors.Wrap(err, "error writing plan file")
+	}
+	// keep JSON output parseable
+	out := cmdCtx.Out
+	if cmdCtx.OutputJSON() {
+		out = cmdCtx.IO.ErrOut
+	}
+	fmt.Fprintf(out, "\nPlan written to %s. Deploy it with `flyctl deploy --apply-plan %s`\n", planOut, planOut)
+
+	return nil
+}
+
+func runApplyDeploymentPlan(ctx context.Context, cmdCtx *cmdctx.CmdContext, path string) error {
+	plan, err := deployment.ReadPlanFile(path)
 	if err != nil {
 		return err
 	}
 
+	if plan.AppName != cmdCtx.AppName {
+		return fmt.Errorf("plan %s was made for %s, not %s", path, plan.AppName, cmdCtx.AppName)
+	}
+
+	cmdCtx.Status("deploy", cmdctx.STITLE, "Applying deployment plan for", cmdCtx.AppName)
+
+	if err := plan.Verify(ctx, cmdCtx.Client.API()); err != nil {
+		return err
+	}
+
+	plan.Fprint(cmdCtx.Out)
+	fmt.Fprintln(cmdCtx.Out)
+
+	// deploy the reviewed image by digest, the tag could be pushed again before the deployment starts
+	image, err := imgsrc.PinImage(ctx, plan.Image.Tag, plan.Image.ID)
+	if err != nil {
+		return errors.Wrap(err, "plan image changed")
+	}
+
+	input := api.DeployImageInput{
+		AppID: cmdCtx.AppName,
+		Image: image,
+	}
+	if plan.Strategy != deployment.DefaultStrategy {
+		input.Strategy = api.StringPointer(strings.ToUpper(plan.Strategy))
+	}
+	if len(plan.Definition) > 0 {
+		input.Definition = &plan.Definition
+	}
+
+	return createRelease(ctx, cmdCtx, input)
+}
+
+func createRelease(ctx context.Context, cmdCtx *cmdctx.CmdContext, input api.DeployImageInput) error {
+	smokeTests, err := smokeTestsFromConfig(cmdCtx)
+	if err != nil {
+		return err
+	}
+
+	autoRollback := cmdCtx.Config.GetBool("auto-rollback")
+	smokeRollback := smokeTests != nil && smokeTests.Rollback
+
+	detach := cmdCtx.Config.GetBool("detach")
+	if detach && autoRollback {
+		return errors.New("--auto-rollback requires monitoring the deployment and can't be used with --detach")
+	}
+
+	var previous *api.Release
+	if !detach && (autoRollback || smokeRollback) {
+		previous, err = lastStableRelease(ctx, cmdCtx)
+		if err != nil {
+			return err
+		}
+		if previous == nil {
+			terminal.Warn("No previous successful release found, automatic rollback is disabled for this deployment")
+		}
+	}
+
+	if detach {
+		if smokeTests != nil {
+			terminal.Warn("Smoke tests are skipped for detached deployments")
+		}
+		_, err := deployRelease(ctx, cmdCtx, input)
+		return err
+	}
+
+	release, err := deployRelease(ctx, cmdCtx, input)
+	rollback := autoRollback
+	if err == nil && smokeTests != nil {
+		err = runSmokeTests(ctx, cmdCtx, smokeTests)
+		rollback = smokeRollback
+	}
+
+	if err == nil || !rollback || release == nil || previous == nil || errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	return rollbackRelease(ctx, cmdCtx, release, previous, err)
+}
+
+// smokeTestsFromConfig merges [deploy.smoke_tests] from fly.toml with the --smoke flags
+func smokeTestsFromConfig(cmdCtx *cmdctx.CmdContext) (*flyctl.SmokeTests, error) {
+	tests := flyctl.SmokeTests{}
+	if cmdCtx.AppConfig != nil && cmdCtx.AppConfig.SmokeTests != nil {
+		tests = *cmdCtx.AppConfig.SmokeTests
+		tests.Checks = append([]flyctl.SmokeCheck{}, tests.Checks...)
+	}
+
+	for _, path := range cmdCtx.Config.GetStringSlice("smoke") {
+		check := flyctl.SmokeCheck{
+			Path:   path,
+			Status: cmdCtx.Config.GetInt("smoke-status"),
+			Body:   cmdCtx.Config.GetString("smoke-body"),
+		}
+		if latency := cmdCtx.Config.GetString("smoke-latency"); latency != "" {
+			d, err := time.ParseDuration(latency)
+			if err != nil {
+				return nil, errors.Wrap(err, "invalid smoke-latency")
+			}
+			check.MaxLatency = d
+		}
+		tests.Checks = append(tests.Checks, check)
+	}
+
+	if cmdCtx.Config.GetBool("smoke-instances") {
+		tests.Instances = true
+	}
+	if cmdCtx.Config.GetBool("smoke-rollback") {
+		tests.Rollback = true
+	}
+
+	if len(tests.Checks) == 0 {
+		return nil, nil
+	}
+
+	if err := deployment.ValidateSmokeTests(&tests); err != nil {
+		return nil, err
+	}
+
+	return &tests, nil
+}
+
+func runSmokeTests(ctx context.Context, cmdCtx *cmdctx.CmdContext, tests *flyctl.SmokeTests) error {
+	cmdCtx.Status("deploy", cmdctx.STITLE, "Running smoke tests")
+
+	apiClient := cmdCtx.Client.API()
+
+	app, err := apiClient.GetApp(ctx, cmdCtx.AppName)
+	if err != nil {
+		return fmt.Errorf("get app: %w", err)
+	}
+
+	targets := []deployment.SmokeTarget{{Name: app.Hostname, Address: app.Hostname}}
+	var dial deployment.DialFunc
+
+	if tests.Instances {
+		status, err := apiClient.GetAppStatus(ctx, cmdCtx.AppName, false)
+		if err != nil {
+			return errors.Wrap(err, "error fetching instances")
+		}
+
+		agentclient, err := agent.Establish(ctx, apiClient)
+		if err != nil {
+			return errors.Wrap(err, "can't establish agent")
+		}
+
+		dialer, err := agentclient.Dialer(ctx, &app.Organization)
+		if err != nil {
+			return fmt.Errorf("can't build tunnel for %s: %s", app.Organization.Slug, err)
+		}
+
+		if err := agentclient.WaitForTunnel(ctx, &app.Organization); err != nil {
+			return errors.Wrap(err, "tunnel unavailable")
+		}
+		dial = dialer.DialContext
+
+		port := 8080
+		if cmdCtx.AppConfig != nil {
+			port = deployment.InternalPort(cmdCtx.AppConfig.Definition)
+		}
+
+		targets = nil
+		for _, alloc := range status.Allocations {
+			if alloc.PrivateIP == "" {
+				continue
+			}
+			targets = append(targets, deployment.SmokeTarget{
+				Name:     alloc.IDShort,
+				Region:   alloc.Region,
+				Address:  alloc.PrivateIP,
+				Port:     port,
+				Hostname: app.Hostname,
+				Instance: true,
+			})
+		}
+
+		if len(targets) == 0 {
+			return errors.New("no running instances to smoke test")
+		}
+	}
+
+	results := deployment.RunSmokeTests(ctx, tests, targets, dial)
+
+	failed := 0
+	for _, r := range results {
+		where := r.Target.Name
+		if r.Target.Region != "" {
+			where = fmt.Sprintf("%s (%s)", where, r.Target.Region)
+		}
+		if r.Allocation == "" {
+			where += " via the proxy"
+		}
+
+		if r.Passed() {
+			fmt.Fprintf(cmdCtx.Out, "  %s %s on %s: %d in %s\n", aurora.Green("✔"), r.Check.Path, where, r.Status, r.Latency.Round(time.Millisecond))
+			continue
+		}
+
+		failed++
+		fmt.Fprintf(cmdCtx.Out, "  %s %s on %s: %s\n", aurora.Red("✘"), r.Check.Path, where, r.Err)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d smoke checks failed", failed, len(results))
+	}
+
+	cmdCtx.Statusf("deploy", cmdctx.SDONE, "All %d smoke checks passed\n", len(results))
+
+	return nil
+}
+
+// lastStableRelease finds the most recent stable release that can be redeployed
+func lastStableRelease(ctx context.Context, cmdCtx *cmdctx.CmdContext) (*api.Release, error) {
+	releases, err := cmdCtx.Client.API().GetAppReleasesWithConfig(ctx, cmdCtx.AppName, 25)
+	if err != nil {
+		return nil, errors.Wrap(err, "error fetching releases")
+	}
+
+	for i := range releases {
+		if releases[i].Stable && releases[i].ImageRef != "" {
+			return &releases[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+func rollbackRelease(ctx context.Context, cmdCtx *cmdctx.CmdContext, failed *api.Release, previous *api.Release, deployErr error) error {
+	cmdCtx.StatusLn()
+	cmdCtx.Statusf("deploy", cmdctx.STITLE, "Deployment of v%d failed, rolling back to v%d (%s)\n", failed.Version, previous.Version, previous.ImageRef)
+
+	input := api.DeployImageInput{
+		AppID: cmdCtx.AppName,
+		Image: previous.ImageRef,
+	}
+	if previous.Config != nil && len(previous.Config.Definition) > 0 {
+		input.Definition = &previous.Config.Definition
+	}
+
+	rollback, err := deployRelease(ctx, cmdCtx, input)
+
+	cmdCtx.StatusLn()
+	cmdCtx.Statusf("deploy", cmdctx.SERROR, "v%d failed: %s\n", failed.Version, describeDeployError(deployErr))
+
+	if err != nil {
+		if rollback != nil {
+			cmdCtx.Statusf("deploy", cmdctx.SERROR, "Rollback to v%d (released as v%d) failed: %s\n", previous.Version, rollback.Version, describeDeployError(err))
+		}
+		return errors.Wrapf(err, "rollback to v%d failed", previous.Version)
+	}
+
+	cmdCtx.Statusf("deploy", cmdctx.SDONE, "Rolled back to v%d, released as v%d\n", previous.Version, rollback.Version)
+
+	return flyerr.ErrAbort
+}
+
+func describeDeployError(err error) string {
+	if errors.Is(err, flyerr.ErrAbort) {
+		return "deployment did not become healthy"
+	}
+	return err.Error()
+}
+
+// deployRelease creates a release and watches its release command and deployment. The
+// release is returned whenever it was created, even if watching it failed.
+func deployRelease(ctx context.Context, cmdCtx *cmdctx.CmdContext, input api.DeployImageInput) (*api.Release, error) {
+	cmdfmt.PrintBegin(cmdCtx.Out, "Creating release")
+
+	release, releaseCommand, err := cmdCtx.Client.API().DeployImage(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
 	fmt.Fprintf(cmdCtx.Out, "Release v%d created\n", release.Version)
 	if releaseCommand != nil {
 		fmt.Fprintf(cmdCtx.Out, "Release command detected: this new release will not be available until the command succeeds.\n")
 	}
 
 	if cmdCtx.Config.GetBool("detach") {
-		return nil
+		return release, nil
 	}
 
-	fmt.Println()
+	fmt.Fprintln(cmdCtx.Out)
 	cmdCtx.Status("deploy", cmdctx.SDETAIL, "You can detach the terminal anytime without stopping the deployment")
 
 	if releaseCommand != nil {
 		cmdfmt.PrintBegin(cmdCtx.Out, "Release command")
-		fmt.Printf("Command: %s\n", releaseCommand.Command)
+		fmt.Fprintf(cmdCtx.Out, "Command: %s\n", releaseCommand.Command)
 
 		err = watchReleaseCommand(ctx, cmdCtx, cmdCtx.Client.API(), releaseCommand.ID)
 		if err != nil {
-			return err
+			return release, err
 		}
 	}
 
 	if release.DeploymentStrategy == "IMMEDIATE" {
 		terminal.Debug("immediate deployment strategy, nothing to monitor")
-		return nil
+		return release, nil
 	}
 
-	return watchDeployment(ctx, cmdCtx)
+	return release, watchDeployment(ctx, cmdCtx)
+}
+
+// withDeploymentEvents sets up the --events stream. Human readable output moves to
+// stderr so stdout only carries events.
+func withDeploymentEvents(ctx context.Context, cmdCtx *cmdctx.CmdContext) (context.Context, error) {
+	format := cmdCtx.Config.GetString("events")
+	if format == "" {
+		return ctx, nil
+	}
+
+	emitter, err := deployment.NewEventEmitter(cmdCtx.IO.Out, format, cmdCtx.AppName)
+	if err != nil {
+		return nil, err
+	}
+
+	cmdCtx.IO.Out = cmdCtx.IO.ErrOut
+	cmdCtx.Out = cmdCtx.IO.ErrOut
+
+	return deployment.NewContext(ctx, emitter), nil
 }
 
 func watchReleaseCommand(ctx context.Context, cc *cmdctx.CmdContext, apiClient *api.Client, id string) error {
@@ -294,7 +994,7 @@ func watchReleaseCommand(ctx context.Context, cc *cmdctx.CmdContext, apiClient *
 							defer s.Start()
 						}
 
-						fmt.Println("\t", entry.Message)
+						fmt.Fprintln(cc.Out, "\t", entry.Message)
 
 						// watch for the shutdown message
 						if entry.Message == "Starting clean up." {
@@ -488,6 +1188,10 @@ func watchDeployment(ctx context.Context, cmdCtx *cmdctx.CmdContext) error {
 		return nil
 	}
 
+	if emitter := deployment.EmitterFromContext(ctx); emitter != nil {
+		emitter.Attach(monitor)
+	}
+
 	monitor.Start(ctx)
 
 	if err := monitor.Error(); err != nil {
@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -15,12 +16,12 @@ func isInterrupt(err error) bool {
 	return err != nil && err.Error() == "interrupt"
 }
 
-func confirm(message string) bool {
+func confirm(message string, opts ...survey.AskOpt) bool {
 	confirm := false
 	prompt := &survey.Confirm{
 		Message: message,
 	}
-	err := survey.AskOne(prompt, &confirm)
+	err := survey.AskOne(prompt, &confirm, opts...)
 	checkErr(err)
 
 	return confirm
@@ -37,7 +38,7 @@ func confirmOverwrite(filename string) bool {
 	return confirm
 }
 
-func selectOrganization(ctx context.Context, client *api.Client, slug string, typeFilter *api.OrganizationType) (*api.Organization, error) {
+func selectOrganization(ctx context.Context, client *api.Client, slug string, typeFilter *api.OrganizationType, opts ...survey.AskOpt) (*api.Organization, error) {
 	orgs, err := client.GetOrganizations(ctx, typeFilter)
 	if err != nil {
 		return nil, err
@@ -54,7 +55,7 @@ func selectOrganization(ctx context.Context, client *api.Client, slug string, ty
 	}
 
 	if len(orgs) == 1 && orgs[0].Type == "PERSONAL" {
-		fmt.Printf("Automatically selected %s organization: %s\n", strings.ToLower(orgs[0].Type), orgs[0].Name)
+		fmt.Fprintf(os.Stderr, "Automatically selected %s organization: %s\n", strings.ToLower(orgs[0].Type), orgs[0].Name)
 		return &orgs[0], nil
 	}
 
@@ -72,7 +73,7 @@ func selectOrganization(ctx context.Context, client *api.Client, slug string, ty
 		Options:  options,
 		PageSize: 15,
 	}
-	if err := survey.AskOne(prompt, &selectedOrg); err != nil {
+	if err := survey.AskOne(prompt, &selectedOrg, opts...); err != nil {
 		return nil, err
 	}
 
@@ -107,7 +108,7 @@ func selectWireGuardPeer(ctx context.Context, client *api.Client, slug string) (
 	return peers[selectedPeer].Name, nil
 }
 
-func selectRegion(ctx context.Context, client *api.Client, regionCode string) (*api.Region, error) {
+func selectRegion(ctx context.Context, client *api.Client, regionCode string, opts ...survey.AskOpt) (*api.Region, error) {
 	regions, requestRegion, err := client.PlatformRegions(ctx)
 	if err != nil {
 		return nil, err
@@ -140,7 +141,7 @@ func selectRegion(ctx context.Context, client *api.Client, regionCode string) (*
 		prompt.Default = fmt.Sprintf("%s (%s)", requestRegion.Code, requestRegion.Name)
 	}
 
-	if err := survey.AskOne(prompt, &selectedRegion); err != nil {
+	if err := survey.AskOne(prompt, &selectedRegion, opts...); err != nil {
 		return nil, err
 	}
 
@@ -182,7 +183,7 @@ func selectVMSize(ctx context.Context, client *api.Client, vmSizeName string) (*
 	return &vmSizes[selectedVMSize], nil
 }
 
-func inputAppName(defaultName string, autoGenerate bool) (name string, err error) {
+func inputAppName(defaultName string, autoGenerate bool, opts ...survey.AskOpt) (name string, err error) {
 	message := "App Name"
 
 	if autoGenerate {
@@ -196,7 +197,7 @@ func inputAppName(defaultName string, autoGenerate bool) (name string, err error
 		Default: defaultName,
 	}
 
-	if err := survey.AskOne(prompt, &name); err != nil {
+	if err := survey.AskOne(prompt, &name, opts...); err != nil {
 		return name, err
 	}
 
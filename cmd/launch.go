@@ -3,13 +3,13 @@ package cmd
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
 
-	"github.com/AlecAivazis/survey/v2"
 	"github.com/logrusorgru/aurora"
 	"github.com/spf13/cobra"
 
@@ -77,11 +77,54 @@ func newLaunchCommand(client *client.Client) *Command {
 		Description: "Perform builds remotely without using the local docker daemon",
 		Default:     true,
 	})
+	launchCmd.AddStringSliceFlag(StringSliceFlagOpts{
+		Name:        "apps",
+		Description: "Directories of a monorepo to launch as separate apps without prompting",
+	})
+	launchCmd.AddStringFlag(StringFlagOpts{
+		Name:        "from",
+		Description: "Take every decision from a YAML or JSON answers file instead of prompting",
+	})
+	launchCmd.AddStringFlag(StringFlagOpts{
+		Name:        "json-answers",
+		Description: "Take every decision from answers given as JSON, or read from stdin with -",
+	})
+	launchCmd.AddBoolFlag(BoolFlagOpts{
+		Name:        "scanner-debug",
+		Description: "Show why each source code scanner did or didn't match",
+		Default:     false,
+	})
 
 	return launchCmd
 }
 
 func runLaunch(cmdCtx *cmdctx.CmdContext) error {
+	p, err := newLaunchPrompter(cmdCtx)
+	if err != nil {
+		return err
+	}
+
+	summary := &launchSummary{Apps: []*launchedAppSummary{}}
+	if !p.fromAnswers && !cmdCtx.OutputJSON() {
+		return launch(cmdCtx, p, summary)
+	}
+
+	// Keep stdout for the summary, everything else launch and deploy print goes to stderr
+	err = func() error {
+		out, streamsOut := cmdCtx.Out, cmdCtx.IO.Out
+		cmdCtx.Out, cmdCtx.IO.Out = cmdCtx.IO.ErrOut, cmdCtx.IO.ErrOut
+		defer func() {
+			cmdCtx.Out, cmdCtx.IO.Out = out, streamsOut
+		}()
+
+		return launch(cmdCtx, p, summary)
+	}()
+	cmdCtx.WriteJSON(summary)
+
+	return err
+}
+
+func launch(cmdCtx *cmdctx.CmdContext, p *launchPrompter, summary *launchSummary) error {
 	ctx := cmdCtx.Command.Context()
 
 	dir := cmdCtx.Config.GetString("path")
@@ -91,7 +134,7 @@ func runLaunch(cmdCtx *cmdctx.CmdContext) error {
 	}
 	cmdCtx.WorkingDir = dir
 
-	orgSlug := cmdCtx.Config.GetString("org")
+	orgSlug := p.answers.Org
 
 	// start a remote builder for the personal org if necessary
 	eagerBuilderOrg := orgSlug
@@ -104,7 +147,22 @@ func runLaunch(cmdCtx *cmdctx.CmdContext) error {
 
 	var importedConfig bool
 	configFilePath := filepath.Join(dir, "fly.toml")
-	if exists, _ := flyctl.ConfigFileExistsAtPath(configFilePath); exists {
+	configExists, _ := flyctl.ConfigFileExistsAtPath(configFilePath)
+
+	// Offer to launch each app of a monorepo with its own fly.toml
+	if !configExists && p.answers.Image == "" && p.answers.Dockerfile == "" {
+		if workspaceApps := sourcecode.DetectWorkspace(dir); len(workspaceApps) > 0 {
+			selected, err := selectWorkspaceApps(cmdCtx.Out, p, workspaceApps)
+			if err != nil {
+				return err
+			}
+			if len(selected) > 0 {
+				return launchWorkspace(cmdCtx, p, summary, dir, selected)
+			}
+		}
+	}
+
+	if configExists {
 		cfg, err := flyctl.LoadAppConfig(configFilePath)
 		if err != nil {
 			return err
@@ -113,51 +171,67 @@ func runLaunch(cmdCtx *cmdctx.CmdContext) error {
 		var deployExisting bool
 
 		if cfg.AppName != "" {
-			fmt.Println("An existing fly.toml file was found for app", cfg.AppName)
+			fmt.Fprintln(cmdCtx.Out, "An existing fly.toml file was found for app", cfg.AppName)
 			deployExisting, err = shouldDeployExistingApp(cmdCtx, cfg.AppName)
 			if err != nil {
 				return err
 			}
 		} else {
-			fmt.Println("An existing fly.toml file was found")
+			fmt.Fprintln(cmdCtx.Out, "An existing fly.toml file was found")
 		}
 
 		if deployExisting {
-			fmt.Println("App is not running, deploy...")
+			fmt.Fprintln(cmdCtx.Out, "App is not running, deploy...")
 			cmdCtx.AppName = cfg.AppName
 			cmdCtx.AppConfig = cfg
-			return runDeploy(cmdCtx)
-		} else if cmdCtx.Config.GetBool("copy-config") || confirm("Would you like to copy its configuration to the new app?") {
+			if err := runDeploy(cmdCtx); err != nil {
+				return err
+			}
+			summary.add(&launchedAppSummary{Name: cfg.AppName, ConfigFile: configFilePath, Deployed: true})
+			return nil
+		}
+
+		copyConfig := p.answers.CopyConfig
+		if !copyConfig {
+			if copyConfig, err = p.confirm("Would you like to copy its configuration to the new app?", false); err != nil {
+				return err
+			}
+		}
+		if copyConfig {
 			appConfig.Definition = cfg.Definition
 			importedConfig = true
 		}
 	}
 
-	fmt.Println("Creating app in", dir)
+	fmt.Fprintln(cmdCtx.Out, "Creating app in", dir)
 
 	var srcInfo = new(sourcecode.SourceInfo)
 
-	if img := cmdCtx.Config.GetString("image"); img != "" {
-		fmt.Println("Using image", img)
+	if img := p.answers.Image; img != "" {
+		fmt.Fprintln(cmdCtx.Out, "Using image", img)
 		appConfig.Build = &flyctl.Build{
 			Image: img,
 		}
-	} else if dockerfile := cmdCtx.Config.GetString("dockerfile"); dockerfile != "" {
-		fmt.Println("Using dockefile", dockerfile)
+	} else if dockerfile := p.answers.Dockerfile; dockerfile != "" {
+		fmt.Fprintln(cmdCtx.Out, "Using dockefile", dockerfile)
 		appConfig.Build = &flyctl.Build{
 			Dockerfile: dockerfile,
 		}
 	} else {
-		fmt.Println("Scanning source code")
+		fmt.Fprintln(cmdCtx.Out, "Scanning source code")
 
-		if si, err := sourcecode.Scan(dir); err != nil {
+		si, traces, err := sourcecode.ScanWithTrace(dir, filepath.Join(flyctl.ConfigDir(), "scanners"))
+		if err != nil {
 			return err
-		} else {
-			srcInfo = si
+		}
+		srcInfo = si
+
+		if cmdCtx.Config.GetBool("scanner-debug") {
+			printScannerTraces(cmdCtx.Out, traces)
 		}
 
 		if srcInfo == nil {
-			fmt.Println(aurora.Green("Could not find a Dockerfile, nor detect a runtime or framework from source code. Continuing with a blank app."))
+			fmt.Fprintln(cmdCtx.Out, aurora.Green("Could not find a Dockerfile, nor detect a runtime or framework from source code. Continuing with a blank app."))
 		} else {
 
 			var article string = "a"
@@ -173,13 +247,13 @@ func runLaunch(cmdCtx *cmdctx.CmdContext) error {
 				appType = appType + " " + srcInfo.Version
 			}
 
-			fmt.Printf("Detected %s %s app\n", article, aurora.Green(appType))
+			fmt.Fprintf(cmdCtx.Out, "Detected %s %s app\n", article, aurora.Green(appType))
 
 			if srcInfo.Builder != "" {
-				fmt.Println("Using the following build configuration:")
-				fmt.Println("\tBuilder:", srcInfo.Builder)
+				fmt.Fprintln(cmdCtx.Out, "Using the following build configuration:")
+				fmt.Fprintln(cmdCtx.Out, "\tBuilder:", srcInfo.Builder)
 				if srcInfo.Buildpacks != nil && len(srcInfo.Buildpacks) > 0 {
-					fmt.Println("\tBuildpacks:", strings.Join(srcInfo.Buildpacks, " "))
+					fmt.Fprintln(cmdCtx.Out, "\tBuildpacks:", strings.Join(srcInfo.Buildpacks, " "))
 				}
 
 				appConfig.Build = &flyctl.Build{
@@ -190,32 +264,15 @@ func runLaunch(cmdCtx *cmdctx.CmdContext) error {
 		}
 	}
 
-	if srcInfo != nil {
-		for _, f := range srcInfo.Files {
-			path := filepath.Join(dir, f.Path)
-
-			if helpers.FileExists(path) && !confirmOverwrite(path) {
-				continue
-			}
-
-			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
-				return err
-			}
-
-			if err := os.WriteFile(path, f.Contents, 0666); err != nil {
-				return err
-			}
-		}
-	}
-
+	// ask for or check everything needed to create the app before writing any files
 	appName := ""
 
-	if !cmdCtx.Config.GetBool("generate-name") {
-		appName = cmdCtx.Config.GetString("name")
+	if !p.answers.GenerateName {
+		appName = p.answers.Name
 
 		if appName == "" {
 			// Prompt the user for the app name
-			inputName, err := inputAppName("", true)
+			inputName, err := p.appName("", true)
 
 			if err != nil {
 				return err
@@ -223,11 +280,14 @@ func runLaunch(cmdCtx *cmdctx.CmdContext) error {
 
 			appName = inputName
 		} else {
-			fmt.Printf("Selected App Name: %s\n", appName)
+			fmt.Fprintf(cmdCtx.Out, "Selected App Name: %s\n", appName)
 		}
 	}
 
-	org, err := selectOrganization(ctx, cmdCtx.Client.API(), orgSlug, nil)
+	if err := p.require("org", orgSlug); err != nil {
+		return err
+	}
+	org, err := selectOrganization(ctx, cmdCtx.Client.API(), orgSlug, nil, p.stdio())
 	if err != nil {
 		return err
 	}
@@ -237,12 +297,20 @@ func runLaunch(cmdCtx *cmdctx.CmdContext) error {
 		go imgsrc.EagerlyEnsureRemoteBuilder(ctx, cmdCtx.Client.API(), org.Slug)
 	}
 
-	regionCode := cmdCtx.Config.GetString("region")
-	region, err := selectRegion(ctx, cmdCtx.Client.API(), regionCode)
+	if err := p.require("region", p.answers.Region); err != nil {
+		return err
+	}
+	region, err := selectRegion(ctx, cmdCtx.Client.API(), p.answers.Region, p.stdio())
 	if err != nil {
 		return err
 	}
 
+	if srcInfo != nil {
+		if err := writeSourceFiles(p, dir, srcInfo.Files); err != nil {
+			return err
+		}
+	}
+
 	input := api.CreateAppInput{
 		Name:            appName,
 		OrganizationID:  org.ID,
@@ -263,74 +331,177 @@ func runLaunch(cmdCtx *cmdctx.CmdContext) error {
 	cmdCtx.AppConfig = appConfig
 
 	if srcInfo != nil {
-		if srcInfo.Port > 0 {
-			appConfig.SetInternalPort(srcInfo.Port)
+		applySourceInfo(appConfig, srcInfo, app.Name)
+	}
+
+	fmt.Fprintf(cmdCtx.Out, "Created app %s in organization %s\n", app.Name, org.Slug)
+
+	launched := summary.add(&launchedAppSummary{
+		Name:         app.Name,
+		Organization: org.Slug,
+		Region:       region.Code,
+		Hostname:     app.Hostname,
+		ConfigFile:   filepath.Join(dir, "fly.toml"),
+	})
+
+	if srcInfo != nil {
+		if err := setupSourceApp(cmdCtx, p, launched, dir, srcInfo); err != nil {
+			return err
 		}
+	}
 
-		for envName, envVal := range srcInfo.Env {
-			if envVal == "APP_FQDN" {
-				appConfig.SetEnvVariable(envName, app.Name+".fly.dev")
-			} else {
-				appConfig.SetEnvVariable(envName, envVal)
+	// Finally, write the config
+	if err := writeAppConfig(filepath.Join(dir, "fly.toml"), appConfig); err != nil {
+		return err
+	}
+
+	if srcInfo == nil {
+		return nil
+	}
+
+	// If a Postgres cluster is requested, ask to create one
+	if srcInfo.CreatePostgresCluster {
+		setup, err := p.confirm("Would you like to setup a Postgres database now?", p.answers.Postgres)
+		if err != nil {
+			return err
+		}
+		if setup {
+			if launched.Postgres, err = launchPostgres(cmdCtx, app.Name, org, region.Code); err != nil {
+				return err
 			}
 		}
+	}
 
-		if len(srcInfo.Statics) > 0 {
-			appConfig.SetStatics(srcInfo.Statics)
-		}
+	// Notices from a launcher about its behavior that should always be displayed
+	if srcInfo.Notice != "" {
+		fmt.Fprintln(cmdCtx.Out, srcInfo.Notice)
+	}
 
-		if len(srcInfo.Volumes) > 0 {
-			appConfig.SetVolumes(srcInfo.Volumes)
+	if !srcInfo.SkipDeploy {
+		deploy, err := shouldDeployNow(cmdCtx, p, "Would you like to deploy now?")
+		if err != nil {
+			return err
 		}
-
-		for procName, procCommand := range srcInfo.Processes {
-			appConfig.SetProcess(procName, procCommand)
+		if deploy {
+			if err := runDeploy(cmdCtx); err != nil {
+				return err
+			}
+			launched.Deployed = true
+			return nil
 		}
+	}
 
-		if srcInfo.ReleaseCmd != "" {
-			appConfig.SetReleaseCommand(srcInfo.ReleaseCmd)
+	// Alternative deploy documentation if our standard deploy method is not correct
+	if srcInfo.DeployDocs != "" {
+		fmt.Fprintln(cmdCtx.Out, srcInfo.DeployDocs)
+	} else {
+		fmt.Fprintln(cmdCtx.Out, "Your app is ready. Deploy with `flyctl deploy`")
+	}
+
+	return nil
+}
+
+// shouldDeployNow returns whether to deploy after launching, from --now, --no-deploy, the answers or by asking
+func shouldDeployNow(cmdCtx *cmdctx.CmdContext, p *launchPrompter, question string) (bool, error) {
+	if p.answers.Deploy || cmdCtx.Config.GetBool("no-deploy") {
+		return p.answers.Deploy, nil
+	}
+	return p.confirm(question, false)
+}
+
+// writeSourceFiles writes the files generated by the launch scanner into dir, asking before overwriting any
+func writeSourceFiles(p *launchPrompter, dir string, files []sourcecode.SourceFile) error {
+	for _, f := range files {
+		path := filepath.Join(dir, f.Path)
+
+		if helpers.FileExists(path) {
+			overwrite, err := p.confirmOverwrite(path)
+			if err != nil {
+				return err
+			}
+			if !overwrite {
+				continue
+			}
 		}
 
-		if srcInfo.DockerCommand != "" {
-			appConfig.SetDockerCommand(srcInfo.DockerCommand)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return err
 		}
 
-		if srcInfo.DockerCommand != "" {
-			appConfig.SetDockerEntrypoint(srcInfo.DockerEntrypoint)
+		if err := os.WriteFile(path, f.Contents, 0666); err != nil {
+			return err
 		}
+	}
+	return nil
+}
+
+// applySourceInfo configures the app as the launch scanner suggests
+func applySourceInfo(appConfig *flyctl.AppConfig, srcInfo *sourcecode.SourceInfo, appName string) {
+	if srcInfo.Port > 0 {
+		appConfig.SetInternalPort(srcInfo.Port)
+	}
 
-		if srcInfo.KillSignal != "" {
-			appConfig.SetKillSignal(srcInfo.KillSignal)
+	for envName, envVal := range srcInfo.Env {
+		if envVal == "APP_FQDN" {
+			appConfig.SetEnvVariable(envName, appName+".fly.dev")
+		} else {
+			appConfig.SetEnvVariable(envName, envVal)
 		}
 	}
 
-	fmt.Printf("Created app %s in organization %s\n", app.Name, org.Slug)
+	if len(srcInfo.Statics) > 0 {
+		appConfig.SetStatics(srcInfo.Statics)
+	}
+
+	if len(srcInfo.Volumes) > 0 {
+		appConfig.SetVolumes(srcInfo.Volumes)
+	}
+
+	for procName, procCommand := range srcInfo.Processes {
+		appConfig.SetProcess(procName, procCommand)
+	}
+
+	if srcInfo.ReleaseCmd != "" {
+		appConfig.SetReleaseCommand(srcInfo.ReleaseCmd)
+	}
+
+	if srcInfo.DockerCommand != "" {
+		appConfig.SetDockerCommand(srcInfo.DockerCommand)
+	}
+
+	if srcInfo.DockerCommand != "" {
+		appConfig.SetDockerEntrypoint(srcInfo.DockerEntrypoint)
+	}
+
+	if srcInfo.KillSignal != "" {
+		appConfig.SetKillSignal(srcInfo.KillSignal)
+	}
+}
+
+// setupSourceApp sets the secrets, creates the volumes and runs the commands the launch scanner asks for in dir
+func setupSourceApp(cmdCtx *cmdctx.CmdContext, p *launchPrompter, launched *launchedAppSummary, dir string, srcInfo *sourcecode.SourceInfo) error {
+	ctx := cmdCtx.Command.Context()
+	appName, regionCode := launched.Name, launched.Region
 
 	// If secrets are requested by the launch scanner, ask the user to input them
-	if srcInfo != nil && len(srcInfo.Secrets) > 0 {
+	if len(srcInfo.Secrets) > 0 {
 		secrets := make(map[string]string)
 		keys := []string{}
 
 		for _, secret := range srcInfo.Secrets {
 
-			val := ""
+			var val string
+			var err error
 
 			// If a secret should be a random default, just generate it without displaying
 			// Otherwise, prompt to type it in
 			if secret.Generate {
-				if val, err = helpers.RandString(64); err != nil {
-					fmt.Errorf("Could not generate random string: %w", err)
-				}
-
-			} else {
-				prompt := fmt.Sprintf("Set secret %s:", secret.Key)
-
-				surveyInput := &survey.Input{
-					Message: prompt,
-					Help:    secret.Help,
+				if val, err = secret.GenerateValue(); err != nil {
+					return fmt.Errorf("Could not generate random string: %w", err)
 				}
 
-				survey.AskOne(surveyInput, &val)
+			} else if val, err = p.secret(secret.Key, secret.Help); err != nil {
+				return err
 			}
 
 			if val != "" {
@@ -340,148 +511,115 @@ func runLaunch(cmdCtx *cmdctx.CmdContext) error {
 		}
 
 		if len(secrets) > 0 {
-			_, err := cmdCtx.Client.API().SetSecrets(ctx, app.Name, secrets)
+			_, err := cmdCtx.Client.API().SetSecrets(ctx, appName, secrets)
 
 			if err != nil {
 				return err
 			}
-			fmt.Printf("Set secrets on %s: %s\n", app.Name, strings.Join(keys, ", "))
+			fmt.Fprintf(cmdCtx.Out, "Set secrets on %s: %s\n", appName, strings.Join(keys, ", "))
+			launched.Secrets = keys
 		}
 	}
 
 	// If volumes are requested by the launch scanner, create them
-	if srcInfo != nil && len(srcInfo.Volumes) > 0 {
-
-		for _, vol := range srcInfo.Volumes {
-
-			app, err := cmdCtx.Client.API().GetApp(ctx, cmdCtx.AppName)
-
-			if err != nil {
-				return err
-			}
-
-			volume, err := cmdCtx.Client.API().CreateVolume(ctx, api.CreateVolumeInput{
-				AppID:     app.ID,
-				Name:      vol.Source,
-				Region:    region.Code,
-				SizeGb:    10,
-				Encrypted: true,
-			})
-
-			if err != nil {
-				return err
-			} else {
-				fmt.Printf("Created a %dGB volume %s in the %s region\n", volume.SizeGb, volume.ID, region.Code)
-			}
+	for _, vol := range srcInfo.Volumes {
+		app, err := cmdCtx.Client.API().GetApp(ctx, appName)
+		if err != nil {
+			return err
+		}
 
+		volume, err := cmdCtx.Client.API().CreateVolume(ctx, api.CreateVolumeInput{
+			AppID:     app.ID,
+			Name:      vol.Source,
+			Region:    regionCode,
+			SizeGb:    10,
+			Encrypted: true,
+		})
+		if err != nil {
+			return err
 		}
+		fmt.Fprintf(cmdCtx.Out, "Created a %dGB volume %s in the %s region\n", volume.SizeGb, volume.ID, regionCode)
+		launched.Volumes = append(launched.Volumes, volume.ID)
 	}
 
 	// Run any initialization commands
-	if srcInfo != nil && len(srcInfo.InitCommands) > 0 {
-		for _, cmd := range srcInfo.InitCommands {
-			binary, err := exec.LookPath(cmd.Command)
-			if err != nil {
-				return fmt.Errorf("%s not found in $PATH - make sure app dependencies are installed and try again", cmd.Command)
-			}
-			fmt.Println(cmd.Description)
-			// Run a requested generator command, for example to generate a Dockerfile
-			cmd := exec.CommandContext(ctx, binary, cmd.Args...)
+	for _, cmd := range srcInfo.InitCommands {
+		binary, err := exec.LookPath(cmd.Command)
+		if err != nil {
+			return fmt.Errorf("%s not found in $PATH - make sure app dependencies are installed and try again", cmd.Command)
+		}
+		fmt.Fprintln(cmdCtx.Out, cmd.Description)
+		// Run a requested generator command, for example to generate a Dockerfile
+		cmd := exec.CommandContext(ctx, binary, cmd.Args...)
+		cmd.Dir = dir
 
-			if err = cmd.Start(); err != nil {
-				return err
-			}
+		if err = cmd.Start(); err != nil {
+			return err
+		}
 
-			if err = cmd.Wait(); err != nil {
-				err = fmt.Errorf("failed running %s: %w ", cmd.String(), err)
+		if err = cmd.Wait(); err != nil {
+			err = fmt.Errorf("failed running %s: %w ", cmd.String(), err)
 
-				return err
-			}
+			return err
 		}
 	}
 
 	// Append any requested Dockerfile entries
-	if srcInfo != nil && len(srcInfo.DockerfileAppendix) > 0 {
-		if err := appendDockerfileAppendix(srcInfo.DockerfileAppendix); err != nil {
+	if len(srcInfo.DockerfileAppendix) > 0 {
+		if err := appendDockerfileAppendix(filepath.Join(dir, "Dockerfile"), srcInfo.DockerfileAppendix); err != nil {
 			return fmt.Errorf("failed appending Dockerfile appendix: %w", err)
 		}
 	}
 
-	// Finally, write the config
-	if err := writeAppConfig(filepath.Join(dir, "fly.toml"), appConfig); err != nil {
-		return err
-	}
-
-	if srcInfo == nil {
-		return nil
-	}
-
-	// If a Postgres cluster is requested, ask to create one
-	if srcInfo.CreatePostgresCluster && confirm("Would you like to setup a Postgres database now?") {
-
-		app, err := cmdCtx.Client.API().GetApp(ctx, cmdCtx.AppName)
-
-		if err != nil {
-			return err
-		}
-
-		options := standalonePostgres()
-
-		clusterAppName := app.Name + "-db"
-
-		// Create a standalone Postgres in the same region as the app and organization
-		clusterInput := api.CreatePostgresClusterInput{
-			OrganizationID: org.ID,
-			Name:           clusterAppName,
-			Region:         api.StringPointer(region.Code),
-			ImageRef:       api.StringPointer(options.ImageRef),
-			Count:          api.IntPointer(1),
-		}
-		payload, err := runApiCreatePostgresCluster(cmdCtx, org.Slug, &clusterInput)
-
-		if err != nil {
-			return err
-		}
+	return nil
+}
 
-		attachInput := api.AttachPostgresClusterInput{
-			AppID:                app.ID,
-			PostgresClusterAppID: clusterAppName,
-		}
+// launchPostgres creates a standalone Postgres cluster in the same region and organization as the app, attaches it
+// and returns its name
+func launchPostgres(cmdCtx *cmdctx.CmdContext, appName string, org *api.Organization, regionCode string) (string, error) {
+	app, err := cmdCtx.Client.API().GetApp(cmdCtx.Command.Context(), appName)
 
-		_, err = cmdCtx.Client.API().AttachPostgresCluster(cmdCtx.Command.Context(), attachInput)
+	if err != nil {
+		return "", err
+	}
 
-		// Reset the app name here beacuse AttachPostgresCluster sets it on the cmdCtx :/
-		cmdCtx.AppName = app.ID
+	options := standalonePostgres()
 
-		if err != nil {
-			return err
-		}
+	clusterAppName := app.Name + "-db"
 
-		fmt.Printf("Postgres cluster %s is now attached to %s\n", payload.App.Name, app.Name)
+	// Create a standalone Postgres in the same region as the app and organization
+	clusterInput := api.CreatePostgresClusterInput{
+		OrganizationID: org.ID,
+		Name:           clusterAppName,
+		Region:         api.StringPointer(regionCode),
+		ImageRef:       api.StringPointer(options.ImageRef),
+		Count:          api.IntPointer(1),
 	}
+	payload, err := runApiCreatePostgresCluster(cmdCtx, org.Slug, &clusterInput)
 
-	// Notices from a launcher about its behavior that should always be displayed
-	if srcInfo.Notice != "" {
-		fmt.Println(srcInfo.Notice)
+	if err != nil {
+		return "", err
 	}
 
-	if !cmdCtx.Config.GetBool("no-deploy") &&
-		!srcInfo.SkipDeploy &&
-		(cmdCtx.Config.GetBool("now") || confirm("Would you like to deploy now?")) {
-		return runDeploy(cmdCtx)
+	attachInput := api.AttachPostgresClusterInput{
+		AppID:                app.ID,
+		PostgresClusterAppID: clusterAppName,
 	}
 
-	// Alternative deploy documentation if our standard deploy method is not correct
-	if srcInfo.DeployDocs != "" {
-		fmt.Println(srcInfo.DeployDocs)
-	} else {
-		fmt.Println("Your app is ready. Deploy with `flyctl deploy`")
+	_, err = cmdCtx.Client.API().AttachPostgresCluster(cmdCtx.Command.Context(), attachInput)
+
+	// Reset the app name here beacuse AttachPostgresCluster sets it on the cmdCtx :/
+	cmdCtx.AppName = app.ID
+
+	if err != nil {
+		return "", err
 	}
 
-	return nil
+	fmt.Fprintf(cmdCtx.Out, "Postgres cluster %s is now attached to %s\n", payload.App.Name, app.Name)
+	return payload.App.Name, nil
 }
 
-func appendDockerfileAppendix(appendix []string) (err error) {
+func appendDockerfileAppendix(path string, appendix []string) (err error) {
 	var b bytes.Buffer
 	b.WriteString("\n# Appended by flyctl\n")
 
@@ -492,7 +630,7 @@ func appendDockerfileAppendix(appendix []string) (err error) {
 
 	var f *os.File
 	// TODO: this is prone to race conditions and also we don't flush
-	if f, err = os.OpenFile("Dockerfile", os.O_APPEND|os.O_WRONLY, 0644); err != nil {
+	if f, err = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644); err != nil {
 		return
 	}
 	defer func() {
@@ -529,3 +667,20 @@ func shouldDeployExistingApp(cmdCtx *cmdctx.CmdContext, appName string) (bool, e
 
 	return true, nil
 }
+
+func printScannerTraces(w io.Writer, traces []sourcecode.Trace) {
+	fmt.Fprintln(w, "Scanners, in the order they are checked:")
+	for _, trace := range traces {
+		mark, note := aurora.Red("✗"), ""
+		switch {
+		case trace.Selected:
+			mark = aurora.Green("✓")
+		case trace.Matched:
+			mark, note = aurora.Yellow("✓"), ", not used since an earlier scanner matched"
+		}
+		fmt.Fprintf(w, "  %s %s (%s, priority %d%s)\n", mark, trace.Scanner, trace.Source, trace.Priority, note)
+		for _, reason := range trace.Reasons {
+			fmt.Fprintf(w, "      %s\n", reason)
+		}
+	}
+}
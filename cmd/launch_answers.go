@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/sammccord/flyctl/cmdctx"
+	"github.com/sammccord/flyctl/internal/launchanswers"
+)
+
+// loadLaunchAnswers reads the answers from --from or --json-answers, with the flags taking precedence.
+// Without either, the answers only hold the flags and fromAnswers is false.
+func loadLaunchAnswers(cmdCtx *cmdctx.CmdContext) (answers *launchanswers.Answers, fromAnswers bool, err error) {
+	from := cmdCtx.Config.GetString("from")
+	jsonAnswers := cmdCtx.Config.GetString("json-answers")
+
+	answers = &launchanswers.Answers{}
+	switch {
+	case from != "" && jsonAnswers != "":
+		return nil, false, errors.New("--from and --json-answers are mutually exclusive")
+	case from != "":
+		data, err := os.ReadFile(from)
+		if err != nil {
+			return nil, false, errors.Wrap(err, "error reading launch answers")
+		}
+		if err := yaml.UnmarshalStrict(data, answers); err != nil {
+			return nil, false, errors.Wrapf(err, "error parsing %s", from)
+		}
+	case jsonAnswers != "":
+		var r io.Reader = strings.NewReader(jsonAnswers)
+		if jsonAnswers == "-" {
+			r = cmdCtx.IO.In
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, false, errors.Wrap(err, "error reading launch answers")
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(answers); err != nil {
+			return nil, false, errors.Wrap(err, "error parsing --json-answers")
+		}
+	}
+	fromAnswers = from != "" || jsonAnswers != ""
+
+	for flag, field := range map[string]*string{
+		"name":       &answers.Name,
+		"org":        &answers.Org,
+		"region":     &answers.Region,
+		"image":      &answers.Image,
+		"dockerfile": &answers.Dockerfile,
+	} {
+		if value := cmdCtx.Config.GetString(flag); value != "" {
+			*field = value
+		}
+	}
+	if dirs := cmdCtx.Config.GetStringSlice("apps"); len(dirs) > 0 {
+		answers.Apps = dirs
+	}
+	answers.GenerateName = answers.GenerateName || cmdCtx.Config.GetBool("generate-name")
+	answers.CopyConfig = answers.CopyConfig || cmdCtx.Config.GetBool("copy-config")
+	answers.Deploy = (answers.Deploy || cmdCtx.Config.GetBool("now")) && !cmdCtx.Config.GetBool("no-deploy")
+
+	if fromAnswers {
+		if err := answers.Validate(); err != nil {
+			return nil, false, err
+		}
+	}
+	return answers, fromAnswers, nil
+}
+
+// launchPrompter answers the questions of fly launch from the answers when they were given, and otherwise
+// prompts unless stdin isn't a terminal. Its answers always hold the flags.
+type launchPrompter struct {
+	answers     *launchanswers.Answers
+	fromAnswers bool
+	canPrompt   bool
+}
+
+func newLaunchPrompter(cmdCtx *cmdctx.CmdContext) (*launchPrompter, error) {
+	answers, fromAnswers, err := loadLaunchAnswers(cmdCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &launchPrompter{
+		answers:     answers,
+		fromAnswers: fromAnswers,
+		canPrompt:   cmdCtx.IO.IsStdinTTY(),
+	}, nil
+}
+
+func (p *launchPrompter) noPrompt(question string) error {
+	return fmt.Errorf("can't ask %q because stdin isn't a terminal, pass the answers with --from or --json-answers", question)
+}
+
+// require fails when a flag that would otherwise be prompted for is missing and there is no one to ask
+func (p *launchPrompter) require(flag, value string) error {
+	if value == "" && !p.fromAnswers && !p.canPrompt {
+		return fmt.Errorf("--%s is required when stdin isn't a terminal, or pass the answers with --from or --json-answers", flag)
+	}
+	return nil
+}
+
+// confirm returns answer when launching from answers, and otherwise asks
+func (p *launchPrompter) confirm(question string, answer bool) (bool, error) {
+	if p.fromAnswers {
+		return answer, nil
+	}
+	if !p.canPrompt {
+		return false, p.noPrompt(question)
+	}
+	return confirm(question, p.stdio()), nil
+}
+
+func (p *launchPrompter) confirmOverwrite(path string) (bool, error) {
+	return p.confirm(fmt.Sprintf(`Overwrite "%s"?`, path), p.answers.Overwrite)
+}
+
+func (p *launchPrompter) appName(defaultName string, autoGenerate bool) (string, error) {
+	if p.fromAnswers {
+		if p.answers.Name == "" && !autoGenerate {
+			return defaultName, nil
+		}
+		return p.answers.Name, nil
+	}
+	if !p.canPrompt {
+		return "", p.noPrompt("App Name")
+	}
+	return inputAppName(defaultName, autoGenerate, p.stdio())
+}
+
+// secret returns the value of a secret requested by a launch scanner, which is left unset when empty
+func (p *launchPrompter) secret(key, help string) (string, error) {
+	if p.fromAnswers {
+		return p.answers.Secrets[key], nil
+	}
+	if !p.canPrompt {
+		return "", p.noPrompt("Set secret " + key)
+	}
+
+	val := ""
+	prompt := &survey.Input{
+		Message: fmt.Sprintf("Set secret %s:", key),
+		Help:    help,
+	}
+	err := survey.AskOne(prompt, &val, p.stdio())
+	return val, err
+}
+
+// stdio draws prompts on stderr, keeping stdout for the JSON summary
+func (p *launchPrompter) stdio() survey.AskOpt {
+	return survey.WithStdio(os.Stdin, os.Stderr, os.Stderr)
+}
+
+// launchSummary lists the resources fly launch created, printed as JSON with --from, --json-answers or --json
+type launchSummary struct {
+	Apps []*launchedAppSummary `json:"apps"`
+}
+
+type launchedAppSummary struct {
+	Name         string   `json:"name"`
+	Organization string   `json:"organization"`
+	Region       string   `json:"region"`
+	Hostname     string   `json:"hostname"`
+	ConfigFile   string   `json:"config_file"`
+	Secrets      []string `json:"secrets,omitempty"`
+	Volumes      []string `json:"volumes,omitempty"`
+	Postgres     string   `json:"postgres,omitempty"`
+	Deployed     bool     `json:"deployed"`
+}
+
+func (s *launchSummary) add(app *launchedAppSummary) *launchedAppSummary {
+	s.Apps = append(s.Apps, app)
+	return app
+}
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/logrusorgru/aurora"
+
+	"github.com/sammccord/flyctl/api"
+	"github.com/sammccord/flyctl/cmdctx"
+	"github.com/sammccord/flyctl/flyctl"
+	"github.com/sammccord/flyctl/internal/build/imgsrc"
+	"github.com/sammccord/flyctl/internal/sourcecode"
+)
+
+// launchedApp is an app of a monorepo that was created by launchWorkspace
+type launchedApp struct {
+	dir       string
+	appConfig *flyctl.AppConfig
+	summary   *launchedAppSummary
+}
+
+// selectWorkspaceApps returns the apps of the monorepo to launch, either from --apps and the answers or by asking.
+// Only apps with their own Dockerfile can be launched together, see launchWorkspace.
+func selectWorkspaceApps(w io.Writer, p *launchPrompter, apps []sourcecode.WorkspaceApp) ([]sourcecode.WorkspaceApp, error) {
+	if dirs := p.answers.Apps; len(dirs) > 0 {
+		byDir := map[string]sourcecode.WorkspaceApp{}
+		for _, app := range apps {
+			byDir[app.Dir] = app
+		}
+
+		selected := []sourcecode.WorkspaceApp{}
+		for _, dir := range dirs {
+			app, ok := byDir[path.Clean(filepath.ToSlash(dir))]
+			if !ok {
+				return nil, fmt.Errorf("%s is not an app of this monorepo", dir)
+			}
+			if !app.Dockerfile {
+				return nil, errNoWorkspaceDockerfile(app)
+			}
+			selected = append(selected, app)
+		}
+		return selected, nil
+	}
+
+	// without --apps, launching without a terminal treats the monorepo like any other directory
+	if !p.canPrompt && !p.fromAnswers {
+		return nil, nil
+	}
+
+	ready := []sourcecode.WorkspaceApp{}
+	for _, app := range apps {
+		if app.Dockerfile {
+			ready = append(ready, app)
+		}
+	}
+	if len(ready) == 0 {
+		return nil, nil
+	}
+
+	fmt.Fprintf(w, "Detected a monorepo with %d apps\n", len(apps))
+	for _, app := range apps {
+		if !app.Dockerfile && app.Launchable {
+			fmt.Fprintln(w, aurora.Yellow(errNoWorkspaceDockerfile(app).Error()))
+		}
+	}
+	if launchSeveral, err := p.confirm(fmt.Sprintf("Would you like to launch the %d apps with a Dockerfile at once?", len(ready)), false); err != nil || !launchSeveral {
+		return nil, err
+	}
+	apps = ready
+
+	options := []string{}
+	defaults := []string{}
+	for _, app := range apps {
+		option := fmt.Sprintf("%s (%s)", app.Dir, strings.Join(app.Kinds, ", "))
+		options = append(options, option)
+		defaults = append(defaults, option)
+	}
+
+	prompt := &survey.MultiSelect{
+		Message:  "Select the apps to launch:",
+		Options:  options,
+		Default:  defaults,
+		PageSize: 15,
+	}
+
+	var indexes []int
+	if err := survey.AskOne(prompt, &indexes, p.stdio()); err != nil {
+		return nil, err
+	}
+
+	selected := []sourcecode.WorkspaceApp{}
+	for _, i := range indexes {
+		selected = append(selected, apps[i])
+	}
+	return selected, nil
+}
+
+// errNoWorkspaceDockerfile explains why app can't be launched with the rest of the monorepo. Scanners write
+// Dockerfiles for the app's own directory, which can't reach the workspace packages it shares with other apps.
+func errNoWorkspaceDockerfile(app sourcecode.WorkspaceApp) error {
+	return fmt.Errorf("%s has no Dockerfile to build it from the repository root, add one or launch it on its own with `flyctl launch --path %s`", app.Dir, app.Dir)
+}
+
+// launchWorkspace creates an app with its own fly.toml for each of the selected apps of the monorepo at root.
+// Apps are built with their Dockerfile from the repository root so they can use shared code.
+func launchWorkspace(cmdCtx *cmdctx.CmdContext, p *launchPrompter, summary *launchSummary, root string, apps []sourcecode.WorkspaceApp) error {
+	ctx := cmdCtx.Command.Context()
+
+	if err := p.require("org", p.answers.Org); err != nil {
+		return err
+	}
+	if err := p.require("region", p.answers.Region); err != nil {
+		return err
+	}
+
+	// ask for every name before creating anything, so a missing answer doesn't leave some apps launched
+	appNames := make([]string, len(apps))
+	for i, wa := range apps {
+		appName, err := p.appName(wa.Name, false)
+		if err != nil {
+			return err
+		}
+		appNames[i] = appName
+	}
+
+	org, err := selectOrganization(ctx, cmdCtx.Client.API(), p.answers.Org, nil, p.stdio())
+	if err != nil {
+		return err
+	}
+	go imgsrc.EagerlyEnsureRemoteBuilder(ctx, cmdCtx.Client.API(), org.Slug)
+
+	region, err := selectRegion(ctx, cmdCtx.Client.API(), p.answers.Region, p.stdio())
+	if err != nil {
+		return err
+	}
+
+	launched := []launchedApp{}
+	for i, wa := range apps {
+		appDir := filepath.Join(root, filepath.FromSlash(wa.Dir))
+		fmt.Fprintf(cmdCtx.Out, "\nLaunching %s\n", aurora.Bold(wa.Dir))
+
+		// the Dockerfile is relative to the build context
+		appConfig := flyctl.NewAppConfig()
+		appConfig.Build = &flyctl.Build{
+			Dockerfile: path.Join(wa.Dir, "Dockerfile"),
+			Context:    wa.BuildContext(),
+		}
+		fmt.Fprintf(cmdCtx.Out, "Using %s with the repository root as build context\n", appConfig.Build.Dockerfile)
+
+		app, err := cmdCtx.Client.API().CreateApp(ctx, api.CreateAppInput{
+			Name:            appNames[i],
+			OrganizationID:  org.ID,
+			PreferredRegion: &region.Code,
+			Runtime:         "FIRECRACKER",
+		})
+		if err != nil {
+			return err
+		}
+		appConfig.Definition = app.Config.Definition
+		appConfig.AppName = app.Name
+
+		fmt.Fprintf(cmdCtx.Out, "Created app %s in organization %s\n", app.Name, org.Slug)
+
+		appSummary := summary.add(&launchedAppSummary{
+			Name:         app.Name,
+			Organization: org.Slug,
+			Region:       region.Code,
+			Hostname:     app.Hostname,
+			ConfigFile:   filepath.Join(appDir, "fly.toml"),
+		})
+
+		if err := writeAppConfig(filepath.Join(appDir, "fly.toml"), appConfig); err != nil {
+			return err
+		}
+
+		launched = append(launched, launchedApp{
+			dir:       appDir,
+			appConfig: appConfig,
+			summary:   appSummary,
+		})
+	}
+
+	deploy, err := shouldDeployNow(cmdCtx, p, fmt.Sprintf("Would you like to deploy %d apps now?", len(launched)))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(cmdCtx.Out)
+	for _, app := range launched {
+		if !deploy {
+			rel, _ := filepath.Rel(root, app.dir)
+			fmt.Fprintf(cmdCtx.Out, "%s is ready. Deploy with `flyctl deploy %s`\n", app.appConfig.AppName, rel)
+			continue
+		}
+
+		cmdCtx.AppName = app.appConfig.AppName
+		cmdCtx.AppConfig = app.appConfig
+		cmdCtx.ConfigFile = filepath.Join(app.dir, "fly.toml")
+		cmdCtx.WorkingDir = app.dir
+
+		if err := runDeploy(cmdCtx); err != nil {
+			return fmt.Errorf("failed deploying %s: %w", app.appConfig.AppName, err)
+		}
+		app.summary.Deployed = true
+	}
+
+	return nil
+}
@@ -19,7 +19,12 @@ import (
 
 func newMonitorCommand(client *client.Client) *Command {
 	ks := docstrings.Get("monitor")
-	return BuildCommandKS(nil, runMonitor, ks, client, requireSession, requireAppName)
+	cmd := BuildCommandKS(nil, runMonitor, ks, client, requireSession, requireAppName)
+	cmd.AddStringFlag(StringFlagOpts{
+		Name:        "events",
+		Description: "Write deployment events to stdout in a machine readable format. Only ndjson is supported",
+	})
+	return cmd
 }
 
 func runMonitor(commandContext *cmdctx.CmdContext) error {
@@ -32,10 +37,15 @@ func runMonitor(commandContext *cmdctx.CmdContext) error {
 		return fmt.Errorf("Failed to get app from context")
 	}
 
+	ctx, err = withDeploymentEvents(context.Background(), commandContext)
+	if err != nil {
+		return err
+	}
+
 	commandContext.Statusf("monitor", cmdctx.STITLE, "Monitoring Deployments for %s\n", app.Name)
 
 	for {
-		err := monitorDeployment(context.Background(), commandContext)
+		err := monitorDeployment(ctx, commandContext)
 		if err != nil {
 			return err
 		}
@@ -139,6 +149,10 @@ func monitorDeployment(ctx context.Context, cmdCtx *cmdctx.CmdContext) error {
 		return nil
 	}
 
+	if emitter := deployment.EmitterFromContext(ctx); emitter != nil {
+		emitter.Attach(monitor)
+	}
+
 	monitor.Start(ctx)
 
 	if err := monitor.Error(); err != nil {
@@ -134,6 +134,33 @@ func newPostgresCommand(client *client.Client) *Command {
 	connectCmd.AddStringFlag(StringFlagOpts{Name: "user", Description: "The postgres user to connect with"})
 	connectCmd.AddStringFlag(StringFlagOpts{Name: "password", Description: "The postgres user password"})
 
+	statusStrings := docstrings.Get("postgres.status")
+	statusCmd := BuildCommandKS(cmd, runPostgresStatus, statusStrings, client, requireSession, requireAppNameAsArg)
+	statusCmd.AddStringFlag(StringFlagOpts{Name: "user", Description: "The postgres user to query as"})
+	statusCmd.AddStringFlag(StringFlagOpts{Name: "password", Description: "The postgres user password, defaults to $PGPASSWORD"})
+
+	backupStrings := docstrings.Get("postgres.backup")
+	backupCmd := BuildCommandKS(cmd, runPostgresBackup, backupStrings, client, requireSession, requireAppNameAsArg)
+	backupCmd.AddStringFlag(StringFlagOpts{Name: "database", Description: "The postgres database to back up"})
+	backupCmd.AddStringFlag(StringFlagOpts{Name: "user", Description: "The postgres user to back up as"})
+	backupCmd.AddStringFlag(StringFlagOpts{Name: "password", Description: "The postgres user password, defaults to $PGPASSWORD"})
+	backupCmd.AddStringFlag(StringFlagOpts{Name: "output", Shorthand: "o", Description: "Local path or s3://<bucket>/<key> to write the backup to"})
+	backupCmd.AddIntFlag(IntFlagOpts{Name: "compression-level", Description: "pg_dump compression level, from 0 for none to 9", Default: 6})
+	backupCmd.AddStringFlag(StringFlagOpts{Name: "s3-endpoint", Description: "Endpoint of an S3-compatible service, defaults to $AWS_ENDPOINT_URL or AWS"})
+	backupCmd.AddStringFlag(StringFlagOpts{Name: "s3-region", Description: "Region of the bucket, defaults to $AWS_REGION or us-east-1"})
+
+	restoreStrings := docstrings.Get("postgres.restore")
+	restoreCmd := BuildCommandKS(cmd, runPostgresRestore, restoreStrings, client, requireSession, requireAppNameAsArg)
+	restoreCmd.AddStringFlag(StringFlagOpts{Name: "from", Description: "Local path or s3://<bucket>/<key> of the backup to restore"})
+	restoreCmd.AddStringFlag(StringFlagOpts{Name: "database", Description: "The postgres database to restore into"})
+	restoreCmd.AddStringFlag(StringFlagOpts{Name: "user", Description: "The postgres user to restore as"})
+	restoreCmd.AddStringFlag(StringFlagOpts{Name: "password", Description: "The postgres user password, defaults to $PGPASSWORD"})
+	restoreCmd.AddBoolFlag(BoolFlagOpts{Name: "clean", Description: "Drop database objects before recreating them"})
+	restoreCmd.AddBoolFlag(BoolFlagOpts{Name: "no-verify", Description: "Restore without checking the backup against its checksum"})
+	restoreCmd.AddBoolFlag(BoolFlagOpts{Name: "yes", Shorthand: "y", Description: "accept all confirmations"})
+	restoreCmd.AddStringFlag(StringFlagOpts{Name: "s3-endpoint", Description: "Endpoint of an S3-compatible service, defaults to $AWS_ENDPOINT_URL or AWS"})
+	restoreCmd.AddStringFlag(StringFlagOpts{Name: "s3-region", Description: "Region of the bucket, defaults to $AWS_REGION or us-east-1"})
+
 	attachStrngs := docstrings.Get("postgres.attach")
 	attachCmd := BuildCommandKS(cmd, runAttachPostgresCluster, attachStrngs, client, requireSession, requireAppName)
 	attachCmd.AddStringFlag(StringFlagOpts{Name: "postgres-app", Description: "the postgres cluster to attach to the app"})
@@ -314,14 +341,14 @@ func runApiCreatePostgresCluster(cmdCtx *cmdctx.CmdContext, org string, input *a
 	s.FinalMSG = fmt.Sprintf("Postgres cluster %s created\n", payload.App.Name)
 	s.Stop()
 
-	fmt.Printf("  Username:    %s\n", payload.Username)
-	fmt.Printf("  Password:    %s\n", payload.Password)
-	fmt.Printf("  Hostname:    %s.internal\n", payload.App.Name)
-	fmt.Printf("  Proxy Port:  5432\n")
-	fmt.Printf("  PG Port: 5433\n")
+	fmt.Fprintf(cmdCtx.Out, "  Username:    %s\n", payload.Username)
+	fmt.Fprintf(cmdCtx.Out, "  Password:    %s\n", payload.Password)
+	fmt.Fprintf(cmdCtx.Out, "  Hostname:    %s.internal\n", payload.App.Name)
+	fmt.Fprintf(cmdCtx.Out, "  Proxy Port:  5432\n")
+	fmt.Fprintf(cmdCtx.Out, "  PG Port: 5433\n")
 
-	fmt.Println(aurora.Italic("Save your credentials in a secure place, you won't be able to see them again!"))
-	fmt.Println()
+	fmt.Fprintln(cmdCtx.Out, aurora.Italic("Save your credentials in a secure place, you won't be able to see them again!"))
+	fmt.Fprintln(cmdCtx.Out)
 
 	cancelCtx := cmdCtx.Command.Context()
 	cmdCtx.AppName = payload.App.Name
@@ -332,13 +359,13 @@ func runApiCreatePostgresCluster(cmdCtx *cmdctx.CmdContext, org string, input *a
 	}
 
 	if err == nil {
-		fmt.Println()
-		fmt.Println(aurora.Bold("Connect to postgres"))
-		fmt.Printf("Any app within the %s organization can connect to postgres using the above credentials and the hostname \"%s.internal.\"\n", org, payload.App.Name)
-		fmt.Printf("For example: postgres://%s:%s@%s.internal:%d\n", payload.Username, payload.Password, payload.App.Name, 5432)
+		fmt.Fprintln(cmdCtx.Out)
+		fmt.Fprintln(cmdCtx.Out, aurora.Bold("Connect to postgres"))
+		fmt.Fprintf(cmdCtx.Out, "Any app within the %s organization can connect to postgres using the above credentials and the hostname \"%s.internal.\"\n", org, payload.App.Name)
+		fmt.Fprintf(cmdCtx.Out, "For example: postgres://%s:%s@%s.internal:%d\n", payload.Username, payload.Password, payload.App.Name, 5432)
 
-		fmt.Println()
-		fmt.Println("See the postgres docs for more information on next steps, managing postgres, connecting from outside fly:  https://fly.io/docs/reference/postgres/")
+		fmt.Fprintln(cmdCtx.Out)
+		fmt.Fprintln(cmdCtx.Out, "See the postgres docs for more information on next steps, managing postgres, connecting from outside fly:  https://fly.io/docs/reference/postgres/")
 	}
 
 	return payload, err
@@ -596,54 +623,70 @@ func runDetachPostgresCluster(cmdCtx *cmdctx.CmdContext) error {
 	return nil
 }
 
-func runPostgresConnect(cmdCtx *cmdctx.CmdContext) error {
-	// Minimum image version requirements
-	MinPostgresStandaloneVersion := "0.0.4"
-	MinPostgresHaVersion := "0.0.9"
-
-	ctx := cmdCtx.Command.Context()
-	client := cmdCtx.Client.API()
+// Minimum image versions flyctl can connect to, per repository
+const (
+	// https://github.com/fly-apps/postgres-standalone/releases/tag/v0.0.4
+	minPostgresStandaloneVersion = "0.0.4"
+	// https://github.com/fly-apps/postgres-ha/releases/tag/v0.0.9
+	minPostgresHaVersion = "0.0.9"
+)
 
-	app, err := client.GetApp(ctx, cmdCtx.AppName)
-	if err != nil {
-		return fmt.Errorf("get app: %w", err)
-	}
+// postgresImage is the image a postgres app runs
+type postgresImage struct {
+	Repository string
+	Version    *version.Version
+	HA         bool
+}
 
-	// Validate image version to ensure it's compatible with this feature.
-	imageVersionStr := app.ImageDetails.Version[1:]
-	imageVersion, err := version.NewVersion(imageVersionStr)
+// checkPostgresImage validates that a postgres app runs an image compatible with flyctl
+func checkPostgresImage(app *api.App) (*postgresImage, error) {
+	imageVersion, err := version.NewVersion(strings.TrimPrefix(app.ImageDetails.Version, "v"))
 	if err != nil {
-		return err
+		return nil, err
 	}
+	image := &postgresImage{Repository: app.ImageDetails.Repository, Version: imageVersion}
 
 	// Specify compatible versions per repo.
-	requiredVersion := &version.Version{}
-	if app.ImageDetails.Repository == "flyio/postgres-standalone" {
-		// https://github.com/fly-apps/postgres-standalone/releases/tag/v0.0.4
-		requiredVersion, err = version.NewVersion(MinPostgresStandaloneVersion)
-		if err != nil {
-			return err
-		}
+	var requiredVersion *version.Version
+	switch image.Repository {
+	case "flyio/postgres-standalone":
+		requiredVersion, err = version.NewVersion(minPostgresStandaloneVersion)
+	case "flyio/postgres":
+		image.HA = true
+		requiredVersion, err = version.NewVersion(minPostgresHaVersion)
 	}
-	if app.ImageDetails.Repository == "flyio/postgres" {
-		// https://github.com/fly-apps/postgres-ha/releases/tag/v0.0.9
-		requiredVersion, err = version.NewVersion(MinPostgresHaVersion)
-		if err != nil {
-			return err
-		}
+	if err != nil {
+		return nil, err
 	}
 
 	if requiredVersion == nil {
-		return fmt.Errorf("Unable to resolve image version...")
+		return nil, fmt.Errorf("Unable to resolve image version...")
 	}
 
 	if imageVersion.LessThan(requiredVersion) {
-		return fmt.Errorf(
+		return nil, fmt.Errorf(
 			"Image version is not compatible. (Current: %s, Required: >= %s)\n"+
 				"Please run 'flyctl image show' and update to the latest available version.",
 			imageVersion, requiredVersion.String())
 	}
 
+	return image, nil
+}
+
+func runPostgresConnect(cmdCtx *cmdctx.CmdContext) error {
+	ctx := cmdCtx.Command.Context()
+	client := cmdCtx.Client.API()
+
+	app, err := client.GetApp(ctx, cmdCtx.AppName)
+	if err != nil {
+		return fmt.Errorf("get app: %w", err)
+	}
+
+	// Validate image version to ensure it's compatible with this feature.
+	if _, err := checkPostgresImage(app); err != nil {
+		return err
+	}
+
 	agentclient, err := agent.Establish(ctx, cmdCtx.Client.API())
 	if err != nil {
 		return errors.Wrap(err, "can't establish agent")
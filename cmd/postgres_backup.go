@@ -0,0 +1,364 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+	"github.com/sammccord/flyctl/api"
+	"github.com/sammccord/flyctl/cmdctx"
+	"github.com/sammccord/flyctl/helpers"
+	"github.com/sammccord/flyctl/internal/pgbackup"
+	"github.com/sammccord/flyctl/pkg/agent"
+	"github.com/sammccord/flyctl/pkg/ssh"
+)
+
+// postgresSSH prepares an SSH connection to a postgres app. Commands connect to port 5432 on localhost, which is
+// the leader in HA clusters and postgres itself for standalone ones.
+func postgresSSH(cmdCtx *cmdctx.CmdContext) (*SSHParams, string, error) {
+	app, err := cmdCtx.Client.API().GetApp(cmdCtx.Command.Context(), cmdCtx.AppName)
+	if err != nil {
+		return nil, "", fmt.Errorf("get app: %w", err)
+	}
+
+	params, err := postgresSSHFor(cmdCtx, app)
+	if err != nil {
+		return nil, "", err
+	}
+	return params, fmt.Sprintf("%s.internal", cmdCtx.AppName), nil
+}
+
+// postgresSSHFor prepares SSH connections to the instances of app over the agent tunnel
+func postgresSSHFor(cmdCtx *cmdctx.CmdContext, app *api.App) (*SSHParams, error) {
+	ctx := cmdCtx.Command.Context()
+
+	agentclient, err := agent.Establish(ctx, cmdCtx.Client.API())
+	if err != nil {
+		return nil, errors.Wrap(err, "can't establish agent")
+	}
+
+	dialer, err := agentclient.Dialer(ctx, &app.Organization)
+	if err != nil {
+		return nil, fmt.Errorf("ssh: can't build tunnel for %s: %s\n", app.Organization.Slug, err)
+	}
+
+	return &SSHParams{
+		Ctx:    cmdCtx,
+		Org:    &app.Organization,
+		Dialer: dialer,
+		App:    app.Name,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}, nil
+}
+
+// postgresCommand runs a postgres client tool against a port on localhost. The password is written to a temporary
+// pgpass file rather than passed in the command, where process listings on the instance would show it.
+type postgresCommand struct {
+	database string
+	user     string
+	password string
+	cmd      string
+}
+
+// postgresCommandFor builds a command running tool as the --user and --password flags on the --database flag,
+// falling back to the postgres user and database and a password in PGPASSWORD
+func postgresCommandFor(cmdCtx *cmdctx.CmdContext, tool string, port int, args ...string) (*postgresCommand, error) {
+	database := cmdCtx.Config.GetString("database")
+	if database == "" {
+		database = "postgres"
+	}
+
+	user := cmdCtx.Config.GetString("user")
+	if user == "" {
+		user = "postgres"
+	}
+
+	password := cmdCtx.Config.GetString("password")
+	if password == "" {
+		password = os.Getenv("PGPASSWORD")
+	}
+	if password == "" {
+		return nil, fmt.Errorf("the password of %s is required, pass --password or set PGPASSWORD", user)
+	}
+	if strings.ContainsAny(password, "\r\n") {
+		return nil, fmt.Errorf("the password can't contain line breaks")
+	}
+
+	args = append(args, "--host=localhost", fmt.Sprintf("--port=%d", port), "--username="+user, "--dbname="+database)
+	for _, arg := range args {
+		// the command isn't run through a shell, so arguments can't be quoted
+		if strings.ContainsAny(arg, " \t\n\"'") {
+			return nil, fmt.Errorf("the database and user can't contain whitespace or quotes")
+		}
+	}
+
+	return &postgresCommand{
+		database: database,
+		user:     user,
+		password: password,
+		cmd:      tool + " " + strings.Join(args, " "),
+	}, nil
+}
+
+// Run runs the command over client. The pgpass file is created in a directory only root can read and removed
+// once the command exits.
+func (c *postgresCommand) Run(ctx context.Context, client *ssh.Client, stdin io.Reader, stdout, stderr io.Writer) error {
+	name, err := helpers.RandString(16)
+	if err != nil {
+		return err
+	}
+	dir := "/tmp/flyctl-pgpass-" + name
+	passfile := dir + "/pgpass"
+
+	if err := client.Run(ctx, "mkdir -m 700 "+dir, nil, io.Discard, stderr); err != nil {
+		return errors.Wrap(err, "create pgpass file")
+	}
+	defer client.Run(context.Background(), "rm -rf "+dir, nil, io.Discard, io.Discard)
+
+	if err := client.Run(ctx, "tee "+passfile, strings.NewReader(c.pgpass()), io.Discard, stderr); err != nil {
+		return errors.Wrap(err, "create pgpass file")
+	}
+	// libpq ignores pgpass files that group or others can read
+	if err := client.Run(ctx, "chmod 600 "+passfile, nil, io.Discard, stderr); err != nil {
+		return errors.Wrap(err, "create pgpass file")
+	}
+
+	return client.Run(ctx, fmt.Sprintf("env PGPASSFILE=%s %s", passfile, c.cmd), stdin, stdout, stderr)
+}
+
+// pgpass returns the pgpass entry matching the user on any host, port and database
+func (c *postgresCommand) pgpass() string {
+	escape := strings.NewReplacer(`\`, `\\`, `:`, `\:`)
+	return fmt.Sprintf("*:*:*:%s:%s\n", escape.Replace(c.user), escape.Replace(c.password))
+}
+
+// backupStore returns where backups at loc are kept, configuring S3 from the --s3-endpoint and --s3-region flags
+// and AWS environment variables
+func backupStore(cmdCtx *cmdctx.CmdContext, loc pgbackup.Location) (pgbackup.Store, error) {
+	if !loc.IsS3() {
+		return pgbackup.Files{}, nil
+	}
+	return pgbackup.NewS3FromEnv(cmdCtx.Config.GetString("s3-endpoint"), cmdCtx.Config.GetString("s3-region"))
+}
+
+type byteCounter int64
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
+
+type postgresBackup struct {
+	Database string
+	Location string
+	Checksum string
+	SHA256   string
+	Size     int64
+}
+
+func runPostgresBackup(cmdCtx *cmdctx.CmdContext) error {
+	ctx := cmdCtx.Command.Context()
+
+	level := cmdCtx.Config.GetInt("compression-level")
+	if level < 0 || level > 9 {
+		return fmt.Errorf("--compression-level must be between 0 and 9")
+	}
+
+	pgDump, err := postgresCommandFor(cmdCtx, "pg_dump", 5432, "--format=custom", fmt.Sprintf("--compress=%d", level))
+	if err != nil {
+		return err
+	}
+	database := pgDump.database
+
+	output := cmdCtx.Config.GetString("output")
+	if output == "" {
+		output = fmt.Sprintf("%s-%s-%s.dump", cmdCtx.AppName, database, time.Now().UTC().Format("20060102T150405Z"))
+	}
+	loc, err := pgbackup.ParseLocation(output)
+	if err != nil {
+		return err
+	}
+	store, err := backupStore(cmdCtx, loc)
+	if err != nil {
+		return err
+	}
+
+	params, addr, err := postgresSSH(cmdCtx)
+	if err != nil {
+		return err
+	}
+	client, err := sshClientFor(params, addr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	upload, err := store.Create(ctx, loc)
+	if err != nil {
+		return errors.Wrap(err, "create backup")
+	}
+
+	hash := sha256.New()
+	var size byteCounter
+
+	cmdCtx.Statusf("postgres", cmdctx.SINFO, "Backing up database %s of %s to %s\n", database, cmdCtx.AppName, loc)
+
+	if err := pgDump.Run(ctx, client, nil, io.MultiWriter(upload, hash, &size), os.Stderr); err != nil {
+		upload.Abort()
+		return errors.Wrap(err, "pg_dump failed")
+	}
+	if size == 0 {
+		upload.Abort()
+		return fmt.Errorf("pg_dump didn't write a backup")
+	}
+	if err := upload.Commit(); err != nil {
+		return errors.Wrap(err, "store backup")
+	}
+
+	sum := hash.Sum(nil)
+	checksum, err := store.Create(ctx, loc.Checksum())
+	if err != nil {
+		return errors.Wrap(err, "store checksum")
+	}
+	if _, err := checksum.Write(pgbackup.FormatChecksum(sum, loc.Name())); err != nil {
+		checksum.Abort()
+		return errors.Wrap(err, "store checksum")
+	}
+	if err := checksum.Commit(); err != nil {
+		return errors.Wrap(err, "store checksum")
+	}
+
+	backup := postgresBackup{
+		Database: database,
+		Location: loc.String(),
+		Checksum: loc.Checksum().String(),
+		SHA256:   hex.EncodeToString(sum),
+		Size:     int64(size),
+	}
+	if cmdCtx.OutputJSON() {
+		cmdCtx.WriteJSON(backup)
+		return nil
+	}
+
+	cmdCtx.Statusf("postgres", cmdctx.SDONE, "Backed up %s to %s\n", humanize.Bytes(uint64(size)), backup.Location)
+	cmdCtx.Statusf("postgres", cmdctx.SINFO, "SHA-256 %s written to %s\n", backup.SHA256, backup.Checksum)
+	return nil
+}
+
+// verifyBackup compares the backup at loc with the checksum stored next to it, which older or copied backups may
+// not have
+func verifyBackup(cmdCtx *cmdctx.CmdContext, store pgbackup.Store, loc pgbackup.Location) error {
+	ctx := cmdCtx.Command.Context()
+
+	r, err := store.Open(ctx, loc.Checksum())
+	if errors.Is(err, fs.ErrNotExist) {
+		cmdCtx.Statusf("postgres", cmdctx.SWARN, "No checksum found at %s, restoring without verifying the backup\n", loc.Checksum())
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "read checksum")
+	}
+	data, err := io.ReadAll(io.LimitReader(r, 4096))
+	r.Close()
+	if err != nil {
+		return errors.Wrap(err, "read checksum")
+	}
+	want, err := pgbackup.ParseChecksum(data)
+	if err != nil {
+		return err
+	}
+
+	cmdCtx.Statusf("postgres", cmdctx.SINFO, "Verifying %s\n", loc)
+
+	r, err = store.Open(ctx, loc)
+	if err != nil {
+		return errors.Wrap(err, "read backup")
+	}
+	defer r.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return errors.Wrap(err, "read backup")
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		return fmt.Errorf("%s is corrupt, its SHA-256 is %s but %s was recorded", loc, got, want)
+	}
+	return nil
+}
+
+func runPostgresRestore(cmdCtx *cmdctx.CmdContext) error {
+	ctx := cmdCtx.Command.Context()
+
+	args := []string{"--no-owner", "--exit-on-error"}
+	clean := cmdCtx.Config.GetBool("clean")
+	if clean {
+		args = append(args, "--clean", "--if-exists")
+	}
+	pgRestore, err := postgresCommandFor(cmdCtx, "pg_restore", 5432, args...)
+	if err != nil {
+		return err
+	}
+	database := pgRestore.database
+
+	from := cmdCtx.Config.GetString("from")
+	if from == "" {
+		return fmt.Errorf("pass the backup to restore with --from")
+	}
+	loc, err := pgbackup.ParseLocation(from)
+	if err != nil {
+		return err
+	}
+	store, err := backupStore(cmdCtx, loc)
+	if err != nil {
+		return err
+	}
+
+	if !cmdCtx.Config.GetBool("no-verify") {
+		if err := verifyBackup(cmdCtx, store, loc); err != nil {
+			return err
+		}
+	}
+
+	if clean && !cmdCtx.Config.GetBool("yes") {
+		if !confirm(fmt.Sprintf("Drop and replace the objects in database %s of %s with %s?", database, cmdCtx.AppName, loc)) {
+			return nil
+		}
+	}
+
+	r, err := store.Open(ctx, loc)
+	if err != nil {
+		return errors.Wrap(err, "read backup")
+	}
+	defer r.Close()
+	dump := bufio.NewReader(r)
+	if err := pgbackup.CheckHeader(dump); err != nil {
+		return errors.Wrapf(err, "can't restore %s", loc)
+	}
+
+	params, addr, err := postgresSSH(cmdCtx)
+	if err != nil {
+		return err
+	}
+	client, err := sshClientFor(params, addr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	cmdCtx.Statusf("postgres", cmdctx.SINFO, "Restoring %s into database %s of %s\n", loc, database, cmdCtx.AppName)
+
+	if err := pgRestore.Run(ctx, client, dump, os.Stdout, os.Stderr); err != nil {
+		return errors.Wrap(err, "pg_restore failed")
+	}
+
+	cmdCtx.Statusf("postgres", cmdctx.SDONE, "Restored %s into database %s\n", loc, database)
+	return nil
+}
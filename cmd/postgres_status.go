@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/dustin/go-humanize"
+	"github.com/logrusorgru/aurora"
+	"github.com/sammccord/flyctl/cmdctx"
+	"github.com/sammccord/flyctl/helpers"
+	"github.com/sammccord/flyctl/internal/pgstatus"
+)
+
+// postgresDataDir is where postgres apps mount the volume holding their data
+const postgresDataDir = "/data"
+
+func runPostgresStatus(cmdCtx *cmdctx.CmdContext) error {
+	ctx := cmdCtx.Command.Context()
+	client := cmdCtx.Client.API()
+
+	app, err := client.GetApp(ctx, cmdCtx.AppName)
+	if err != nil {
+		return fmt.Errorf("get app: %w", err)
+	}
+
+	image, err := checkPostgresImage(app)
+	if err != nil {
+		return err
+	}
+
+	appStatus, err := client.GetAppStatus(ctx, cmdCtx.AppName, false)
+	if err != nil {
+		return fmt.Errorf("get app status: %w", err)
+	}
+
+	// replicas of HA clusters are only reachable on 5433, 5432 is a proxy to the leader
+	port := 5432
+	if image.HA {
+		port = 5433
+	}
+	psql, err := postgresCommandFor(cmdCtx, "psql", port, "--no-psqlrc", "--quiet", "--tuples-only", "--no-align", "--field-separator-zero")
+	if err != nil {
+		return err
+	}
+
+	params, err := postgresSSHFor(cmdCtx, app)
+	if err != nil {
+		return err
+	}
+	params.DisableSpinner = true
+
+	status := &pgstatus.Cluster{
+		App:     app.Name,
+		Image:   app.ImageDetails.Repository,
+		Version: image.Version.String(),
+		HA:      image.HA,
+	}
+
+	var wg sync.WaitGroup
+	for _, alloc := range appStatus.Allocations {
+		if alloc.PrivateIP == "" {
+			continue
+		}
+		instance := &pgstatus.Instance{ID: alloc.IDShort, Region: alloc.Region, Address: alloc.PrivateIP}
+		status.Instances = append(status.Instances, instance)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := *params
+			if err := queryPostgresInstance(&p, psql, instance); err != nil {
+				instance.Role = "unknown"
+				instance.Error = err.Error()
+			}
+		}()
+	}
+
+	cmdCtx.Statusf("postgres", cmdctx.SINFO, "Querying %d instances of %s\n", len(status.Instances), app.Name)
+	wg.Wait()
+
+	leaders := pgstatus.SetReplicationLag(status.Instances)
+
+	if cmdCtx.OutputJSON() {
+		cmdCtx.WriteJSON(status)
+		return nil
+	}
+
+	flavor := "standalone"
+	if status.HA {
+		flavor = "highly available"
+	}
+	fmt.Fprintf(cmdCtx.Out, "Image: %s v%s (%s)\n\n", status.Image, status.Version, flavor)
+
+	table := helpers.MakeSimpleTable(cmdCtx.Out, []string{"Instance", "Region", "Role", "Postgres", "WAL position", "Lag", "Connections", "Disk"})
+	for _, instance := range status.Instances {
+		if instance.Error != "" {
+			table.Append([]string{instance.ID, instance.Region, aurora.Red(instance.Role).String(), "", "", "", "", ""})
+			continue
+		}
+
+		role := instance.Role
+		if role == "leader" {
+			role = aurora.Green(role).String()
+		}
+		lag := ""
+		if instance.LagBytes != nil {
+			lag = humanize.Bytes(uint64(*instance.LagBytes))
+		}
+		disk := ""
+		if instance.DiskSize > 0 {
+			disk = fmt.Sprintf("%s / %s (%d%%)", humanize.Bytes(instance.DiskUsed), humanize.Bytes(instance.DiskSize), instance.DiskUsed*100/instance.DiskSize)
+		}
+		table.Append([]string{
+			instance.ID,
+			instance.Region,
+			role,
+			instance.ServerVersion,
+			instance.WALPosition,
+			lag,
+			fmt.Sprintf("%d/%d", instance.Connections, instance.MaxConnections),
+			disk,
+		})
+	}
+	table.Render()
+
+	for _, instance := range status.Instances {
+		if instance.Error != "" {
+			cmdCtx.Statusf("postgres", cmdctx.SERROR, "%s: %s\n", instance.ID, instance.Error)
+		}
+	}
+	switch {
+	case len(status.Instances) == 0:
+		cmdCtx.Statusf("postgres", cmdctx.SWARN, "%s has no running instances\n", app.Name)
+	case leaders == 0:
+		cmdCtx.Statusf("postgres", cmdctx.SWARN, "No leader found, replication lag is unknown\n")
+	case leaders > 1:
+		cmdCtx.Statusf("postgres", cmdctx.SWARN, "%d instances are leaders, the cluster may be split\n", leaders)
+	}
+
+	return nil
+}
+
+// queryPostgresInstance fills in the status of an instance, running psql and df over a single SSH connection
+func queryPostgresInstance(p *SSHParams, psql *postgresCommand, instance *pgstatus.Instance) error {
+	ctx := p.Ctx.Command.Context()
+
+	client, err := sshClientFor(p, fmt.Sprintf("[%s]", instance.Address))
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var stdout, stderr bytes.Buffer
+	if err := psql.Run(ctx, client, strings.NewReader(pgstatus.Query), &stdout, &stderr); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("psql failed: %s", msg)
+		}
+		return fmt.Errorf("psql failed: %w", err)
+	}
+	if err := pgstatus.ParseRow(stdout.String(), instance); err != nil {
+		return err
+	}
+
+	stdout.Reset()
+	if err := client.Run(ctx, "df -P -k "+postgresDataDir, nil, &stdout, io.Discard); err == nil {
+		instance.DiskUsed, instance.DiskSize = pgstatus.ParseDiskUsage(stdout.String())
+	}
+
+	return nil
+}
@@ -49,7 +49,9 @@ func NewRootCmd(client *client.Client) *cobra.Command {
 	checkErr(err)
 
 	rootCmd.AddCommand(
+		newBuildCommand(client),
 		newBuildsCommand(client),
+		newBuiltinsCommand(client),
 		newCurlCommand(client),
 		newCertificatesCommand(client),
 		newConfigCommand(client),
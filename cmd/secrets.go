@@ -1,15 +1,22 @@
 package cmd
 
 import (
-	"errors"
+	"context"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/logrusorgru/aurora"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/sammccord/flyctl/api"
 	"github.com/sammccord/flyctl/cmdctx"
+	"github.com/sammccord/flyctl/flyctl"
 	"github.com/sammccord/flyctl/internal/client"
 	"github.com/sammccord/flyctl/internal/cmdutil"
+	"github.com/sammccord/flyctl/internal/secrets"
 
 	"github.com/sammccord/flyctl/docstrings"
 
@@ -39,13 +46,57 @@ func newSecretsCommand(client *client.Client) *Command {
 		Name:        "detach",
 		Description: "Return immediately instead of monitoring deployment progress",
 	})
+	set.AddBoolFlag(BoolFlagOpts{
+		Name:        "stage",
+		Description: "Record the change to apply later with secrets apply",
+	})
 
 	secretsImportStrings := docstrings.Get("secrets.import")
 	importCmd := BuildCommandKS(cmd, runImportSecrets, secretsImportStrings, client, requireSession, requireAppName)
+	importCmd.Command.Args = cobra.MaximumNArgs(1)
 	importCmd.AddBoolFlag(BoolFlagOpts{
 		Name:        "detach",
 		Description: "Return immediately instead of monitoring deployment progress",
 	})
+	importCmd.AddStringFlag(StringFlagOpts{
+		Name:        "format",
+		Description: "Format of the secrets: auto, dotenv, json or yaml",
+		Default:     secrets.FormatAuto,
+	})
+
+	secretsSyncStrings := docstrings.Get("secrets.sync")
+	syncCmd := BuildCommandKS(cmd, runSyncSecrets, secretsSyncStrings, client, requireSession, requireAppName)
+	syncCmd.Command.Args = cobra.ExactArgs(1)
+	syncCmd.AddBoolFlag(BoolFlagOpts{
+		Name:        "detach",
+		Description: "Return immediately instead of monitoring deployment progress",
+	})
+	syncCmd.AddStringFlag(StringFlagOpts{
+		Name:        "format",
+		Description: "Format of the secrets: auto, dotenv, json or yaml",
+		Default:     secrets.FormatAuto,
+	})
+	syncCmd.AddBoolFlag(BoolFlagOpts{
+		Name:        "prune",
+		Description: "Remove secrets that aren't in the file",
+	})
+	syncCmd.AddBoolFlag(BoolFlagOpts{
+		Name:        "dry-run",
+		Description: "Only show what would change",
+	})
+	syncCmd.AddBoolFlag(BoolFlagOpts{Name: "yes", Shorthand: "y", Description: "Accept all confirmations"})
+
+	secretsPullStrings := docstrings.Get("secrets.pull")
+	pull := BuildCommandKS(cmd, runPullSecrets, secretsPullStrings, client, requireSession, requireAppName)
+	pull.Command.Args = cobra.ExactArgs(1)
+	pull.AddStringFlag(StringFlagOpts{
+		Name:        "provider",
+		Description: "The secret manager to read from: vault, sops, op or age",
+	})
+	pull.AddBoolFlag(BoolFlagOpts{
+		Name:        "detach",
+		Description: "Return immediately instead of monitoring deployment progress",
+	})
 
 	secretsUnsetStrings := docstrings.Get("secrets.unset")
 	unset := BuildCommandKS(cmd, runSecretsUnset, secretsUnsetStrings, client, requireSession, requireAppName)
@@ -55,6 +106,24 @@ func newSecretsCommand(client *client.Client) *Command {
 		Name:        "detach",
 		Description: "Return immediately instead of monitoring deployment progress",
 	})
+	unset.AddBoolFlag(BoolFlagOpts{
+		Name:        "stage",
+		Description: "Record the change to apply later with secrets apply",
+	})
+
+	secretsPendingStrings := docstrings.Get("secrets.pending")
+	pending := BuildCommandKS(cmd, runPendingSecrets, secretsPendingStrings, client, requireAppName)
+	pending.AddBoolFlag(BoolFlagOpts{
+		Name:        "discard",
+		Description: "Discard the staged changes",
+	})
+
+	secretsApplyStrings := docstrings.Get("secrets.apply")
+	apply := BuildCommandKS(cmd, runApplySecrets, secretsApplyStrings, client, requireSession, requireAppName)
+	apply.AddBoolFlag(BoolFlagOpts{
+		Name:        "detach",
+		Description: "Return immediately instead of monitoring deployment progress",
+	})
 
 	return cmd
 }
@@ -73,17 +142,12 @@ func runListSecrets(cmdCtx *cmdctx.CmdContext) error {
 func runSetSecrets(cc *cmdctx.CmdContext) error {
 	ctx := cc.Command.Context()
 
-	app, err := cc.Client.API().GetApp(ctx, cc.AppName)
+	values, err := cmdutil.ParseKVStringsToMap(cc.Args)
 	if err != nil {
 		return err
 	}
 
-	secrets, err := cmdutil.ParseKVStringsToMap(cc.Args)
-	if err != nil {
-		return err
-	}
-
-	for k, v := range secrets {
+	for k, v := range values {
 		if v == "-" {
 			if !helpers.HasPipedStdin() {
 				return fmt.Errorf("Secret `%s` expects standard input but none provided", k)
@@ -92,19 +156,64 @@ func runSetSecrets(cc *cmdctx.CmdContext) error {
 			if err != nil {
 				return fmt.Errorf("Error reading stdin for '%s': %s", k, err)
 			}
-			secrets[k] = inval
+			values[k] = inval
 		}
 	}
 
-	if len(secrets) < 1 {
+	if len(values) < 1 {
 		return errors.New("requires at least one SECRET=VALUE pair")
 	}
 
-	release, err := cc.Client.API().SetSecrets(ctx, cc.AppName, secrets)
+	if cc.Config.GetBool("stage") {
+		return stageSecrets(cc, func(staged *secrets.Staged) {
+			for k, v := range values {
+				staged.SetValue(k, v)
+			}
+		})
+	}
+
+	app, err := cc.Client.API().GetApp(ctx, cc.AppName)
+	if err != nil {
+		return err
+	}
+
+	values, err = secrets.DefaultProviders().Resolve(ctx, values)
+	if err != nil {
+		return err
+	}
+
+	release, err := cc.Client.API().SetSecrets(ctx, cc.AppName, values)
 	if err != nil {
 		return err
 	}
 
+	return watchSecretsRelease(ctx, cc, app, release)
+}
+
+// readSecrets parses the secrets in the file at path, or stdin when it's empty or -, in the --format format,
+// and resolves the references to secret managers among them
+func readSecrets(cc *cmdctx.CmdContext, path string) (map[string]string, error) {
+	var data []byte
+	var err error
+	if path != "" && path != "-" {
+		data, err = os.ReadFile(path)
+	} else {
+		path = ""
+		data, err = io.ReadAll(cc.IO.In)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := secrets.Parse(path, data, cc.Config.GetString("format"))
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading secrets")
+	}
+	return secrets.DefaultProviders().Resolve(cc.Command.Context(), parsed)
+}
+
+// watchSecretsRelease follows the deployment of a release that changed secrets
+func watchSecretsRelease(ctx context.Context, cc *cmdctx.CmdContext, app *api.App, release *api.Release) error {
 	if !app.Deployed {
 		cc.Statusf("secrets", cmdctx.SINFO, "Secrets are staged for the first deployment\n")
 		return nil
@@ -118,107 +227,365 @@ func runSetSecrets(cc *cmdctx.CmdContext) error {
 func runImportSecrets(cc *cmdctx.CmdContext) error {
 	ctx := cc.Command.Context()
 
-	app, err := cc.Client.API().GetApp(ctx, cc.AppName)
+	path := ""
+	if len(cc.Args) > 0 {
+		path = cc.Args[0]
+	}
+	imported, err := readSecrets(cc, path)
 	if err != nil {
 		return err
 	}
+	if len(imported) < 1 {
+		return errors.New("requires at least one SECRET=VALUE pair")
+	}
 
-	secrets := make(map[string]string)
+	app, err := cc.Client.API().GetApp(ctx, cc.AppName)
+	if err != nil {
+		return err
+	}
 
-	secretsString, err := ioutil.ReadAll(os.Stdin)
+	cc.Statusf("secrets", cmdctx.SINFO, "Importing %d secrets: %s\n", len(imported), strings.Join(secrets.Keys(imported), ", "))
 
+	release, err := cc.Client.API().SetSecrets(ctx, cc.AppName, imported)
 	if err != nil {
 		return err
 	}
 
-	secretsArray := strings.Split(string(secretsString), "\n")
+	return watchSecretsRelease(ctx, cc, app, release)
+}
 
-	parsestate := 0
-	parsedkey := ""
-	var parsebuffer strings.Builder
+func runSyncSecrets(cc *cmdctx.CmdContext) error {
+	ctx := cc.Command.Context()
+	prune := cc.Config.GetBool("prune")
 
-	for _, line := range secretsArray {
-		switch parsestate {
-		case 0:
-			if line != "" {
-				parts := strings.SplitN(line, "=", 2)
-				if strings.HasPrefix(parts[1], "\"\"\"") {
-					// Switch to multiline
-					parsestate = 1
-					parsedkey = parts[0]
-					parsebuffer.WriteString(strings.TrimPrefix(parts[1], "\"\"\""))
-					parsebuffer.WriteString("\n")
-				} else {
-					if len(parts) != 2 {
-						return fmt.Errorf("Secrets must be provided as NAME=VALUE pairs (%s is invalid)", line)
-					}
-					key := parts[0]
-					value := parts[1]
-					secrets[key] = value
-				}
-			}
-		case 1:
-			if strings.HasSuffix(line, "\"\"\"") {
-				// End of multiline
-				parsebuffer.WriteString(strings.TrimSuffix(line, "\"\"\""))
-				secrets[parsedkey] = parsebuffer.String()
-				parsebuffer.Reset()
-				parsestate = 0
-				parsedkey = ""
-			} else {
-				if line != "" {
-					parsebuffer.WriteString(line)
-				}
-				parsebuffer.WriteString("\n")
-			}
+	local, err := readSecrets(cc, cc.Args[0])
+	if err != nil {
+		return err
+	}
+	// an empty file, or one read in the wrong format, would remove every secret
+	if prune && len(local) == 0 {
+		return fmt.Errorf("%s has no secrets, refusing to remove all secrets of %s with --prune", cc.Args[0], cc.AppName)
+	}
 
-		}
+	remote, err := cc.Client.API().GetAppSecrets(ctx, cc.AppName)
+	if err != nil {
+		return err
+	}
+	remoteDigests := map[string]string{}
+	for _, secret := range remote {
+		remoteDigests[secret.Name] = secret.Digest
+	}
 
+	diff := secrets.Compare(local, remoteDigests)
+
+	if cc.OutputJSON() {
+		cc.WriteJSON(diff)
+	} else {
+		printSecretsDiff(cc, diff, prune)
 	}
 
-	if len(secrets) < 1 {
-		return errors.New("requires at least one SECRET=VALUE pair")
+	if diff.Empty(prune) {
+		cc.Statusf("secrets", cmdctx.SINFO, "Secrets are in sync with %s\n", cc.Args[0])
+		return nil
+	}
+	if cc.Config.GetBool("dry-run") {
+		return nil
+	}
+
+	if prune && len(diff.Removed) > 0 && !cc.Config.GetBool("yes") {
+		if !cc.IO.IsStdinTTY() {
+			return fmt.Errorf("--prune would remove %d secrets, pass --yes to remove them when stdin isn't a terminal", len(diff.Removed))
+		}
+		if !confirm(fmt.Sprintf("Remove %d secrets from %s: %s?", len(diff.Removed), cc.AppName, strings.Join(diff.Removed, ", "))) {
+			return nil
+		}
 	}
-	fmt.Println(secrets)
 
-	release, err := cc.Client.API().SetSecrets(ctx, cc.AppName, secrets)
+	app, err := cc.Client.API().GetApp(ctx, cc.AppName)
 	if err != nil {
 		return err
 	}
 
-	if !app.Deployed {
-		cc.Statusf("secrets", cmdctx.SINFO, "Secrets are staged for the first deployment\n")
-		return nil
+	// Setting and removing secrets are separate mutations, so pruning as well takes a second release. Only the
+	// last one is watched, it holds both changes.
+	var release *api.Release
+	changed := map[string]string{}
+	for _, key := range append(append(diff.Added, diff.Changed...), diff.Unknown...) {
+		changed[key] = local[key]
+	}
+	if len(changed) > 0 {
+		release, err = cc.Client.API().SetSecrets(ctx, cc.AppName, changed)
+		if err != nil {
+			return err
+		}
+	}
+	if prune && len(diff.Removed) > 0 {
+		release, err = cc.Client.API().UnsetSecrets(ctx, cc.AppName, diff.Removed)
+		if err != nil {
+			return err
+		}
 	}
 
-	cc.Statusf("secrets", cmdctx.SINFO, "Release v%d created\n", release.Version)
+	return watchSecretsRelease(ctx, cc, app, release)
+}
 
-	return watchDeployment(ctx, cc)
+func printSecretsDiff(cc *cmdctx.CmdContext, diff secrets.Diff, prune bool) {
+	for _, key := range diff.Added {
+		fmt.Fprintf(cc.Out, "%s %s (added)\n", aurora.Green("+"), key)
+	}
+	for _, key := range diff.Changed {
+		fmt.Fprintf(cc.Out, "%s %s (changed)\n", aurora.Yellow("~"), key)
+	}
+	for _, key := range diff.Unknown {
+		fmt.Fprintf(cc.Out, "%s %s (unknown digest, set again)\n", aurora.Yellow("?"), key)
+	}
+	for _, key := range diff.Removed {
+		if prune {
+			fmt.Fprintf(cc.Out, "%s %s (removed)\n", aurora.Red("-"), key)
+		} else {
+			fmt.Fprintf(cc.Out, "  %s (not in the file, kept without --prune)\n", key)
+		}
+	}
+	if len(diff.Unchanged) > 0 {
+		fmt.Fprintf(cc.Out, "%d unchanged\n", len(diff.Unchanged))
+	}
 }
 
-func runSecretsUnset(cc *cmdctx.CmdContext) error {
+func runPullSecrets(cc *cmdctx.CmdContext) error {
 	ctx := cc.Command.Context()
 
+	providers := secrets.DefaultProviders()
+	name := cc.Config.GetString("provider")
+	if name == "" {
+		return fmt.Errorf("--provider is required, use one of %s", strings.Join(providers.Names(), ", "))
+	}
+	provider, err := providers.Get(name)
+	if err != nil {
+		return err
+	}
+
+	pulled, err := provider.List(ctx, cc.Args[0])
+	if err != nil {
+		return errors.Wrapf(err, "error reading %s from %s", cc.Args[0], name)
+	}
+	if len(pulled) < 1 {
+		return fmt.Errorf("no secrets found at %s", cc.Args[0])
+	}
+	for _, key := range secrets.Keys(pulled) {
+		if err := secrets.ValidateKey(key); err != nil {
+			return err
+		}
+	}
+
 	app, err := cc.Client.API().GetApp(ctx, cc.AppName)
 	if err != nil {
 		return err
 	}
 
+	cc.Statusf("secrets", cmdctx.SINFO, "Setting %d secrets from %s: %s\n", len(pulled), name, strings.Join(secrets.Keys(pulled), ", "))
+
+	release, err := cc.Client.API().SetSecrets(ctx, cc.AppName, pulled)
+	if err != nil {
+		return err
+	}
+
+	return watchSecretsRelease(ctx, cc, app, release)
+}
+
+func runSecretsUnset(cc *cmdctx.CmdContext) error {
+	ctx := cc.Command.Context()
+
 	if len(cc.Args) == 0 {
 		return errors.New("Requires at least one secret name")
 	}
 
+	if cc.Config.GetBool("stage") {
+		return stageSecrets(cc, func(staged *secrets.Staged) {
+			for _, key := range cc.Args {
+				staged.UnsetKey(key)
+			}
+		})
+	}
+
+	app, err := cc.Client.API().GetApp(ctx, cc.AppName)
+	if err != nil {
+		return err
+	}
+
 	release, err := cc.Client.API().UnsetSecrets(ctx, cc.AppName, cc.Args)
 	if err != nil {
 		return err
 	}
 
-	if !app.Deployed {
-		cc.Statusf("secrets", cmdctx.SINFO, "Secrets are staged for the first deployment\n")
+	return watchSecretsRelease(ctx, cc, app, release)
+}
+
+func stagedSecretsDir() string {
+	return filepath.Join(flyctl.ConfigDir(), "staged-secrets")
+}
+
+// stageSecrets records changes to the app's secrets without releasing them
+func stageSecrets(cc *cmdctx.CmdContext, change func(*secrets.Staged)) error {
+	staged, err := secrets.LoadStaged(stagedSecretsDir(), cc.AppName)
+	if err != nil {
+		return errors.Wrap(err, "error reading staged secrets")
+	}
+
+	change(staged)
+	if staged.Mixed() {
+		return errMixedStagedSecrets(cc.AppName)
+	}
+
+	if err := staged.Save(stagedSecretsDir(), cc.AppName); err != nil {
+		return errors.Wrap(err, "error saving staged secrets")
+	}
+
+	cc.Statusf("secrets", cmdctx.SINFO, "%d secret changes are staged for %s, release them with flyctl secrets apply\n", len(staged.Set)+len(staged.Unset), cc.AppName)
+	return nil
+}
+
+func runPendingSecrets(cc *cmdctx.CmdContext) error {
+	if cc.Config.GetBool("discard") {
+		if err := secrets.ClearStaged(stagedSecretsDir(), cc.AppName); err != nil {
+			return err
+		}
+		cc.Statusf("secrets", cmdctx.SINFO, "Discarded the staged secret changes of %s\n", cc.AppName)
 		return nil
 	}
 
-	cc.Statusf("secrets", cmdctx.SINFO, "Release v%d created\n", release.Version)
+	staged, err := secrets.LoadStaged(stagedSecretsDir(), cc.AppName)
+	if err != nil {
+		return errors.Wrap(err, "error reading staged secrets")
+	}
 
-	return watchDeployment(ctx, cc)
+	type pendingChange struct {
+		Name      string
+		Change    string
+		Reference string `json:",omitempty"`
+	}
+	changes := []pendingChange{}
+	for _, key := range secrets.Keys(staged.Set) {
+		change := pendingChange{Name: key, Change: "set"}
+		// references aren't secret, values are never shown
+		if value := staged.Set[key]; secrets.IsReference(value) {
+			change.Reference = value
+		}
+		changes = append(changes, change)
+	}
+	for _, key := range staged.Unset {
+		changes = append(changes, pendingChange{Name: key, Change: "unset"})
+	}
+
+	if cc.OutputJSON() {
+		cc.WriteJSON(changes)
+		return nil
+	}
+
+	if len(changes) == 0 {
+		fmt.Fprintf(cc.Out, "No secret changes are staged for %s\n", cc.AppName)
+		return nil
+	}
+
+	table := tablewriter.NewWriter(cc.Out)
+	table.SetAutoWrapText(false)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetNoWhiteSpace(true)
+	table.SetTablePadding(" ")
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetRowSeparator("")
+	table.SetHeader([]string{"Name", "Change", "Reference"})
+
+	for _, change := range changes {
+		table.Append([]string{change.Name, change.Change, change.Reference})
+	}
+
+	table.Render()
+
+	return nil
+}
+
+func runApplySecrets(cc *cmdctx.CmdContext) error {
+	ctx := cc.Command.Context()
+
+	app, err := cc.Client.API().GetApp(ctx, cc.AppName)
+	if err != nil {
+		return err
+	}
+
+	release, err := applyStagedSecrets(ctx, cc)
+	if err != nil {
+		return err
+	}
+	if release == nil {
+		cc.Statusf("secrets", cmdctx.SINFO, "No secret changes are staged for %s\n", cc.AppName)
+		return nil
+	}
+
+	return watchSecretsRelease(ctx, cc, app, release)
+}
+
+// stagedSecretChanges lists the secret changes staged for the app, like "set NAME" or "unset NAME"
+func stagedSecretChanges(appName string) ([]string, error) {
+	staged, err := secrets.LoadStaged(stagedSecretsDir(), appName)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading staged secrets")
+	}
+
+	changes := []string{}
+	for _, key := range secrets.Keys(staged.Set) {
+		changes = append(changes, "set "+key)
+	}
+	for _, key := range staged.Unset {
+		changes = append(changes, "unset "+key)
+	}
+	return changes, nil
+}
+
+func errStagedSecretsBlockDeploy(appName string, changes []string) error {
+	return fmt.Errorf("%d secret changes are staged for %s (%s), release them with `flyctl secrets apply` or drop them with `flyctl secrets pending --discard` before deploying", len(changes), appName, strings.Join(changes, ", "))
+}
+
+// errMixedStagedSecrets is returned when values and removals would be staged together. They take separate
+// mutations, and so separate releases, which staging is meant to avoid.
+func errMixedStagedSecrets(appName string) error {
+	return fmt.Errorf("secrets can't be set and removed in the same release, apply or discard the changes staged for %s first", appName)
+}
+
+// applyStagedSecrets releases the secret changes staged for the app with a single mutation, without watching
+// the deployment, and returns its release or nil when nothing was staged
+func applyStagedSecrets(ctx context.Context, cc *cmdctx.CmdContext) (*api.Release, error) {
+	staged, err := secrets.LoadStaged(stagedSecretsDir(), cc.AppName)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading staged secrets")
+	}
+	if staged.Empty() {
+		return nil, nil
+	}
+	if staged.Mixed() {
+		return nil, errMixedStagedSecrets(cc.AppName)
+	}
+
+	values, err := secrets.DefaultProviders().Resolve(ctx, staged.Set)
+	if err != nil {
+		return nil, err
+	}
+
+	var release *api.Release
+	if len(staged.Unset) > 0 {
+		release, err = cc.Client.API().UnsetSecrets(ctx, cc.AppName, staged.Unset)
+	} else {
+		release, err = cc.Client.API().SetSecrets(ctx, cc.AppName, values)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := secrets.ClearStaged(stagedSecretsDir(), cc.AppName); err != nil {
+		return nil, err
+	}
+
+	cc.Statusf("secrets", cmdctx.SINFO, "Applied %d staged secret changes\n", len(staged.Set)+len(staged.Unset))
+	return release, nil
 }
@@ -187,16 +187,38 @@ type SSHParams struct {
 }
 
 func sshConnect(p *SSHParams, addr string) error {
+	sshClient, err := sshClientFor(p, addr)
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	term := &ssh.Terminal{
+		Stdin:  p.Stdin,
+		Stdout: p.Stdout,
+		Stderr: p.Stderr,
+		Mode:   "xterm",
+	}
+
+	if err := sshClient.Shell(context.Background(), term, p.Cmd); err != nil {
+		return errors.Wrap(err, "ssh shell")
+	}
+
+	return nil
+}
+
+// sshClientFor connects to addr as root with a single use certificate
+func sshClientFor(p *SSHParams, addr string) (*ssh.Client, error) {
 	terminal.Debugf("Fetching certificate for %s\n", addr)
 
 	cert, err := singleUseSSHCertificate(p.Ctx, p.Org)
 	if err != nil {
-		return fmt.Errorf("create ssh certificate: %w (if you haven't created a key for your org yet, try `flyctl ssh establish`)", err)
+		return nil, fmt.Errorf("create ssh certificate: %w (if you haven't created a key for your org yet, try `flyctl ssh establish`)", err)
 	}
 
 	pk, err := parsePrivateKey(cert.Key)
 	if err != nil {
-		return errors.Wrap(err, "parse ssh certificate")
+		return nil, errors.Wrap(err, "parse ssh certificate")
 	}
 
 	pemkey := MarshalED25519PrivateKey(pk, "single-use certificate")
@@ -221,9 +243,8 @@ func sshConnect(p *SSHParams, addr string) error {
 	}
 
 	if err := sshClient.Connect(context.Background()); err != nil {
-		return errors.Wrap(err, "error connecting to SSH server")
+		return nil, errors.Wrap(err, "error connecting to SSH server")
 	}
-	defer sshClient.Close()
 
 	terminal.Debugf("Connection completed.\n", addr)
 
@@ -231,16 +252,5 @@ func sshConnect(p *SSHParams, addr string) error {
 		endSpin()
 	}
 
-	term := &ssh.Terminal{
-		Stdin:  p.Stdin,
-		Stdout: p.Stdout,
-		Stderr: p.Stderr,
-		Mode:   "xterm",
-	}
-
-	if err := sshClient.Shell(context.Background(), term, p.Cmd); err != nil {
-		return errors.Wrap(err, "ssh shell")
-	}
-
-	return nil
+	return sshClient, nil
 }
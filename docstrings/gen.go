@@ -148,6 +148,18 @@ max=int - maximum number of instances to be allocated from region pool.`,
 min=int - minimum number of instances to be allocated from region pool.
 max=int - maximum number of instances to be allocated from region pool.`,
 		}
+	case "build":
+		return KeyStrings{"build <command>", "Inspect builds",
+			`Commands that inspect what a deployment builds`,
+		}
+	case "build.context":
+		return KeyStrings{"context [<workingdirectory>]", "Report on the build context",
+			`Report on the build context that deployments upload from a working directory:
+the total and compressed size, the largest files and directories, which
+.dockerignore rules matched and exclusions worth adding for dependencies and
+build outputs. Use --dockerfile to report with the Dockerfile's own
+<Dockerfile>.dockerignore.`,
+		}
 	case "builds":
 		return KeyStrings{"builds", "Work with Fly builds",
 			`Fly builds are templates to make developing Fly applications easier.`,
@@ -162,12 +174,25 @@ max=int - maximum number of instances to be allocated from region pool.`,
 		}
 	case "builtins":
 		return KeyStrings{"builtins", "View and manage Flyctl deployment builtins",
-			`View and manage Flyctl deployment builtins.`,
+			`View and manage Flyctl deployment builtins.
+
+Besides the builtins shipped with flyctl, teams can define their own in
+a local directory or a git repository listed in fly.toml:
+
+  [build]
+    builtin = "go-service"
+    builtins = ["./builtins", "https://github.com/acme/fly-builtins#main"]
+
+Each builtin is a <name>.toml file, or a <name>/builtin.toml directory,
+with a name, description, details, settings and either an inline
+template or a dockerfile path to a templated Dockerfile. Later sources
+override earlier ones and the defaults with the same name.`,
 		}
 	case "builtins.list":
 		return KeyStrings{"list", "List available Flyctl deployment builtins",
-			`List available Flyctl deployment builtins and their
-descriptions.`,
+			`List available Flyctl deployment builtins, their
+descriptions and where they were loaded from, including those from
+the sources in fly.toml.`,
 		}
 	case "builtins.show":
 		return KeyStrings{"show [<builtin name>]", "Show details of a builtin's configuration",
@@ -180,6 +205,13 @@ the builtin "Dockerfile" with default settings and other information.`,
 the builtin "Dockerfile" with an apps settings included
 and other information.`,
 		}
+	case "builtins.validate":
+		return KeyStrings{"validate [<path>]", "Validate custom builtins",
+			`Check builtins render to a valid Dockerfile with their
+default settings. Validates the builtins in the given directory, file or
+git repository, or every builtin available to the app when no path is
+given. Exits with an error if any builtin is invalid.`,
+		}
 	case "certs":
 		return KeyStrings{"certs", "Manage certificates",
 			`Manages the certificates associated with a deployed application.
@@ -283,6 +315,80 @@ Use the --image/-i flag to specify a local or remote image to deploy.
 Use the --detach flag to return immediately from starting the deployment rather
 than monitoring the deployment progress.
 
+Use the --plan flag to build and push the image, then show the image, configuration,
+secrets, strategy, VM placement and release command changes without deploying.
+Save the plan with --plan-out <file> and deploy exactly that plan later with
+--apply-plan <file>. Secret changes staged with "flyctl secrets set --stage" are
+listed in the plan, and deploying is refused until they're applied.
+
+Use the --auto-rollback flag to redeploy the image and configuration of the
+previous successful release when the release command or the deployment fails.
+
+Use the --smoke <path> flag, or a [deploy.smoke_tests] section in fly.toml, to run
+HTTP checks against the app hostname once the deployment succeeds. Checks against
+the hostname go through the Fly proxy, which doesn't report which instance served
+them. Add --smoke-instances to check every instance over WireGuard, with each
+result attributed to its instance, and --smoke-rollback to redeploy the previous
+successful release when a check fails.
+
+Use the --events ndjson flag to write one JSON object per deployment event to
+stdout. Other output is written to stderr.
+
+Use the --git <url>#<ref>[:<subdir>] flag to build and deploy a clean checkout of
+a git repository instead of the working directory. fly.toml and --dockerfile are
+read from the checkout. The commit hash is recorded in the image's
+org.opencontainers.image.revision label, except for buildpacks builds, and in its
+tag unless --image-label is given.
+
+Use the --daemonless flag to build without Docker. The image is assembled from
+the base image and the files copied from the build context, so Dockerfiles that
+RUN commands still need a local or remote Docker daemon. Builds fall back to this
+when no daemon is available. Save the image with --image-out <dir>, or
+--image-out <file>.tar for a tarball.
+
+Use the --context-report flag to list the largest files and directories in the
+build context, its compressed size and the .dockerignore rules that matched
+before building. See also flyctl build context.
+
+A <Dockerfile>.dockerignore file next to the Dockerfile, like
+api.Dockerfile.dockerignore, is used instead of the .dockerignore in the working
+directory. Use the --build-context <name>=<path> flag to make another directory
+available to COPY --from=<name>, for example code shared between apps in a
+monorepo. Named contexts apply their own .dockerignore.
+
+Use the --build-secret id=<id>,src=<path> flag, or env=<var> instead of src, to
+make a file or environment variable available to RUN --mount=type=secret,id=<id>
+without storing it in the image. Use --ssh default to forward the SSH agent to
+RUN --mount=type=ssh, for example to clone private git repositories. Both require
+BuildKit.
+
+Use --cache-to type=registry to push the image's layer cache to the app's cache
+image in the Fly registry after building, and --cache-from type=registry to
+reuse it, so fresh remote builders and CI jobs don't start cold. Pass ref=<image>
+to use another image in the Fly registry. The cache is stored inline in the
+image, so it only covers the layers of the final stage.
+
+Images are built for linux/amd64, the platform of Fly VMs. Use the --platform flag,
+or platforms = ["linux/amd64", "linux/arm64"] in the [build] section of fly.toml,
+to build for other platforms. Building for more than one platform pushes an
+image per platform and a manifest list tagged with the deployment tag. Platforms
+without linux/amd64 need --build-only, which keeps the images in docker instead of
+pushing them. Deploying an --image built for another
+platform, like arm64 images built on Apple Silicon, fails before the release.
+
+Use --sbom spdx or --sbom cyclonedx to list the OS packages and npm, Python and
+Ruby packages installed in the image, and --provenance for a SLSA provenance
+statement recording the git commit, builder and build args. Both are attached to
+the pushed image as OCI referrers. Use --sbom-out <dir> to also save them locally.
+
+Use --scan-db <path> to scan the image for vulnerabilities before deploying. The
+path is an offline snapshot of an OSV database, like the all.zip exports from
+osv.dev, or a directory of OSV JSON files, so scans work without network access
+to a vulnerability service. Deployments are blocked by vulnerabilities at or
+above --scan-severity, high by default. Vulnerabilities without a known severity
+are reported but don't block. Accept a vulnerability with --allow <id>, using its
+OSV or CVE id.
+
 Use flyctl monitor to restart monitoring deployment progress`,
 		}
 	case "destroy":
@@ -416,7 +522,62 @@ Fly network`,
 		}
 	case "launch":
 		return KeyStrings{"launch", "Launch a new app",
-			`Create and configure a new app from source code or an image reference.`,
+			`Create and configure a new app from source code or an image reference.
+
+Source code is recognized by scanners, manifests with detection rules
+and the Dockerfile templates, port, secrets and release command to use.
+Besides the scanners shipped with flyctl, manifests are loaded from
+~/.fly/scanners and the app's .fly/scanners directory, replacing those
+with the same name. Only scanners in ~/.fly/scanners can set init_commands,
+so launching a cloned repository never runs commands it lists.
+A manifest is a .toml file like:
+
+  family = "Acme Service"
+  port = 4000
+  release_cmd = "bin/acme migrate"
+  templates = "acme"   # directory of files copied into the app
+
+  [[rules]]
+  file_exists = ["package.json"]
+
+  [[rules]]
+  dir_contains = "package.json"
+  patterns = ['"@acme/service"']
+
+All rules must pass, a rule passes if any of its files or patterns do.
+Rules can also check dir_exists and be inverted with not = true.
+Scanners are checked by priority, highest first; custom scanners
+default to 100, before the generic language scanners. Use
+--scanner-debug to see why each scanner did or didn't match.
+
+In a monorepo, apps are found in npm, yarn and pnpm workspaces, go.work
+or nested Go modules, Cargo workspaces and directories with a Dockerfile.
+Apps with their own Dockerfile can be launched together: each gets its own
+fly.toml in its directory and is built with the repository root as build
+context, so the Dockerfile path is relative to the root. Apps without one
+are listed but need a Dockerfile, or to be launched alone with --path.
+Use --apps to pick the directories without prompting.
+
+To launch without prompts, pass the answers with --from launch.yaml or
+--json-answers (inline JSON, or - to read it from stdin):
+
+  name: acme-api          # or generate_name: true
+  org: acme
+  region: iad
+  image: ""               # or dockerfile
+  copy_config: false      # when a fly.toml exists
+  overwrite: false        # files generated by scanners
+  secrets:
+    STRIPE_KEY: sk_live_x
+  postgres: true
+  deploy: true
+  apps: [services/api]    # monorepo apps, named after their package
+
+Answers are validated before anything is created and flags take
+precedence over them. A JSON summary of the created apps, secrets,
+volumes and Postgres clusters is printed on stdout, with progress on
+stderr. Without answers, launch fails instead of prompting when stdin
+isn't a terminal.`,
 		}
 	case "list":
 		return KeyStrings{"list", "Lists your Fly resources",
@@ -484,7 +645,9 @@ to all instances running in a specific region using the --region/-r flag.`,
 	case "monitor":
 		return KeyStrings{"monitor", "Monitor deployments",
 			`Monitor application deployments and other activities. Use --verbose/-v
-to get details of every instance . Control-C to stop output.`,
+to get details of every instance . Control-C to stop output.
+
+Use --events ndjson to write one JSON object per deployment event to stdout.`,
 		}
 	case "move":
 		return KeyStrings{"move [APPNAME]", "Move an app to another organization",
@@ -561,6 +724,23 @@ about the Fly platform.`,
 		return KeyStrings{"attach", "Attach a postgres cluster to an app",
 			`Attach a postgres cluster to an app`,
 		}
+	case "postgres.backup":
+		return KeyStrings{"backup [<postgres-app>]", "Back up a database to a local file or S3 bucket",
+			`Back up a database with pg_dump in custom format, streamed over SSH from
+the leader of the cluster to a local file or an S3-compatible bucket. The
+backup is written to --output, a path or s3://<bucket>/<key>, and defaults
+to <app>-<database>-<timestamp>.dump in the current directory.
+
+pg_dump compresses the backup with --compression-level. Its SHA-256 checksum
+is stored next to it as <output>.sha256, in the format of sha256sum.
+
+S3 credentials are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+AWS_SESSION_TOKEN. Services other than AWS, like R2, Tigris or MinIO, are
+used with --s3-endpoint or AWS_ENDPOINT_URL.
+
+Unlike volume snapshots, backups are portable and can be restored into any
+postgres cluster with 'flyctl postgres restore' or pg_restore.`,
+		}
 	case "postgres.connect":
 		return KeyStrings{"connect", "Connect to postgres cluster",
 			`Connect to postgres cluster`,
@@ -589,6 +769,28 @@ about the Fly platform.`,
 		return KeyStrings{"list", "list postgres clusters",
 			`list postgres clusters`,
 		}
+	case "postgres.restore":
+		return KeyStrings{"restore [<postgres-app>] --from <backup>", "Restore a backup into a database",
+			`Restore a backup made with 'flyctl postgres backup', or any pg_dump archive
+in custom format, streaming it over SSH into pg_restore on the leader of the
+cluster. The backup is read from --from, a path or s3://<bucket>/<key>.
+
+The backup is checked against the checksum stored next to it first, unless
+--no-verify is set. Objects are restored without their original owner. With
+--clean, objects in the target database are dropped before being recreated.`,
+		}
+	case "postgres.status":
+		return KeyStrings{"status [<postgres-app>]", "Show the health and replication status of a postgres cluster",
+			`Show the health and replication status of each instance of a postgres
+cluster, queried over SSH through the agent tunnel. For each instance it
+reports its role as leader or replica, its postgres version, WAL position,
+how far it lags behind the leader, client connections out of the maximum,
+and disk usage of the data volume.
+
+Replication lag is the difference between the WAL position of the leader
+and the position replayed by the replica. Instances are queried at slightly
+different times, so it's approximate.`,
+		}
 	case "postgres.users":
 		return KeyStrings{"users", "manage users in a cluster",
 			`manage users in a cluster`,
@@ -689,10 +891,28 @@ Secrets are provided to applications at runtime as ENV variables. Names are
 case sensitive and stored as-is, so ensure names are appropriate for
 the application and vm environment.`,
 		}
+	case "secrets.apply":
+		return KeyStrings{"apply", "Release the staged secret changes",
+			`Release the secret changes staged for the app in a single release,
+so the app is restarted once for all of them. Setting and removing secrets
+take separate releases, so they can't be staged together. Deploying is
+refused while changes are staged, deploy --plan lists them.`,
+		}
 	case "secrets.import":
-		return KeyStrings{"import [flags]", "Read secrets in name=value from stdin",
-			`Set one or more encrypted secrets for an application. Values
-are read from stdin as name=value`,
+		return KeyStrings{"import [flags] [path]", "Read secrets from a dotenv, JSON or YAML file or stdin",
+			`Set one or more encrypted secrets for an application from a
+file, or from stdin when no path or - is given.
+
+Secrets can be a dotenv file, a JSON object or a YAML mapping of names to
+values. The format is detected from the file extension or the content,
+or set with --format. In dotenv files, values can be single quoted to be
+taken literally, double quoted with \n, \t, \" and \$ escapes, or span
+several lines in quotes or in the older """triple quotes""". Comments
+and export prefixes are ignored.
+
+Names may only contain letters, digits and underscores. Errors name the
+line they occurred on, and values are never printed. Values can also be
+references to a secret manager, see "flyctl secrets pull".`,
 		}
 	case "secrets.list":
 		return KeyStrings{"list", "Lists the secrets available to the app",
@@ -700,6 +920,32 @@ are read from stdin as name=value`,
 secret's name, a digest of the its value and the time the secret was last set.
 The actual value of the secret is only available to the application.`,
 		}
+	case "secrets.pending":
+		return KeyStrings{"pending", "List the staged secret changes",
+			`List the secret changes staged for the app with set --stage
+and unset --stage. Values are never shown, only the references to secret
+managers. Use --discard to drop the staged changes.
+
+Staged changes are kept in ~/.fly/staged-secrets, readable only by you;
+references are stored as is and resolved when the changes are applied.`,
+		}
+	case "secrets.pull":
+		return KeyStrings{"pull --provider <provider> <path>", "Set the secrets kept in a secret manager",
+			`Set every field of a secret kept in a secret manager as a
+secret of the app, in a single release.
+
+Providers and their paths:
+  vault  a HashiCorp Vault KV path like secret/myapp, using VAULT_ADDR,
+         VAULT_TOKEN or ~/.vault-token and VAULT_NAMESPACE
+  sops   a SOPS encrypted dotenv, JSON or YAML file, decrypted with sops
+  op     a 1Password vault and item like Production/stripe, read through
+         the Connect server at OP_CONNECT_HOST with OP_CONNECT_TOKEN
+  age    an age encrypted dotenv, JSON or YAML file like .env.age,
+         decrypted with age and AGE_IDENTITY_FILE or ~/.config/age/keys.txt
+
+The same providers resolve references like ref+<provider>://<path>#<field>
+given to secrets set, import and sync.`,
+		}
 	case "secrets.set":
 		return KeyStrings{"set [flags] NAME=VALUE NAME=VALUE ...", "Set one or more encrypted secrets for an app",
 			`Set one or more encrypted secrets for an application.
@@ -708,12 +954,36 @@ Secrets are provided to application at runtime as ENV variables. Names are
 case sensitive and stored as-is, so ensure names are appropriate for
 the application and vm environment.
 
-Any value that equals "-" will be assigned from STDIN instead of args.`,
+Any value that equals "-" will be assigned from STDIN instead of args.
+
+Values like ref+vault://secret/myapp#password refer to a secret manager
+and are resolved by flyctl before they're set, so they never have to be
+pasted in a terminal. See "flyctl secrets pull" for the providers.
+
+With --stage the change is only recorded locally, to be released together
+with other staged changes by "flyctl secrets apply".`,
+		}
+	case "secrets.sync":
+		return KeyStrings{"sync [flags] <path>", "Sync the app's secrets with a local file",
+			`Make the app's secrets match a dotenv, JSON or YAML file.
+
+Secret values can't be read back, so the file is compared with the
+digests of the app's secrets: keys are added, changed when their digest
+differs, or removed with --prune. Keys whose digest isn't a SHA-256 can't
+be compared, they are shown as unknown and set again. Added and changed
+keys are set in a single release; removing keys with --prune takes a
+second one. Use --dry-run to only show the changes.
+
+Removing secrets with --prune asks for confirmation, pass --yes to skip
+it. A file without any secrets is refused with --prune.`,
 		}
 	case "secrets.unset":
 		return KeyStrings{"unset [flags] NAME NAME ...", "Remove encrypted secrets from an app",
 			`Remove encrypted secrets from the application. Unsetting a
-secret removes its availability to the application.`,
+secret removes its availability to the application.
+
+With --stage the removal is only recorded locally, see
+"flyctl secrets apply".`,
 		}
 	case "ssh":
 		return KeyStrings{"ssh <command>", "Commands that manage SSH credentials",
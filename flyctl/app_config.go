@@ -26,6 +26,7 @@ const (
 type AppConfig struct {
 	AppName    string
 	Build      *Build
+	SmokeTests *SmokeTests
 	Definition map[string]interface{}
 }
 
@@ -36,11 +37,34 @@ type Build struct {
 	// Or...
 	Builtin  string
 	Settings map[string]interface{}
+	// Builtins are directories or git repositories with custom builtins, which override the default ones
+	Builtins []string
 	// Or...
 	Image string
 	// Or...
 	Dockerfile        string
 	DockerBuildTarget string
+	// Context is the build context relative to fly.toml, the Dockerfile is then relative to it
+	Context string
+	// Platforms the image is built for, as os/arch[/variant]
+	Platforms []string
+}
+
+// SmokeTests are HTTP checks run after a successful deployment, configured in [deploy.smoke_tests]
+type SmokeTests struct {
+	// Instances runs the checks against each instance over WireGuard instead of the app hostname
+	Instances bool
+	Rollback  bool
+	Checks    []SmokeCheck
+}
+
+type SmokeCheck struct {
+	Path       string
+	Method     string
+	Port       int
+	Status     int
+	Body       string
+	MaxLatency time.Duration
 }
 
 func NewAppConfig() *AppConfig {
@@ -101,6 +125,14 @@ func (ac *AppConfig) Dockerfile() string {
 	return ac.Build.Dockerfile
 }
 
+// BuildContext returns the build context directory relative to fly.toml, empty for the working directory
+func (ac *AppConfig) BuildContext() string {
+	if ac.Build == nil {
+		return ""
+	}
+	return ac.Build.Context
+}
+
 func (ac *AppConfig) DockerBuildTarget() string {
 	if ac.Build == nil {
 		return ""
@@ -108,6 +140,21 @@ func (ac *AppConfig) DockerBuildTarget() string {
 	return ac.Build.DockerBuildTarget
 }
 
+func (ac *AppConfig) BuildPlatforms() []string {
+	if ac.Build == nil {
+		return nil
+	}
+	return ac.Build.Platforms
+}
+
+// BuiltinSources returns the directories and git repositories custom builtins are loaded from
+func (ac *AppConfig) BuiltinSources() []string {
+	if ac.Build == nil {
+		return nil
+	}
+	return ac.Build.Builtins
+}
+
 func (ac *AppConfig) WriteTo(w io.Writer, format ConfigFormat) error {
 	switch format {
 	case TOMLFormat:
@@ -161,6 +208,16 @@ func (ac *AppConfig) unmarshalNativeMap(data map[string]interface{}) error {
 			case "builtin":
 				b.Builtin = fmt.Sprint(v)
 				insection = true
+			case "builtins":
+				switch sources := v.(type) {
+				case []interface{}:
+					for _, source := range sources {
+						b.Builtins = append(b.Builtins, fmt.Sprint(source))
+					}
+				case string:
+					b.Builtins = append(b.Builtins, sources)
+				}
+				insection = true
 			case "settings":
 				if settingsMap, ok := v.(map[string]interface{}); ok {
 					for settingK, settingV := range settingsMap {
@@ -177,24 +234,142 @@ func (ac *AppConfig) unmarshalNativeMap(data map[string]interface{}) error {
 			case "build_target":
 				b.DockerBuildTarget = fmt.Sprint(v)
 				insection = true
+			case "context":
+				b.Context = fmt.Sprint(v)
+				insection = true
+			case "platforms":
+				if platformSlice, ok := v.([]interface{}); ok {
+					for _, platform := range platformSlice {
+						b.Platforms = append(b.Platforms, fmt.Sprint(platform))
+					}
+				}
+				insection = true
 			default:
 				if !insection {
 					b.Args[k] = fmt.Sprint(v)
 				}
 			}
 		}
-		if b.Builder != "" || b.Builtin != "" || b.Image != "" || b.Dockerfile != "" || len(b.Args) > 0 {
+		if b.Builder != "" || b.Builtin != "" || b.Image != "" || b.Dockerfile != "" || len(b.Args) > 0 || len(b.Platforms) > 0 || len(b.Builtins) > 0 || b.Context != "" {
 			ac.Build = &b
 		}
 	}
 
 	delete(data, "build")
 
+	if deployConfig, ok := (data["deploy"]).(map[string]interface{}); ok {
+		if smokeConfig, ok := (deployConfig["smoke_tests"]).(map[string]interface{}); ok {
+			smokeTests, err := parseSmokeTests(smokeConfig)
+			if err != nil {
+				return err
+			}
+			ac.SmokeTests = smokeTests
+		}
+		delete(deployConfig, "smoke_tests")
+		if len(deployConfig) == 0 {
+			delete(data, "deploy")
+		}
+	}
+
 	ac.Definition = data
 
 	return nil
 }
 
+func parseSmokeTests(data map[string]interface{}) (*SmokeTests, error) {
+	st := SmokeTests{}
+	for k, v := range data {
+		switch k {
+		case "instances":
+			st.Instances, _ = v.(bool)
+		case "rollback":
+			st.Rollback, _ = v.(bool)
+		case "checks":
+			checks, ok := v.([]map[string]interface{})
+			if !ok {
+				return nil, errors.New("deploy.smoke_tests.checks must be an array of tables")
+			}
+			for _, c := range checks {
+				check, err := parseSmokeCheck(c)
+				if err != nil {
+					return nil, err
+				}
+				st.Checks = append(st.Checks, check)
+			}
+		default:
+			return nil, fmt.Errorf("unknown deploy.smoke_tests setting %q", k)
+		}
+	}
+	return &st, nil
+}
+
+func parseSmokeCheck(data map[string]interface{}) (SmokeCheck, error) {
+	c := SmokeCheck{}
+	for k, v := range data {
+		switch k {
+		case "path":
+			c.Path = fmt.Sprint(v)
+		case "method":
+			c.Method = fmt.Sprint(v)
+		case "port":
+			port, ok := v.(int64)
+			if !ok {
+				return c, fmt.Errorf("smoke check port must be a number, got %v", v)
+			}
+			c.Port = int(port)
+		case "status":
+			status, ok := v.(int64)
+			if !ok {
+				return c, fmt.Errorf("smoke check status must be a number, got %v", v)
+			}
+			c.Status = int(status)
+		case "body":
+			c.Body = fmt.Sprint(v)
+		case "max_latency":
+			d, err := time.ParseDuration(fmt.Sprint(v))
+			if err != nil {
+				return c, fmt.Errorf("invalid smoke check max_latency: %w", err)
+			}
+			c.MaxLatency = d
+		default:
+			return c, fmt.Errorf("unknown smoke check setting %q", k)
+		}
+	}
+	if c.Path == "" {
+		return c, errors.New("smoke check is missing a path")
+	}
+	return c, nil
+}
+
+func (st *SmokeTests) toNativeMap() map[string]interface{} {
+	checks := []map[string]interface{}{}
+	for _, c := range st.Checks {
+		check := map[string]interface{}{"path": c.Path}
+		if c.Method != "" {
+			check["method"] = c.Method
+		}
+		if c.Port != 0 {
+			check["port"] = c.Port
+		}
+		if c.Status != 0 {
+			check["status"] = c.Status
+		}
+		if c.Body != "" {
+			check["body"] = c.Body
+		}
+		if c.MaxLatency != 0 {
+			check["max_latency"] = c.MaxLatency.String()
+		}
+		checks = append(checks, check)
+	}
+
+	return map[string]interface{}{
+		"instances": st.Instances,
+		"rollback":  st.Rollback,
+		"checks":    checks,
+	}
+}
+
 func (ac AppConfig) marshalTOML(w io.Writer) error {
 	encoder := toml.NewEncoder(w)
 
@@ -227,15 +402,40 @@ func (ac AppConfig) marshalTOML(w io.Writer) error {
 				buildData["settings"] = ac.Build.Settings
 			}
 		}
+		if len(ac.Build.Builtins) > 0 {
+			buildData["builtins"] = ac.Build.Builtins
+		}
 		if ac.Build.Image != "" {
 			buildData["image"] = ac.Build.Image
 		}
 		if ac.Build.Dockerfile != "" {
 			buildData["dockerfile"] = ac.Build.Dockerfile
 		}
+		if ac.Build.Context != "" {
+			buildData["context"] = ac.Build.Context
+		}
+		if len(ac.Build.Platforms) > 0 {
+			buildData["platforms"] = ac.Build.Platforms
+		}
 		rawData["build"] = buildData
 	}
 
+	if ac.SmokeTests != nil {
+		deployData := map[string]interface{}{}
+		switch deploy := ac.Definition["deploy"].(type) {
+		case map[string]interface{}:
+			for k, v := range deploy {
+				deployData[k] = v
+			}
+		case map[string]string:
+			for k, v := range deploy {
+				deployData[k] = v
+			}
+		}
+		deployData["smoke_tests"] = ac.SmokeTests.toNativeMap()
+		rawData["deploy"] = deployData
+	}
+
 	if len(ac.Definition) > 0 {
 		// roundtrip through json encoder to convert float64 numbers to json.Number, otherwise numbers are floats in toml
 		var buf bytes.Buffer
@@ -1,7 +1,9 @@
 package flyctl
 
 import (
+	"bytes"
 	"testing"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/stretchr/testify/assert"
@@ -35,6 +37,36 @@ func TestLoadTOMLAppConfigWithDockerfile(t *testing.T) {
 	assert.Equal(t, p.Build.Dockerfile, "./Dockerfile")
 }
 
+func TestLoadTOMLAppConfigWithPlatforms(t *testing.T) {
+	path := "./testdata/platforms.toml"
+	p, err := LoadAppConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"linux/amd64", "linux/arm64"}, p.BuildPlatforms())
+}
+
+func TestLoadTOMLAppConfigWithBuiltinSources(t *testing.T) {
+	path := "./testdata/builtins.toml"
+	p, err := LoadAppConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"./builtins", "https://github.com/example/fly-builtins#main"}, p.BuiltinSources())
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, p.WriteTo(buf, TOMLFormat))
+	assert.Contains(t, buf.String(), "builtins = [")
+}
+
+func TestLoadTOMLAppConfigWithBuildContext(t *testing.T) {
+	path := "./testdata/build-context.toml"
+	p, err := LoadAppConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "../..", p.BuildContext())
+	assert.Equal(t, "services/api/Dockerfile", p.Dockerfile())
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, p.WriteTo(buf, TOMLFormat))
+	assert.Contains(t, buf.String(), `context = "../.."`)
+}
+
 func TestLoadTOMLAppConfigWithBuilderNameAndArgs(t *testing.T) {
 	path := "./testdata/build-with-args.toml"
 	p, err := LoadAppConfig(path)
@@ -54,3 +86,19 @@ func TestLoadTOMLAppConfigWithServices(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, p.Definition, rawData)
 }
+
+func TestLoadTOMLAppConfigWithSmokeTests(t *testing.T) {
+	path := "./testdata/smoke-tests.toml"
+	p, err := LoadAppConfig(path)
+	assert.NoError(t, err)
+
+	assert.Equal(t, &SmokeTests{
+		Instances: true,
+		Rollback:  true,
+		Checks: []SmokeCheck{
+			{Path: "/healthz", Status: 200, Body: "^ok$", MaxLatency: 500 * time.Millisecond},
+			{Path: "/api/version", Port: 9090},
+		},
+	}, p.SmokeTests)
+	assert.Equal(t, map[string]interface{}{"release_command": "bin/migrate"}, p.Definition["deploy"])
+}
@@ -0,0 +1,355 @@
+// Package attest describes built images: the packages installed in them, as SPDX or CycloneDX
+// software bills of materials, and how they were built, as SLSA provenance
+package attest
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+)
+
+// Package types, as used in package URLs
+const (
+	TypeDeb  = "deb"
+	TypeApk  = "apk"
+	TypeNpm  = "npm"
+	TypePyPI = "pypi"
+	TypeGem  = "gem"
+)
+
+// Package is a package installed in an image
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Type    string `json:"type"`
+	Arch    string `json:"arch,omitempty"`
+	// Source is the source package OS packages were built from, which vulnerability databases refer to
+	Source   string   `json:"source,omitempty"`
+	Licenses []string `json:"licenses,omitempty"`
+	// PURL is the package URL, https://github.com/package-url/purl-spec
+	PURL string `json:"purl"`
+	// Path is the file in the image the package was found in
+	Path string `json:"path"`
+}
+
+// Distro is the Linux distribution of an image, from /etc/os-release
+type Distro struct {
+	ID        string `json:"id"`
+	VersionID string `json:"version_id"`
+}
+
+// Inventory is everything installed in an image that package managers know about
+type Inventory struct {
+	Distro   Distro    `json:"distro"`
+	Packages []Package `json:"packages"`
+}
+
+// maxManifestSize skips files too large to be package metadata
+const maxManifestSize = 64 << 20
+
+var gemspecRegexp = regexp.MustCompile(`^(.+)-([0-9][^-]*)\.gemspec$`)
+
+// ScanImage reads the installed packages from the image's filesystem
+func ScanImage(img v1.Image) (*Inventory, error) {
+	fs := mutate.Extract(img)
+	defer fs.Close()
+
+	return Scan(fs)
+}
+
+// Scan reads the installed packages from a tarball of a flattened image filesystem
+func Scan(r io.Reader) (*Inventory, error) {
+	var (
+		files  = map[string][]byte{}
+		tr     = tar.NewReader(r)
+		wanted = []string{}
+	)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := path.Clean("/" + header.Name)
+		if header.Typeflag != tar.TypeReg || header.Size > maxManifestSize || !isPackageMetadata(name) {
+			continue
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[name] = data
+		wanted = append(wanted, name)
+	}
+
+	inv := &Inventory{Distro: parseOSRelease(files)}
+	sort.Strings(wanted)
+
+	for _, name := range wanted {
+		data := files[name]
+
+		var pkgs []Package
+		switch {
+		case name == "/var/lib/dpkg/status":
+			pkgs = parseDpkgStatus(data)
+		case name == "/lib/apk/db/installed":
+			pkgs = parseApkInstalled(data)
+		case path.Base(name) == "package.json":
+			pkgs = parsePackageJSON(data)
+		case path.Base(name) == "METADATA":
+			pkgs = parsePythonMetadata(data)
+		case strings.HasSuffix(name, ".gemspec"):
+			if m := gemspecRegexp.FindStringSubmatch(path.Base(name)); m != nil {
+				pkgs = []Package{{Name: m[1], Version: m[2], Type: TypeGem}}
+			}
+		}
+
+		for _, pkg := range pkgs {
+			pkg.Path = name
+			pkg.PURL = purl(pkg, inv.Distro)
+			inv.Packages = append(inv.Packages, pkg)
+		}
+	}
+
+	inv.Packages = dedupe(inv.Packages)
+
+	return inv, nil
+}
+
+func isPackageMetadata(name string) bool {
+	switch name {
+	case "/etc/os-release", "/usr/lib/os-release", "/var/lib/dpkg/status", "/lib/apk/db/installed":
+		return true
+	}
+
+	dir, base := path.Split(name)
+	switch {
+	case base == "package.json":
+		// only installed modules, not the app's own package.json
+		parent := path.Dir(path.Clean(dir))
+		if strings.HasPrefix(path.Base(parent), "@") {
+			parent = path.Dir(parent)
+		}
+		return path.Base(parent) == "node_modules"
+	case base == "METADATA":
+		return strings.HasSuffix(path.Clean(dir), ".dist-info")
+	case strings.HasSuffix(base, ".gemspec"):
+		return path.Base(path.Clean(dir)) == "specifications"
+	}
+
+	return false
+}
+
+func parseOSRelease(files map[string][]byte) Distro {
+	data, ok := files["/etc/os-release"]
+	if !ok {
+		data = files["/usr/lib/os-release"]
+	}
+
+	var distro Distro
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value := strings.Trim(parts[1], `"'`)
+		switch parts[0] {
+		case "ID":
+			distro.ID = value
+		case "VERSION_ID":
+			distro.VersionID = value
+		}
+	}
+
+	return distro
+}
+
+// parseStanzas splits the blank line separated records used by dpkg and apk into fields.
+// Continuation lines, which start with a space, are dropped.
+func parseStanzas(data []byte, separator string) []map[string]string {
+	stanzas := []map[string]string{}
+	current := map[string]string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), maxManifestSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			if len(current) > 0 {
+				stanzas = append(stanzas, current)
+				current = map[string]string{}
+			}
+			continue
+		}
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		if parts := strings.SplitN(line, separator, 2); len(parts) == 2 {
+			current[parts[0]] = strings.TrimSpace(parts[1])
+		}
+	}
+	if len(current) > 0 {
+		stanzas = append(stanzas, current)
+	}
+
+	return stanzas
+}
+
+func parseDpkgStatus(data []byte) []Package {
+	pkgs := []Package{}
+	for _, fields := range parseStanzas(data, ":") {
+		if !strings.HasSuffix(fields["Status"], " installed") || fields["Package"] == "" {
+			continue
+		}
+		pkgs = append(pkgs, Package{
+			Name:    fields["Package"],
+			Version: fields["Version"],
+			Type:    TypeDeb,
+			Arch:    fields["Architecture"],
+			// Source is omitted when it's the same as the package, and may have a version in parentheses
+			Source: strings.TrimSpace(strings.SplitN(fields["Source"], "(", 2)[0]),
+		})
+	}
+	return pkgs
+}
+
+func parseApkInstalled(data []byte) []Package {
+	pkgs := []Package{}
+	for _, fields := range parseStanzas(data, ":") {
+		if fields["P"] == "" {
+			continue
+		}
+		pkg := Package{
+			Name:    fields["P"],
+			Version: fields["V"],
+			Type:    TypeApk,
+			Arch:    fields["A"],
+			Source:  fields["o"],
+		}
+		if license := fields["L"]; license != "" {
+			pkg.Licenses = []string{license}
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs
+}
+
+func parsePackageJSON(data []byte) []Package {
+	var manifest struct {
+		Name    string          `json:"name"`
+		Version string          `json:"version"`
+		License json.RawMessage `json:"license"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil || manifest.Name == "" || manifest.Version == "" {
+		return nil
+	}
+
+	pkg := Package{Name: manifest.Name, Version: manifest.Version, Type: TypeNpm}
+
+	// license is usually an SPDX expression, but older packages use {"type": "MIT"}
+	var license string
+	if err := json.Unmarshal(manifest.License, &license); err != nil {
+		var legacy struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(manifest.License, &legacy) == nil {
+			license = legacy.Type
+		}
+	}
+	if license != "" {
+		pkg.Licenses = []string{license}
+	}
+
+	return []Package{pkg}
+}
+
+func parsePythonMetadata(data []byte) []Package {
+	// the headers end at the first blank line, the long description follows
+	if i := bytes.Index(data, []byte("\n\n")); i >= 0 {
+		data = data[:i]
+	}
+
+	fields := parseStanzas(data, ":")
+	if len(fields) == 0 || fields[0]["Name"] == "" {
+		return nil
+	}
+
+	pkg := Package{Name: fields[0]["Name"], Version: fields[0]["Version"], Type: TypePyPI}
+	if license := fields[0]["License"]; license != "" && license != "UNKNOWN" {
+		pkg.Licenses = []string{license}
+	}
+
+	return []Package{pkg}
+}
+
+func purl(pkg Package, distro Distro) string {
+	name := pkg.Name
+	qualifiers := url.Values{}
+
+	switch pkg.Type {
+	case TypeDeb, TypeApk:
+		namespace := distro.ID
+		if namespace == "" {
+			namespace = map[string]string{TypeDeb: "debian", TypeApk: "alpine"}[pkg.Type]
+		}
+		name = namespace + "/" + url.PathEscape(name)
+		if pkg.Arch != "" {
+			qualifiers.Set("arch", pkg.Arch)
+		}
+		if distro.ID != "" && distro.VersionID != "" {
+			qualifiers.Set("distro", distro.ID+"-"+distro.VersionID)
+		}
+	case TypeNpm:
+		// scoped packages keep the slash between the scope and name
+		if parts := strings.SplitN(name, "/", 2); len(parts) == 2 {
+			name = url.PathEscape(parts[0]) + "/" + url.PathEscape(parts[1])
+		} else {
+			name = url.PathEscape(name)
+		}
+	case TypePyPI:
+		name = url.PathEscape(strings.ReplaceAll(strings.ToLower(name), "_", "-"))
+	default:
+		name = url.PathEscape(name)
+	}
+
+	s := fmt.Sprintf("pkg:%s/%s@%s", pkg.Type, name, url.PathEscape(pkg.Version))
+	if len(qualifiers) > 0 {
+		s += "?" + qualifiers.Encode()
+	}
+	return s
+}
+
+// dedupe drops packages installed more than once, like npm modules in several node_modules directories,
+// and sorts the rest by package URL
+func dedupe(pkgs []Package) []Package {
+	seen := map[string]bool{}
+	out := []Package{}
+	for _, pkg := range pkgs {
+		if seen[pkg.PURL] {
+			continue
+		}
+		seen[pkg.PURL] = true
+		out = append(out, pkg)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].PURL < out[j].PURL })
+
+	return out
+}
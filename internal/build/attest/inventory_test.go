@@ -0,0 +1,103 @@
+package attest
+
+import (
+	"archive/tar"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func imageFS(t *testing.T, files map[string]string) *bytes.Buffer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return &buf
+}
+
+func TestScan(t *testing.T) {
+	fs := imageFS(t, map[string]string{
+		"etc/os-release": "NAME=\"Debian GNU/Linux\"\nID=debian\nVERSION_ID=\"11\"\n",
+		"var/lib/dpkg/status": `Package: libc6
+Source: glibc (2.31-13)
+Status: install ok installed
+Architecture: amd64
+Version: 2.31-13+deb11u2
+Description: GNU C Library
+ continuation line: ignored
+
+Package: removed
+Status: deinstall ok config-files
+Version: 1.0
+
+Package: tzdata
+Status: install ok installed
+Architecture: all
+Version: 2021a-1+deb11u1
+`,
+		"app/node_modules/express/package.json":                       `{"name": "express", "version": "4.17.1", "license": "MIT"}`,
+		"app/node_modules/@babel/core/package.json":                   `{"name": "@babel/core", "version": "7.15.0", "license": {"type": "MIT"}}`,
+		"app/node_modules/a/node_modules/express/package.json":        `{"name": "express", "version": "4.17.1"}`,
+		"app/node_modules/express/lib/package.json":                   `{"name": "not-a-module", "version": "1.0.0"}`,
+		"app/package.json":                                            `{"name": "my-app", "version": "1.0.0"}`,
+		"usr/lib/python3/dist-packages/Django-3.2.dist-info/METADATA": "Metadata-Version: 2.1\nName: Django\nVersion: 3.2\nLicense: BSD-3-Clause\n\nDescription\nName: not-a-header\n",
+		"usr/local/bundle/specifications/rack-2.2.3.gemspec":          "",
+		"usr/local/bundle/gems/rack-2.2.3/rack.gemspec":               "",
+	})
+
+	inv, err := Scan(fs)
+	require.NoError(t, err)
+
+	assert.Equal(t, Distro{ID: "debian", VersionID: "11"}, inv.Distro)
+
+	purls := []string{}
+	for _, pkg := range inv.Packages {
+		purls = append(purls, pkg.PURL)
+	}
+	assert.Equal(t, []string{
+		"pkg:deb/debian/libc6@2.31-13+deb11u2?arch=amd64&distro=debian-11",
+		"pkg:deb/debian/tzdata@2021a-1+deb11u1?arch=all&distro=debian-11",
+		"pkg:gem/rack@2.2.3",
+		"pkg:npm/@babel/core@7.15.0",
+		"pkg:npm/express@4.17.1",
+		"pkg:pypi/django@3.2",
+	}, purls)
+
+	assert.Equal(t, []string{"MIT"}, inv.Packages[3].Licenses)
+	assert.Equal(t, []string{"BSD-3-Clause"}, inv.Packages[5].Licenses)
+	assert.Equal(t, "/var/lib/dpkg/status", inv.Packages[0].Path)
+	assert.Equal(t, "glibc", inv.Packages[0].Source)
+	assert.Equal(t, "", inv.Packages[1].Source)
+}
+
+func TestScanAlpine(t *testing.T) {
+	fs := imageFS(t, map[string]string{
+		"etc/os-release": "ID=alpine\nVERSION_ID=3.14.2\n",
+		"lib/apk/db/installed": `C:Q1abc=
+P:musl
+V:1.2.2-r3
+o:musl
+A:x86_64
+L:MIT
+
+P:busybox
+V:1.33.1-r3
+A:x86_64
+L:GPL-2.0-only
+`,
+	})
+
+	inv, err := Scan(fs)
+	require.NoError(t, err)
+	require.Len(t, inv.Packages, 2)
+
+	assert.Equal(t, "pkg:apk/alpine/busybox@1.33.1-r3?arch=x86_64&distro=alpine-3.14.2", inv.Packages[0].PURL)
+	assert.Equal(t, []string{"MIT"}, inv.Packages[1].Licenses)
+	assert.Equal(t, "musl", inv.Packages[1].Source)
+}
@@ -0,0 +1,173 @@
+package attest
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	statementType       = "https://in-toto.io/Statement/v1"
+	provenancePredicate = "https://slsa.dev/provenance/v1"
+	builderID           = "https://fly.io/flyctl"
+	buildTypePrefix     = "https://fly.io/flyctl/build/"
+)
+
+// ProvenanceFileName is the name provenance statements are saved with
+const ProvenanceFileName = "provenance.intoto.json"
+
+// Build is how an image was built
+type Build struct {
+	// Builder is the strategy that built the image: Dockerfile, Buildpacks, Builtin or Daemonless
+	Builder    string
+	Dockerfile string
+	Target     string
+	Buildpacks []string
+	Builtin    string
+	BuildArgs  map[string]string
+	Platforms  []string
+	// Git is the commit the image was built from, nil when the source isn't a git working tree
+	Git        *GitSource
+	StartedOn  time.Time
+	FinishedOn time.Time
+}
+
+// GitSource is a commit, and the repository it's from when known
+type GitSource struct {
+	Commit string
+	URL    string
+	Dirty  bool
+}
+
+type statement struct {
+	Type          string          `json:"_type"`
+	Subject       []statementItem `json:"subject"`
+	PredicateType string          `json:"predicateType"`
+	Predicate     provenance      `json:"predicate"`
+}
+
+type statementItem struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+type provenance struct {
+	BuildDefinition buildDefinition `json:"buildDefinition"`
+	RunDetails      runDetails      `json:"runDetails"`
+}
+
+type buildDefinition struct {
+	BuildType            string                 `json:"buildType"`
+	ExternalParameters   map[string]interface{} `json:"externalParameters"`
+	ResolvedDependencies []resourceDescriptor   `json:"resolvedDependencies,omitempty"`
+}
+
+type resourceDescriptor struct {
+	URI         string            `json:"uri"`
+	Digest      map[string]string `json:"digest"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type runDetails struct {
+	Builder  builder  `json:"builder"`
+	Metadata metadata `json:"metadata"`
+}
+
+type builder struct {
+	ID      string            `json:"id"`
+	Version map[string]string `json:"version"`
+}
+
+type metadata struct {
+	StartedOn  string `json:"startedOn"`
+	FinishedOn string `json:"finishedOn"`
+}
+
+// NewProvenance renders an in-toto statement with SLSA provenance for subject
+func NewProvenance(subject Subject, build Build, tool Tool) ([]byte, error) {
+	algorithm, digest := splitDigest(subject.Digest)
+
+	params := map[string]interface{}{
+		"builder": strings.ToLower(build.Builder),
+	}
+	if build.Dockerfile != "" {
+		params["dockerfile"] = build.Dockerfile
+	}
+	if build.Target != "" {
+		params["target"] = build.Target
+	}
+	if len(build.Buildpacks) > 0 {
+		params["buildpacks"] = build.Buildpacks
+	}
+	if build.Builtin != "" {
+		params["builtin"] = build.Builtin
+	}
+	if len(build.BuildArgs) > 0 {
+		params["buildArgs"] = build.BuildArgs
+	}
+	if len(build.Platforms) > 0 {
+		platforms := append([]string{}, build.Platforms...)
+		sort.Strings(platforms)
+		params["platforms"] = platforms
+	}
+
+	doc := statement{
+		Type: statementType,
+		Subject: []statementItem{{
+			Name:   subject.Name,
+			Digest: map[string]string{algorithm: digest},
+		}},
+		PredicateType: provenancePredicate,
+		Predicate: provenance{
+			BuildDefinition: buildDefinition{
+				BuildType:          buildTypePrefix + buildType(build.Builder) + "/v1",
+				ExternalParameters: params,
+			},
+			RunDetails: runDetails{
+				Builder: builder{
+					ID:      builderID,
+					Version: map[string]string{tool.Name: tool.Version},
+				},
+				Metadata: metadata{
+					StartedOn:  build.StartedOn.UTC().Format(time.RFC3339),
+					FinishedOn: build.FinishedOn.UTC().Format(time.RFC3339),
+				},
+			},
+		},
+	}
+
+	if git := build.Git; git != nil {
+		source := resourceDescriptor{
+			URI:    "git+" + git.URL,
+			Digest: map[string]string{"gitCommit": git.Commit},
+		}
+		if git.URL == "" {
+			source.URI = "git"
+		}
+		if git.Dirty {
+			source.Annotations = map[string]string{"dirty": "true"}
+		}
+		doc.Predicate.BuildDefinition.ResolvedDependencies = []resourceDescriptor{source}
+		params["source"] = source.URI
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// buildType groups builders by their input, the daemonless builder reads Dockerfiles too
+func buildType(builder string) string {
+	switch b := strings.ToLower(builder); b {
+	case "daemonless":
+		return "dockerfile"
+	default:
+		return b
+	}
+}
+
+func splitDigest(d string) (string, string) {
+	if parts := strings.SplitN(d, ":", 2); len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return "sha256", d
+}
@@ -0,0 +1,41 @@
+package attest
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProvenance(t *testing.T) {
+	data, err := NewProvenance(testSubject, Build{
+		Builder:    "Daemonless",
+		Dockerfile: "Dockerfile",
+		BuildArgs:  map[string]string{"NODE_ENV": "production"},
+		Git:        &GitSource{Commit: "abc123", URL: "https://github.com/superfly/my-app.git", Dirty: true},
+		StartedOn:  time.Unix(0, 0),
+		FinishedOn: time.Unix(60, 0),
+	}, testTool)
+	require.NoError(t, err)
+
+	var doc statement
+	require.NoError(t, json.Unmarshal(data, &doc))
+
+	assert.Equal(t, "https://in-toto.io/Statement/v1", doc.Type)
+	assert.Equal(t, []statementItem{{Name: "registry.fly.io/my-app", Digest: map[string]string{"sha256": "0123456789abcdef"}}}, doc.Subject)
+
+	def := doc.Predicate.BuildDefinition
+	assert.Equal(t, "https://fly.io/flyctl/build/dockerfile/v1", def.BuildType)
+	assert.Equal(t, "daemonless", def.ExternalParameters["builder"])
+	assert.Equal(t, map[string]interface{}{"NODE_ENV": "production"}, def.ExternalParameters["buildArgs"])
+	assert.Equal(t, []resourceDescriptor{{
+		URI:         "git+https://github.com/superfly/my-app.git",
+		Digest:      map[string]string{"gitCommit": "abc123"},
+		Annotations: map[string]string{"dirty": "true"},
+	}}, def.ResolvedDependencies)
+
+	assert.Equal(t, "1970-01-01T00:01:00Z", doc.Predicate.RunDetails.Metadata.FinishedOn)
+	assert.Equal(t, map[string]string{"flyctl": "1.2.3"}, doc.Predicate.RunDetails.Builder.Version)
+}
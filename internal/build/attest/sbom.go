@@ -0,0 +1,294 @@
+package attest
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// SBOM formats
+const (
+	FormatSPDX      = "spdx"
+	FormatCycloneDX = "cyclonedx"
+)
+
+// Media types of the documents, used as the artifact type of OCI referrers
+const (
+	MediaTypeSPDX       = "application/spdx+json"
+	MediaTypeCycloneDX  = "application/vnd.cyclonedx+json"
+	MediaTypeProvenance = "application/vnd.in-toto+json"
+)
+
+// Subject is the image a document describes
+type Subject struct {
+	// Name is the image repository, like registry.fly.io/my-app
+	Name string
+	// Tag is the tag the image was pushed or saved with
+	Tag string
+	// Digest is the digest of the image manifest, or the image ID of images that weren't pushed
+	Digest string
+	// Platform is the os/arch of the image the packages were read from
+	Platform string
+}
+
+// Tool identifies flyctl as the creator of documents
+type Tool struct {
+	Name    string
+	Version string
+}
+
+// ParseFormat checks format is an SBOM format, accepting the cdx abbreviation
+func ParseFormat(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case FormatSPDX:
+		return FormatSPDX, nil
+	case FormatCycloneDX, "cdx":
+		return FormatCycloneDX, nil
+	}
+	return "", fmt.Errorf("unsupported SBOM format %q, use spdx or cyclonedx", format)
+}
+
+// MediaType returns the media type of SBOMs in format
+func MediaType(format string) string {
+	if format == FormatCycloneDX {
+		return MediaTypeCycloneDX
+	}
+	return MediaTypeSPDX
+}
+
+// FileName returns the name SBOMs in format are saved with
+func FileName(format string) string {
+	if format == FormatCycloneDX {
+		return "sbom.cdx.json"
+	}
+	return "sbom.spdx.json"
+}
+
+// NewSBOM renders the inventory of subject in format
+func NewSBOM(format string, subject Subject, inv *Inventory, tool Tool, created time.Time) ([]byte, error) {
+	var doc interface{}
+	switch format {
+	case FormatSPDX:
+		doc = newSPDX(subject, inv, tool, created)
+	case FormatCycloneDX:
+		doc = newCycloneDX(subject, inv, tool, created)
+	default:
+		return nil, fmt.Errorf("unsupported SBOM format %q", format)
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// spdxLicenseRegexp matches single license identifiers, anything else isn't a valid SPDX expression
+var spdxLicenseRegexp = regexp.MustCompile(`^[A-Za-z0-9.+-]+$`)
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string            `json:"name"`
+	SPDXID           string            `json:"SPDXID"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	CopyrightText    string            `json:"copyrightText"`
+	SourceInfo       string            `json:"sourceInfo,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+func newSPDX(subject Subject, inv *Inventory, tool Tool, created time.Time) spdxDocument {
+	const imageID = "SPDXRef-Image"
+
+	doc := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              subject.Tag,
+		DocumentNamespace: fmt.Sprintf("https://fly.io/spdx/%s/%s", subject.Name, strings.Replace(subject.Digest, ":", "-", 1)),
+		CreationInfo: spdxCreationInfo{
+			Created:  created.UTC().Format(time.RFC3339),
+			Creators: []string{fmt.Sprintf("Tool: %s-%s", tool.Name, tool.Version)},
+		},
+		Packages: []spdxPackage{{
+			Name:             subject.Name,
+			SPDXID:           imageID,
+			VersionInfo:      subject.Digest,
+			DownloadLocation: "NOASSERTION",
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "NOASSERTION",
+			CopyrightText:    "NOASSERTION",
+			ExternalRefs: []spdxExternalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  ociPURL(subject),
+			}},
+		}},
+		Relationships: []spdxRelationship{{
+			SPDXElementID:      "SPDXRef-DOCUMENT",
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: imageID,
+		}},
+	}
+
+	for i, pkg := range inv.Packages {
+		id := fmt.Sprintf("SPDXRef-Package-%d", i+1)
+
+		declared := "NOASSERTION"
+		if len(pkg.Licenses) == 1 && spdxLicenseRegexp.MatchString(pkg.Licenses[0]) {
+			declared = pkg.Licenses[0]
+		}
+
+		doc.Packages = append(doc.Packages, spdxPackage{
+			Name:             pkg.Name,
+			SPDXID:           id,
+			VersionInfo:      pkg.Version,
+			DownloadLocation: "NOASSERTION",
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  declared,
+			CopyrightText:    "NOASSERTION",
+			SourceInfo:       "found in " + pkg.Path,
+			ExternalRefs: []spdxExternalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  pkg.PURL,
+			}},
+		})
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SPDXElementID:      imageID,
+			RelationshipType:   "CONTAINS",
+			RelatedSPDXElement: id,
+		})
+	}
+
+	return doc
+}
+
+type cdxDocument struct {
+	BOMFormat   string         `json:"bomFormat"`
+	SpecVersion string         `json:"specVersion"`
+	Version     int            `json:"version"`
+	Metadata    cdxMetadata    `json:"metadata"`
+	Components  []cdxComponent `json:"components"`
+}
+
+type cdxMetadata struct {
+	Timestamp string       `json:"timestamp"`
+	Tools     cdxTools     `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	Type       string        `json:"type"`
+	BOMRef     string        `json:"bom-ref,omitempty"`
+	Name       string        `json:"name"`
+	Version    string        `json:"version,omitempty"`
+	PURL       string        `json:"purl,omitempty"`
+	Licenses   []cdxLicense  `json:"licenses,omitempty"`
+	Properties []cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxLicense struct {
+	License cdxLicenseName `json:"license"`
+}
+
+type cdxLicenseName struct {
+	Name string `json:"name"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func newCycloneDX(subject Subject, inv *Inventory, tool Tool, created time.Time) cdxDocument {
+	doc := cdxDocument{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.5",
+		Version:     1,
+		Metadata: cdxMetadata{
+			Timestamp: created.UTC().Format(time.RFC3339),
+			Tools: cdxTools{Components: []cdxComponent{{
+				Type:    "application",
+				Name:    tool.Name,
+				Version: tool.Version,
+			}}},
+			Component: cdxComponent{
+				Type:    "container",
+				BOMRef:  ociPURL(subject),
+				Name:    subject.Name,
+				Version: subject.Digest,
+				PURL:    ociPURL(subject),
+			},
+		},
+		Components: []cdxComponent{},
+	}
+
+	if inv.Distro.ID != "" {
+		doc.Components = append(doc.Components, cdxComponent{
+			Type:    "operating-system",
+			BOMRef:  "os:" + inv.Distro.ID,
+			Name:    inv.Distro.ID,
+			Version: inv.Distro.VersionID,
+		})
+	}
+
+	for _, pkg := range inv.Packages {
+		component := cdxComponent{
+			Type:       "library",
+			BOMRef:     pkg.PURL,
+			Name:       pkg.Name,
+			Version:    pkg.Version,
+			PURL:       pkg.PURL,
+			Properties: []cdxProperty{{Name: "fly:path", Value: pkg.Path}},
+		}
+		for _, license := range pkg.Licenses {
+			component.Licenses = append(component.Licenses, cdxLicense{License: cdxLicenseName{Name: license}})
+		}
+		doc.Components = append(doc.Components, component)
+	}
+
+	return doc
+}
+
+// ociPURL is the package URL of the image itself
+func ociPURL(subject Subject) string {
+	name := subject.Name[strings.LastIndex(subject.Name, "/")+1:]
+	s := fmt.Sprintf("pkg:oci/%s@%s?repository_url=%s", name, strings.Replace(subject.Digest, ":", "%3A", 1), subject.Name)
+	if parts := strings.SplitN(subject.Platform, "/", 2); len(parts) == 2 {
+		s += "&arch=" + strings.ReplaceAll(parts[1], "/", "%2F")
+	}
+	return s
+}
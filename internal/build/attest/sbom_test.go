@@ -0,0 +1,73 @@
+package attest
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testSubject = Subject{
+		Name:     "registry.fly.io/my-app",
+		Tag:      "registry.fly.io/my-app:deployment-123",
+		Digest:   "sha256:0123456789abcdef",
+		Platform: "linux/amd64",
+	}
+	testTool      = Tool{Name: "flyctl", Version: "1.2.3"}
+	testInventory = &Inventory{
+		Distro: Distro{ID: "debian", VersionID: "11"},
+		Packages: []Package{
+			{Name: "libc6", Version: "2.31", Type: TypeDeb, PURL: "pkg:deb/debian/libc6@2.31", Path: "/var/lib/dpkg/status"},
+			{Name: "express", Version: "4.17.1", Type: TypeNpm, Licenses: []string{"MIT"}, PURL: "pkg:npm/express@4.17.1", Path: "/app/node_modules/express/package.json"},
+		},
+	}
+)
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("SPDX")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatSPDX, format)
+
+	format, err = ParseFormat("cdx")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatCycloneDX, format)
+
+	_, err = ParseFormat("syft")
+	assert.Error(t, err)
+}
+
+func TestNewSBOMSPDX(t *testing.T) {
+	data, err := NewSBOM(FormatSPDX, testSubject, testInventory, testTool, time.Unix(0, 0))
+	require.NoError(t, err)
+
+	var doc spdxDocument
+	require.NoError(t, json.Unmarshal(data, &doc))
+
+	assert.Equal(t, "SPDX-2.3", doc.SPDXVersion)
+	assert.Equal(t, "https://fly.io/spdx/registry.fly.io/my-app/sha256-0123456789abcdef", doc.DocumentNamespace)
+	assert.Equal(t, []string{"Tool: flyctl-1.2.3"}, doc.CreationInfo.Creators)
+	require.Len(t, doc.Packages, 3)
+	assert.Equal(t, "pkg:oci/my-app@sha256%3A0123456789abcdef?repository_url=registry.fly.io/my-app&arch=amd64", doc.Packages[0].ExternalRefs[0].ReferenceLocator)
+	assert.Equal(t, "NOASSERTION", doc.Packages[1].LicenseDeclared)
+	assert.Equal(t, "MIT", doc.Packages[2].LicenseDeclared)
+	assert.Equal(t, spdxRelationship{"SPDXRef-Image", "CONTAINS", "SPDXRef-Package-2"}, doc.Relationships[2])
+}
+
+func TestNewSBOMCycloneDX(t *testing.T) {
+	data, err := NewSBOM(FormatCycloneDX, testSubject, testInventory, testTool, time.Unix(0, 0))
+	require.NoError(t, err)
+
+	var doc cdxDocument
+	require.NoError(t, json.Unmarshal(data, &doc))
+
+	assert.Equal(t, "CycloneDX", doc.BOMFormat)
+	assert.Equal(t, "container", doc.Metadata.Component.Type)
+	assert.Equal(t, "1970-01-01T00:00:00Z", doc.Metadata.Timestamp)
+	require.Len(t, doc.Components, 3)
+	assert.Equal(t, "operating-system", doc.Components[0].Type)
+	assert.Equal(t, "pkg:npm/express@4.17.1", doc.Components[2].PURL)
+	assert.Equal(t, "MIT", doc.Components[2].Licenses[0].License.Name)
+}
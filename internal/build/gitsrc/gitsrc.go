@@ -0,0 +1,217 @@
+// Package gitsrc checks out a git ref into a temporary directory so an app can be built from a clean tree
+package gitsrc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	neturl "net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/cli/safeexec"
+)
+
+// Source is a git repository, the ref to check out and the directory within it to build from
+type Source struct {
+	URL    string
+	Ref    string
+	Subdir string
+}
+
+// ParseSource parses <url>#<ref>[:<subdir>], the same syntax docker uses for git build contexts.
+// The ref defaults to HEAD.
+func ParseSource(spec string) (Source, error) {
+	src := Source{URL: spec, Ref: "HEAD"}
+
+	if i := strings.LastIndex(spec, "#"); i >= 0 {
+		src.URL = spec[:i]
+		fragment := spec[i+1:]
+		if j := strings.Index(fragment, ":"); j >= 0 {
+			src.Subdir = fragment[j+1:]
+			fragment = fragment[:j]
+		}
+		if fragment != "" {
+			src.Ref = fragment
+		}
+	}
+
+	if src.URL == "" {
+		return src, fmt.Errorf("invalid git source %q: missing repository url", spec)
+	}
+
+	if src.Subdir != "" {
+		subdir := filepath.Clean(filepath.FromSlash(src.Subdir))
+		if filepath.IsAbs(subdir) || subdir == ".." || strings.HasPrefix(subdir, ".."+string(filepath.Separator)) {
+			return src, fmt.Errorf("invalid git source %q: subdirectory must be inside the repository", spec)
+		}
+		src.Subdir = subdir
+	}
+
+	return src, nil
+}
+
+// Checkout is a detached checkout of a Source
+type Checkout struct {
+	// Root is the top of the temporary checkout
+	Root string
+	// Dir is the directory to build from, Root joined with the source's subdirectory
+	Dir string
+	// SHA is the full commit hash that was checked out
+	SHA string
+}
+
+// ShortSHA returns the first 12 characters of the commit hash
+func (c *Checkout) ShortSHA() string {
+	if len(c.SHA) > 12 {
+		return c.SHA[:12]
+	}
+	return c.SHA
+}
+
+// Remove deletes the temporary checkout
+func (c *Checkout) Remove() error {
+	return os.RemoveAll(c.Root)
+}
+
+// Clone fetches the source's ref into a new temporary directory and checks it out.
+// Only the requested commit is fetched when the remote allows it, otherwise the full history is fetched.
+func Clone(ctx context.Context, src Source) (*Checkout, error) {
+	gitPath, err := safeexec.LookPath("git")
+	if err != nil {
+		return nil, fmt.Errorf("git is required to deploy from a git repository: %w", err)
+	}
+
+	url := src.URL
+	if info, err := os.Stat(url); err == nil && info.IsDir() {
+		// local paths are resolved before git runs from inside the checkout
+		if url, err = filepath.Abs(url); err != nil {
+			return nil, err
+		}
+	}
+
+	root, err := os.MkdirTemp("", "flyctl-git-")
+	if err != nil {
+		return nil, err
+	}
+	checkout := &Checkout{Root: root, Dir: filepath.Join(root, src.Subdir)}
+
+	git := func(args ...string) (string, error) {
+		return runGit(ctx, gitPath, root, args...)
+	}
+
+	sha, err := fetch(git, url, src.Ref)
+	if err == nil {
+		_, err = git("checkout", "--quiet", "--detach", sha)
+	}
+	if err != nil {
+		checkout.Remove()
+		return nil, err
+	}
+	checkout.SHA = sha
+
+	// record where the commit came from, so the checkout describes itself like a clone
+	if _, err := git("remote", "add", "origin", url); err != nil {
+		checkout.Remove()
+		return nil, err
+	}
+
+	if info, err := os.Stat(checkout.Dir); err != nil || !info.IsDir() {
+		checkout.Remove()
+		return nil, fmt.Errorf("directory %s not found in %s at %s", src.Subdir, src.URL, src.Ref)
+	}
+
+	return checkout, nil
+}
+
+// Commit is the commit checked out in a working tree
+type Commit struct {
+	SHA string
+	// URL is the origin remote without credentials, empty when there isn't one
+	URL string
+	// Dirty is set when tracked files have uncommitted changes
+	Dirty bool
+}
+
+// Head describes the commit checked out in dir, which may be anywhere inside a working tree
+func Head(ctx context.Context, dir string) (*Commit, error) {
+	gitPath, err := safeexec.LookPath("git")
+	if err != nil {
+		return nil, err
+	}
+
+	git := func(args ...string) (string, error) {
+		return runGit(ctx, gitPath, dir, args...)
+	}
+
+	sha, err := git("rev-parse", "--verify", "HEAD^{commit}")
+	if err != nil {
+		return nil, err
+	}
+	commit := &Commit{SHA: sha}
+
+	if url, err := git("config", "--get", "remote.origin.url"); err == nil {
+		commit.URL = redactURL(url)
+	}
+
+	status, err := git("status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return nil, err
+	}
+	commit.Dirty = status != ""
+
+	return commit, nil
+}
+
+// redactURL drops credentials from http(s) remotes, which often embed tokens
+func redactURL(remote string) string {
+	u, err := neturl.Parse(remote)
+	if err != nil || u.User == nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return remote
+	}
+	u.User = nil
+	return u.String()
+}
+
+func fetch(git func(args ...string) (string, error), url, ref string) (string, error) {
+	if _, err := git("init", "--quiet"); err != nil {
+		return "", err
+	}
+
+	if _, err := git("fetch", "--quiet", "--depth", "1", url, ref); err == nil {
+		return git("rev-parse", "--verify", "FETCH_HEAD^{commit}")
+	}
+
+	// shallow fetches of abbreviated commit hashes aren't supported, so fall back to fetching everything
+	if _, err := git("fetch", "--quiet", "--tags", url, "+refs/heads/*:refs/remotes/origin/*"); err != nil {
+		return "", err
+	}
+
+	for _, candidate := range []string{ref, "origin/" + ref} {
+		if sha, err := git("rev-parse", "--verify", "--quiet", candidate+"^{commit}"); err == nil {
+			return sha, nil
+		}
+	}
+
+	return "", fmt.Errorf("ref %s not found in %s", ref, url)
+}
+
+func runGit(ctx context.Context, gitPath, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, gitPath, args...)
+	cmd.Dir = dir
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s failed: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s failed: %w", args[0], err)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -10,20 +10,37 @@ import (
 	"github.com/docker/docker/builder/dockerignore"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/fileutils"
+	"github.com/sammccord/flyctl/helpers"
 )
 
+// namedContextsDir is where named build contexts are placed inside an archived context
+const namedContextsDir = ".fly/contexts"
+
+// mainContextDir is where the context itself is placed inside an archive with named contexts, so COPY . .
+// doesn't copy the named contexts next to it
+const mainContextDir = ".fly/context"
+
 type archiveOptions struct {
 	sourcePath string
 	exclusions []string
 	compressed bool
 	additions  map[string][]byte
+	// namedContexts are appended to the archive under namedContextsDir/<name>, moving the context itself
+	// to mainContextDir
+	namedContexts []namedContext
+}
+
+type namedContext struct {
+	name       string
+	path       string
+	exclusions []string
 }
 
 func archiveDirectory(options archiveOptions) (io.ReadCloser, error) {
 	opts := &archive.TarOptions{
 		ExcludePatterns: options.exclusions,
 	}
-	if options.compressed && len(options.additions) == 0 {
+	if options.compressed && len(options.additions) == 0 && len(options.namedContexts) == 0 {
 		opts.Compression = archive.Gzip
 	}
 
@@ -48,11 +65,84 @@ func archiveDirectory(options archiveOptions) (io.ReadCloser, error) {
 		r = archive.ReplaceFileTarWrapper(r, mods)
 	}
 
+	if len(options.namedContexts) > 0 {
+		r = appendNamedContexts(r, options.namedContexts)
+	}
+
 	return r, nil
 }
 
-func readDockerignore(workingDir string) ([]string, error) {
-	file, err := os.Open(filepath.Join(workingDir, ".dockerignore"))
+// appendNamedContexts copies the entries of r under mainContextDir and then archives each named context under
+// namedContextsDir
+func appendNamedContexts(r io.ReadCloser, contexts []namedContext) io.ReadCloser {
+	pr, pw := io.Pipe()
+
+	go func() {
+		defer r.Close()
+
+		tw := tar.NewWriter(pw)
+		err := copyTarEntries(tw, r, mainContextDir+"/")
+		for _, c := range contexts {
+			if err != nil {
+				break
+			}
+
+			var cr io.ReadCloser
+			if cr, err = archive.TarWithOptions(c.path, &archive.TarOptions{ExcludePatterns: c.exclusions}); err != nil {
+				break
+			}
+			err = copyTarEntries(tw, cr, namedContextsDir+"/"+c.name+"/")
+			cr.Close()
+		}
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	return pr
+}
+
+func copyTarEntries(tw *tar.Writer, r io.Reader, prefix string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		hdr.Name = prefix + hdr.Name
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// readDockerignore returns the exclusions for a build context. Like BuildKit, a <Dockerfile>.dockerignore
+// next to the Dockerfile takes precedence over the .dockerignore at the root of the context.
+func readDockerignore(workingDir, dockerfile string) ([]string, error) {
+	ignorePath := filepath.Join(workingDir, ".dockerignore")
+
+	var dockerfileRel string
+	if dockerfile != "" {
+		if helpers.FileExists(dockerfile + ".dockerignore") {
+			ignorePath = dockerfile + ".dockerignore"
+		}
+		if isPathInRoot(dockerfile, workingDir) {
+			rel, err := filepath.Rel(workingDir, dockerfile)
+			if err != nil {
+				return nil, err
+			}
+			dockerfileRel = filepath.ToSlash(rel)
+		}
+	}
+
+	file, err := os.Open(ignorePath)
 	if os.IsNotExist(err) {
 		return []string{}, nil
 	} else if err != nil {
@@ -60,16 +150,18 @@ func readDockerignore(workingDir string) ([]string, error) {
 	}
 	defer file.Close()
 
-	return parseDockerignore(file)
+	return parseDockerignore(file, dockerfileRel)
 }
 
-func parseDockerignore(r io.Reader) ([]string, error) {
+// parseDockerignore reads ignore patterns and makes sure the builder can still read the Dockerfile,
+// at dockerfile relative to the context when it's set. fly.toml is excluded unless a pattern says otherwise.
+func parseDockerignore(r io.Reader, dockerfile string) ([]string, error) {
 	excludes, err := dockerignore.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
 
-	if match, _ := fileutils.Matches("fly.toml", excludes); !match {
+	if match, _ := fileutils.Matches("fly.toml", excludes); !match && !reincludes(excludes, "fly.toml") {
 		excludes = append(excludes, "fly.toml")
 	}
 
@@ -85,9 +177,28 @@ func parseDockerignore(r io.Reader) ([]string, error) {
 		excludes = append(excludes, "![Dd]ockerfile")
 	}
 
+	if dockerfile != "" {
+		if match, _ := fileutils.Matches(dockerfile, excludes); match {
+			excludes = append(excludes, "!"+dockerfile)
+		}
+	}
+
 	return excludes, nil
 }
 
+// reincludes reports whether one of the ! patterns in excludes matches file
+func reincludes(excludes []string, file string) bool {
+	for _, pattern := range excludes {
+		if !strings.HasPrefix(pattern, "!") {
+			continue
+		}
+		if match, _ := fileutils.Matches(file, []string{pattern[1:]}); match {
+			return true
+		}
+	}
+	return false
+}
+
 func isPathInRoot(target, rootDir string) bool {
 	rootDir, _ = filepath.Abs(rootDir)
 	if !filepath.IsAbs(target) {
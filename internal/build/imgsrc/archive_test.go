@@ -167,12 +167,89 @@ func TestParseDockerignore(t *testing.T) {
 	}
 
 	for input, expected := range cases {
-		excludes, err := parseDockerignore(strings.NewReader(input))
+		excludes, err := parseDockerignore(strings.NewReader(input), "")
 		assert.NoError(t, err)
 		assert.Equal(t, expected, excludes, input)
 	}
 }
 
+func TestParseDockerignoreReincludes(t *testing.T) {
+	excludes, err := parseDockerignore(strings.NewReader("*.toml\n!fly.toml"), "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"*.toml", "!fly.toml"}, excludes)
+
+	excludes, err = parseDockerignore(strings.NewReader("docker"), "docker/api.Dockerfile")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"docker", "fly.toml", "!docker/api.Dockerfile"}, excludes)
+}
+
+func TestReadDockerignorePerDockerfile(t *testing.T) {
+	testDir, err := newTestDir("docker/api.Dockerfile", "docker/web.Dockerfile")
+	assert.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(testDir, ".dockerignore"), []byte("*.log"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(testDir, "docker/api.Dockerfile.dockerignore"), []byte("web\nassets"), 0644))
+
+	excludes, err := readDockerignore(testDir, filepath.Join(testDir, "docker/api.Dockerfile"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web", "assets", "fly.toml"}, excludes)
+
+	excludes, err = readDockerignore(testDir, filepath.Join(testDir, "docker/web.Dockerfile"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"*.log", "fly.toml"}, excludes)
+}
+
+func TestArchiverNegation(t *testing.T) {
+	testDir, err := newTestDir("a.md", "README.md", "content/foo.md", "images/a.jpg", "images/keep.jpg", "fly.toml")
+	assert.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	excludes, err := parseDockerignore(strings.NewReader("**/*.md\n!README.md\nimages\n!images/keep.jpg"), "")
+	assert.NoError(t, err)
+
+	r, err := archiveDirectory(archiveOptions{
+		sourcePath: testDir,
+		exclusions: excludes,
+	})
+	assert.NoError(t, err)
+
+	names, _, err := unpackTar(r)
+	assert.NoError(t, err)
+
+	assert.ElementsMatch(t, names, []string{"README.md", "images/keep.jpg"})
+}
+
+func TestArchiverNamedContexts(t *testing.T) {
+	testDir, err := newTestDir("Dockerfile", "index.js")
+	assert.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	sharedDir, err := newTestDir("lib/util.js", "lib/util.test.js")
+	assert.NoError(t, err)
+	defer os.RemoveAll(sharedDir)
+	assert.NoError(t, os.WriteFile(filepath.Join(sharedDir, ".dockerignore"), []byte("**/*.test.js"), 0644))
+
+	contexts, err := loadNamedContexts(map[string]string{"shared": sharedDir})
+	assert.NoError(t, err)
+
+	r, err := archiveDirectory(archiveOptions{
+		sourcePath:    testDir,
+		compressed:    true,
+		namedContexts: contexts,
+		additions: map[string][]byte{
+			"Dockerfile": []byte("rewritten"),
+		},
+	})
+	assert.NoError(t, err)
+
+	names, contents, err := unpackTar(r)
+	assert.NoError(t, err)
+
+	assert.ElementsMatch(t, names, []string{".fly/context/Dockerfile", ".fly/context/index.js", ".fly/contexts/shared/.dockerignore", ".fly/contexts/shared/lib/util.js"})
+	assert.Equal(t, []byte("rewritten"), contents[".fly/context/Dockerfile"])
+}
+
 func TestIsPathInRoot(t *testing.T) {
 	cases := []struct {
 		filename string
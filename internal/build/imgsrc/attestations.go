@@ -0,0 +1,339 @@
+package imgsrc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	dockerclient "github.com/docker/docker/client"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/pkg/errors"
+	"github.com/sammccord/flyctl/internal/build/attest"
+	"github.com/sammccord/flyctl/internal/build/gitsrc"
+	"github.com/sammccord/flyctl/internal/buildinfo"
+	"github.com/sammccord/flyctl/internal/cmdfmt"
+	"github.com/sammccord/flyctl/pkg/iostreams"
+	"github.com/sammccord/flyctl/terminal"
+)
+
+// attestation is a document describing an image
+type attestation struct {
+	fileName  string
+	mediaType string
+	content   []byte
+}
+
+// builtImage is an image read back after it was built or resolved, to describe or scan it
+type builtImage struct {
+	image   v1.Image
+	subject attest.Subject
+	// descriptor is the manifest in the registry attestations are attached to, nil for images that weren't pushed
+	descriptor *v1.Descriptor
+	// dir holds images saved from docker until they've been read
+	dir string
+}
+
+func (t *builtImage) close() {
+	if t.dir != "" {
+		os.RemoveAll(t.dir)
+	}
+}
+
+// attest generates the SBOM and provenance requested in opts for an image made by builder. They're saved to
+// opts.SBOMOut and attached to pushed images as OCI referrers.
+func (r *Resolver) attest(ctx context.Context, streams *iostreams.IOStreams, builder string, opts ImageOptions, img *DeploymentImage, started time.Time) error {
+	if opts.SBOM == "" && !opts.Provenance {
+		return nil
+	}
+	finished := time.Now()
+	tool := attest.Tool{Name: "flyctl", Version: buildinfo.Version().String()}
+
+	cmdfmt.PrintBegin(streams.ErrOut, "Generating attestations")
+
+	platform, err := attestationPlatform(opts)
+	if err != nil {
+		return err
+	}
+
+	target, err := r.findImage(ctx, img, opts.Publish, opts.OutputPath, platform)
+	if err != nil {
+		return err
+	}
+	defer target.close()
+
+	attestations := []attestation{}
+
+	if opts.SBOM != "" {
+		inv, err := attest.ScanImage(target.image)
+		if err != nil {
+			return errors.Wrap(err, "error reading packages from image")
+		}
+
+		content, err := attest.NewSBOM(opts.SBOM, target.subject, inv, tool, finished)
+		if err != nil {
+			return err
+		}
+		attestations = append(attestations, attestation{attest.FileName(opts.SBOM), attest.MediaType(opts.SBOM), content})
+		fmt.Fprintf(streams.ErrOut, "SBOM lists %d packages\n", len(inv.Packages))
+	}
+
+	if opts.Provenance {
+		content, err := attest.NewProvenance(target.subject, buildProvenance(ctx, builder, opts, started, finished), tool)
+		if err != nil {
+			return err
+		}
+		attestations = append(attestations, attestation{attest.ProvenanceFileName, attest.MediaTypeProvenance, content})
+	}
+
+	if opts.SBOMOut != "" {
+		if err := os.MkdirAll(opts.SBOMOut, 0755); err != nil {
+			return err
+		}
+		for _, a := range attestations {
+			path := filepath.Join(opts.SBOMOut, a.fileName)
+			if err := os.WriteFile(path, a.content, 0644); err != nil {
+				return errors.Wrapf(err, "error writing %s", path)
+			}
+			fmt.Fprintf(streams.ErrOut, "Wrote %s\n", path)
+		}
+	}
+
+	if target.descriptor != nil {
+		repo, err := name.NewRepository(target.subject.Name)
+		if err != nil {
+			return err
+		}
+		for _, a := range attestations {
+			if err := attachReferrer(ctx, repo, *target.descriptor, a.mediaType, a.content); err != nil {
+				return errors.Wrapf(pushError(err, img.Tag), "error attaching %s", a.fileName)
+			}
+		}
+		fmt.Fprintf(streams.ErrOut, "Attached %d attestations to %s@%s\n", len(attestations), target.subject.Name, target.subject.Digest)
+	}
+
+	cmdfmt.PrintDone(streams.ErrOut, "Generating attestations done")
+
+	return nil
+}
+
+// Inventory lists the packages installed in an image that was built or resolved, reading it from the registry
+// when it was published, otherwise from outputPath or the docker daemon
+func (r *Resolver) Inventory(ctx context.Context, img *DeploymentImage, published bool, outputPath string) (*attest.Inventory, error) {
+	target, err := r.findImage(ctx, img, published, outputPath, defaultPlatform)
+	if err != nil {
+		return nil, err
+	}
+	defer target.close()
+
+	return attest.ScanImage(target.image)
+}
+
+// findImage reads the image back: from the registry when it was pushed, otherwise from the output path or
+// docker daemon. For multi-platform images the image for platform is read.
+func (r *Resolver) findImage(ctx context.Context, img *DeploymentImage, published bool, outputPath string, platform v1.Platform) (*builtImage, error) {
+	ref, err := name.ParseReference(img.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	target := &builtImage{
+		subject: attest.Subject{
+			Name:     ref.Context().Name(),
+			Tag:      img.Tag,
+			Platform: platformString(platform),
+		},
+	}
+
+	switch {
+	case published:
+		desc, err := remote.Get(ref, remote.WithContext(ctx), remote.WithAuthFromKeychain(registryKeychain{}))
+		if err != nil {
+			return nil, errors.Wrap(err, "error fetching pushed image")
+		}
+		if desc.MediaType.IsIndex() {
+			target.image, err = pullImage(ctx, img.Tag, platform)
+		} else {
+			target.image, err = desc.Image()
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "error fetching pushed image")
+		}
+		target.descriptor = &desc.Descriptor
+		target.subject.Digest = desc.Digest.String()
+
+		return target, nil
+
+	case outputPath != "":
+		if target.image, err = readImageOutput(outputPath, img.Tag, platform); err != nil {
+			return nil, errors.Wrapf(err, "error reading image from %s", outputPath)
+		}
+		digest, err := target.image.Digest()
+		if err != nil {
+			return nil, err
+		}
+		target.subject.Digest = digest.String()
+
+		return target, nil
+	}
+
+	if r.dockerFactory.mode.IsNone() {
+		return nil, errors.New("the image needs to be pushed to the registry or written with --image-out to be read")
+	}
+
+	docker, err := r.dockerFactory.buildFn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if target.dir, err = os.MkdirTemp("", "flyctl-attest-"); err != nil {
+		return nil, err
+	}
+
+	// the image is saved by ID, images found by --image aren't tagged with the deployment tag
+	path := filepath.Join(target.dir, "image.tar")
+	if err := saveImageArchive(ctx, docker, img.ID, path); err != nil {
+		target.close()
+		return nil, errors.Wrap(err, "error saving image from docker")
+	}
+	if target.image, err = tarball.ImageFromPath(path, nil); err != nil {
+		target.close()
+		return nil, err
+	}
+	target.subject.Digest = img.ID
+
+	return target, nil
+}
+
+// saveImageArchive writes ref to path, replacing the previous archive only once the new one is complete
+func saveImageArchive(ctx context.Context, docker *dockerclient.Client, ref, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	r, err := docker.ImageSave(ctx, []string{ref})
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".image-*.tar")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// attestationPlatform is the platform Fly VMs run when it was built, otherwise the first platform
+func attestationPlatform(opts ImageOptions) (v1.Platform, error) {
+	platforms, err := imagePlatforms(opts)
+	if err != nil {
+		return v1.Platform{}, err
+	}
+	for _, p := range platforms {
+		if checkDeployPlatform("", p) == nil {
+			return p, nil
+		}
+	}
+	return platforms[0], nil
+}
+
+// readImageOutput loads the image tagged tag from a tarball or OCI layout written by --image-out
+func readImageOutput(outputPath, tag string, platform v1.Platform) (v1.Image, error) {
+	if strings.HasSuffix(outputPath, ".tar") {
+		ref, err := name.NewTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		return tarball.ImageFromPath(outputPath, &ref)
+	}
+
+	idx, err := layout.ImageIndexFromPath(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	// later builds with the same tag are appended, so the last match is the newest
+	for i := len(manifest.Manifests) - 1; i >= 0; i-- {
+		desc := manifest.Manifests[i]
+		if desc.Annotations["org.opencontainers.image.ref.name"] != tag {
+			continue
+		}
+		if !desc.MediaType.IsIndex() {
+			return idx.Image(desc.Digest)
+		}
+
+		child, err := idx.ImageIndex(desc.Digest)
+		if err != nil {
+			return nil, err
+		}
+		childManifest, err := child.IndexManifest()
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range childManifest.Manifests {
+			if m.Platform != nil && platformString(*m.Platform) == platformString(platform) {
+				return child.Image(m.Digest)
+			}
+		}
+		return nil, fmt.Errorf("%s has no image for %s", tag, platformString(platform))
+	}
+
+	return nil, fmt.Errorf("%s not found", tag)
+}
+
+// buildProvenance records how opts were built by builder, including the commit of the working directory
+func buildProvenance(ctx context.Context, builder string, opts ImageOptions, started, finished time.Time) attest.Build {
+	build := attest.Build{
+		Builder:    builder,
+		Target:     opts.Target,
+		BuildArgs:  buildArgsMap(opts),
+		Platforms:  opts.Platforms,
+		StartedOn:  started,
+		FinishedOn: finished,
+	}
+
+	switch {
+	case opts.AppConfig != nil && opts.AppConfig.HasBuilder():
+		build.Buildpacks = opts.AppConfig.Build.Buildpacks
+	case opts.AppConfig != nil && opts.AppConfig.HasBuiltin():
+		build.Builtin = opts.AppConfig.Build.Builtin
+	default:
+		dockerfile := opts.DockerfilePath
+		if dockerfile == "" {
+			dockerfile = resolveDockerfile(opts.WorkingDir)
+		}
+		if rel, err := filepath.Rel(opts.WorkingDir, dockerfile); err == nil && dockerfile != "" {
+			build.Dockerfile = filepath.ToSlash(rel)
+		}
+	}
+
+	if commit, err := gitsrc.Head(ctx, opts.WorkingDir); err == nil {
+		build.Git = &attest.GitSource{Commit: commit.SHA, URL: commit.URL, Dirty: commit.Dirty}
+	} else {
+		terminal.Debugf("no git commit for provenance: %v\n", err)
+	}
+
+	return build
+}
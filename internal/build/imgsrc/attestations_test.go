@@ -0,0 +1,83 @@
+package imgsrc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	dockerclient "github.com/docker/docker/client"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/sammccord/flyctl/internal/build/attest"
+	"github.com/sammccord/flyctl/pkg/iostreams"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestDaemon serves docker save for images by ID, like a daemon where the deployment tag was already removed
+func newTestDaemon(t *testing.T, images map[string]v1.Image) *dockerClientFactory {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		img, ok := images[r.URL.Query().Get("names")]
+		if !strings.HasSuffix(r.URL.Path, "/images/get") || !ok {
+			http.Error(w, `{"message":"No such image"}`, http.StatusNotFound)
+			return
+		}
+		tag, _ := name.NewTag("flyctl-test:saved")
+		tarball.Write(tag, img, w)
+	}))
+	t.Cleanup(server.Close)
+
+	docker, err := dockerclient.NewClientWithOpts(dockerclient.WithHost("tcp://"+strings.TrimPrefix(server.URL, "http://")), dockerclient.WithVersion("1.41"))
+	require.NoError(t, err)
+
+	return &dockerClientFactory{
+		mode:    DockerDaemonTypeLocal,
+		buildFn: func(ctx context.Context) (*dockerclient.Client, error) { return docker, nil },
+	}
+}
+
+func TestAttestBuildOnly(t *testing.T) {
+	img, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	id, err := img.ConfigName()
+	require.NoError(t, err)
+
+	r := &Resolver{dockerFactory: newTestDaemon(t, map[string]v1.Image{id.String(): img})}
+	streams, _, _, _ := iostreams.Test()
+
+	out := t.TempDir()
+	opts := ImageOptions{
+		AppName:    "test-app",
+		WorkingDir: t.TempDir(),
+		Tag:        "registry.fly.io/test-app:deployment-1",
+		SBOM:       attest.FormatSPDX,
+		SBOMOut:    out,
+	}
+	built := &DeploymentImage{ID: id.String(), Tag: opts.Tag}
+
+	require.NoError(t, r.attest(context.Background(), streams, "Dockerfile", opts, built, time.Now()))
+
+	sbom, err := os.ReadFile(filepath.Join(out, attest.FileName(attest.FormatSPDX)))
+	require.NoError(t, err)
+	assert.Contains(t, string(sbom), "registry.fly.io/test-app")
+}
+
+func TestInventoryBuildOnly(t *testing.T) {
+	img, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	id, err := img.ConfigName()
+	require.NoError(t, err)
+
+	r := &Resolver{dockerFactory: newTestDaemon(t, map[string]v1.Image{id.String(): img})}
+
+	inv, err := r.Inventory(context.Background(), &DeploymentImage{ID: id.String(), Tag: "registry.fly.io/test-app:deployment-1"}, false, "")
+	require.NoError(t, err)
+	assert.Empty(t, inv.Packages)
+}
@@ -0,0 +1,133 @@
+package imgsrc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/pkg/errors"
+	"github.com/sammccord/flyctl/flyctl"
+	"github.com/sammccord/flyctl/pkg/iostreams"
+	"github.com/sammccord/flyctl/terminal"
+	"github.com/spf13/viper"
+)
+
+const cacheTypeRegistry = "registry"
+
+// BuildCache is where layer cache is imported from or exported to, an image in the Fly registry which defaults to
+// the app's cache tag
+type BuildCache struct {
+	Type string
+	Ref  string
+}
+
+// ParseBuildCache parses type=registry[,ref=<image>] or a bare image reference, following docker buildx
+// --cache-from and --cache-to. Builds go through the Docker API, which only exports cache inline in the image, so
+// BuildKit's other cache backends like type=local aren't available.
+func ParseBuildCache(spec string) (BuildCache, error) {
+	cache := BuildCache{Type: cacheTypeRegistry}
+
+	if !strings.Contains(spec, "=") {
+		cache.Ref = spec
+	} else if err := cache.parseFields(spec); err != nil {
+		return cache, err
+	}
+
+	switch cache.Type {
+	case cacheTypeRegistry:
+		if registry := viper.GetString(flyctl.ConfigRegistryHost); cache.Ref != "" && !strings.HasPrefix(cache.Ref, registry+"/") {
+			return cache, fmt.Errorf("build cache %s must be an image in %s", cache.Ref, registry)
+		}
+	default:
+		return cache, fmt.Errorf("unsupported build cache type %q, only registry is supported", cache.Type)
+	}
+
+	return cache, nil
+}
+
+func (c *BuildCache) parseFields(spec string) error {
+	for _, field := range strings.Split(spec, ",") {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid build cache %q, expected type=registry,ref=<image>", spec)
+		}
+
+		switch key, value := strings.ToLower(parts[0]), parts[1]; key {
+		case "type":
+			c.Type = value
+		case "ref":
+			c.Ref = value
+		case "mode":
+			// caches are stored inline in the image, which only holds the layers of the final stage
+			if value != "min" {
+				return fmt.Errorf("unsupported build cache mode %q, only min is supported", value)
+			}
+		default:
+			return fmt.Errorf("unexpected key %q in build cache %q", key, spec)
+		}
+	}
+
+	return nil
+}
+
+// image is the reference of the cache image in the registry
+func (c BuildCache) image(appName string) string {
+	if c.Ref == "" {
+		return newCacheTag(appName)
+	}
+	return c.Ref
+}
+
+// importBuildCache makes cache images available to the daemon and returns their references for CacheFrom.
+// BuildKit reads registry caches itself, the classic builder only uses images it already has.
+// Missing caches are expected on first builds, so failures are only logged.
+func importBuildCache(ctx context.Context, docker *dockerclient.Client, appName string, caches []BuildCache, pull bool) []string {
+	refs := []string{}
+
+	for _, cache := range caches {
+		ref := cache.image(appName)
+
+		if pull {
+			if err := pullCacheImage(ctx, docker, ref); err != nil {
+				terminal.Debugf("error pulling build cache %s: %v\n", ref, err)
+				continue
+			}
+		}
+
+		refs = append(refs, ref)
+	}
+
+	return refs
+}
+
+func pullCacheImage(ctx context.Context, docker *dockerclient.Client, ref string) error {
+	resp, err := docker.ImagePull(ctx, ref, types.ImagePullOptions{RegistryAuth: flyRegistryAuth()})
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	_, err = io.Copy(io.Discard, resp)
+	return err
+}
+
+// exportBuildCache tags the built image as each cache and pushes it to the registry.
+// The image carries its layer cache inline, so exporting it is all later builds need.
+func exportBuildCache(ctx context.Context, docker *dockerclient.Client, streams *iostreams.IOStreams, appName, imageID string, caches []BuildCache) error {
+	for _, cache := range caches {
+		ref := cache.image(appName)
+
+		if err := docker.ImageTag(ctx, imageID, ref); err != nil {
+			return errors.Wrapf(err, "error tagging build cache %s", ref)
+		}
+
+		if err := pushToFly(ctx, docker, streams, ref); err != nil {
+			return errors.Wrapf(err, "error pushing build cache %s", ref)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package imgsrc
+
+import (
+	"testing"
+
+	"github.com/sammccord/flyctl/flyctl"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	viper.SetDefault(flyctl.ConfigRegistryHost, "registry.fly.io")
+}
+
+func TestParseBuildCache(t *testing.T) {
+	cases := map[string]BuildCache{
+		"type=registry":                                 {Type: "registry"},
+		"registry.fly.io/my-app:cache":                  {Type: "registry", Ref: "registry.fly.io/my-app:cache"},
+		"type=registry,ref=registry.fly.io/shared:main": {Type: "registry", Ref: "registry.fly.io/shared:main"},
+		"type=registry,mode=min":                        {Type: "registry"},
+	}
+	for spec, expected := range cases {
+		cache, err := ParseBuildCache(spec)
+		assert.NoError(t, err, spec)
+		assert.Equal(t, expected, cache, spec)
+	}
+
+	for _, spec := range []string{"docker.io/library/node:cache", "type=gha", "type=local,dest=/tmp/cache", "type=registry,foo", "type=registry,mode=max"} {
+		_, err := ParseBuildCache(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestBuildCacheImage(t *testing.T) {
+	cache, err := ParseBuildCache("type=registry")
+	require.NoError(t, err)
+	assert.Equal(t, "registry.fly.io/my-app:cache", cache.image("my-app"))
+
+	cache, err = ParseBuildCache("registry.fly.io/shared:main")
+	require.NoError(t, err)
+	assert.Equal(t, "registry.fly.io/shared:main", cache.image("my-app"))
+}
@@ -0,0 +1,217 @@
+package imgsrc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+var contextNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// loadNamedContexts validates named build contexts and reads the .dockerignore of each one
+func loadNamedContexts(contexts map[string]string) ([]namedContext, error) {
+	out := []namedContext{}
+	for name, dir := range contexts {
+		if !contextNameRegexp.MatchString(name) {
+			return nil, fmt.Errorf("invalid build context name %q", name)
+		}
+
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, fmt.Errorf("build context %s: %w", name, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("build context %s: %s is not a directory", name, dir)
+		}
+
+		excludes, err := readDockerignore(dir, "")
+		if err != nil {
+			return nil, fmt.Errorf("build context %s: error reading .dockerignore: %w", name, err)
+		}
+
+		out = append(out, namedContext{name: name, path: dir, exclusions: excludes})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+
+	return out, nil
+}
+
+// rewriteNamedContexts points COPY and ADD instructions and RUN bind mounts at the copies of the main and named
+// contexts in the archive, since the docker build API has no way to hand named contexts to the builder. Keeping the
+// main context in its own directory means COPY . . doesn't pick up the named contexts next to it.
+// Lines are replaced in place so errors from the builder still refer to the right line.
+func rewriteNamedContexts(dockerfile []byte, contexts []namedContext) ([]byte, error) {
+	result, err := parser.Parse(bytes.NewReader(dockerfile))
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for _, c := range contexts {
+		names[c.name] = true
+	}
+
+	// stages shadow named contexts with the same name
+	for _, node := range result.AST.Children {
+		if args := nodeArgs(node); strings.EqualFold(node.Value, "from") && len(args) == 3 && strings.EqualFold(args[1], "as") {
+			for name := range names {
+				if strings.EqualFold(name, args[2]) {
+					delete(names, name)
+				}
+			}
+		}
+	}
+
+	// contextDir returns where the context a source comes from is in the archive, if it's a context at all
+	contextDir := func(from string) (string, bool) {
+		switch {
+		case from == "":
+			return mainContextDir, true
+		case names[from]:
+			return path.Join(namedContextsDir, from), true
+		}
+		return "", false
+	}
+
+	lines := strings.Split(string(dockerfile), "\n")
+
+	for _, node := range result.AST.Children {
+		args := nodeArgs(node)
+
+		if strings.EqualFold(node.Value, "from") && len(args) > 0 && names[args[0]] {
+			return nil, fmt.Errorf("line %d: build context %s can only be used with COPY --from", node.StartLine, args[0])
+		}
+
+		if strings.EqualFold(node.Value, "run") {
+			if err := rewriteBindMounts(lines, node, contextDir); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if !strings.EqualFold(node.Value, "copy") && !strings.EqualFold(node.Value, "add") || len(args) < 2 {
+			continue
+		}
+		// heredocs are written by the instruction itself rather than read from a context
+		if len(node.Heredocs) > 0 || strings.HasPrefix(args[0], "<<") {
+			continue
+		}
+
+		var from string
+		flags := []string{}
+		for _, flag := range node.Flags {
+			if strings.HasPrefix(flag, "--from=") {
+				from = strings.TrimPrefix(flag, "--from=")
+				continue
+			}
+			flags = append(flags, flag)
+		}
+		dir, ok := contextDir(from)
+		if !ok {
+			continue
+		}
+
+		for i, src := range args[:len(args)-1] {
+			if isRemoteSource(src) {
+				continue
+			}
+			args[i] = contextPath(dir, src)
+		}
+
+		argsJSON, err := json.Marshal(args)
+		if err != nil {
+			return nil, err
+		}
+
+		instruction := append([]string{strings.ToUpper(node.Value)}, flags...)
+		lines[node.StartLine-1] = strings.Join(append(instruction, string(argsJSON)), " ")
+		for i := node.StartLine; i < node.EndLine; i++ {
+			lines[i] = ""
+		}
+	}
+
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// rewriteBindMounts points the bind mounts of a RUN instruction that read from a context at its directory in the
+// archive, replacing each --mount flag where it's written
+func rewriteBindMounts(lines []string, node *parser.Node, contextDir func(string) (string, bool)) error {
+	for _, flag := range node.Flags {
+		if !strings.HasPrefix(flag, "--mount=") {
+			continue
+		}
+
+		fields := strings.Split(strings.TrimPrefix(flag, "--mount="), ",")
+		mountType, from, source := "bind", "", ""
+		for _, field := range fields {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			switch strings.ToLower(kv[0]) {
+			case "type":
+				mountType = kv[1]
+			case "from":
+				from = kv[1]
+			case "source", "src":
+				source = kv[1]
+			}
+		}
+		if mountType != "bind" {
+			continue
+		}
+		dir, ok := contextDir(from)
+		if !ok {
+			continue
+		}
+
+		rewritten := []string{}
+		for _, field := range fields {
+			switch strings.ToLower(strings.SplitN(field, "=", 2)[0]) {
+			case "from", "source", "src":
+				continue
+			}
+			rewritten = append(rewritten, field)
+		}
+		rewritten = append(rewritten, "source="+contextPath(dir, source))
+
+		replaced := false
+		for i := node.StartLine - 1; i < node.EndLine && !replaced; i++ {
+			if strings.Contains(lines[i], flag) {
+				lines[i] = strings.Replace(lines[i], flag, "--mount="+strings.Join(rewritten, ","), 1)
+				replaced = true
+			}
+		}
+		if !replaced {
+			return fmt.Errorf("line %d: can't rewrite %s for build contexts", node.StartLine, flag)
+		}
+	}
+	return nil
+}
+
+// contextPath returns where src is in the copy of a context at dir in the archive. Sources can't reach outside
+// their context, so .. stops at its root like it does for the builder.
+func contextPath(dir, src string) string {
+	return path.Join(dir, path.Clean("/"+src))
+}
+
+// isRemoteSource reports whether an ADD source is fetched from a URL or git rather than a context
+func isRemoteSource(src string) bool {
+	return strings.Contains(src, "://") || strings.HasPrefix(src, "git@")
+}
+
+func nodeArgs(node *parser.Node) []string {
+	args := []string{}
+	for n := node.Next; n != nil; n = n.Next {
+		args = append(args, n.Value)
+	}
+	return args
+}
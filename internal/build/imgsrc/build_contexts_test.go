@@ -0,0 +1,42 @@
+package imgsrc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewriteNamedContexts(t *testing.T) {
+	contexts := []namedContext{{name: "shared"}, {name: "build"}}
+
+	dockerfile := `FROM node:16 AS build
+COPY --from=shared /lib ./lib
+COPY --chown=node:node --from=shared \
+  package.json yarn.lock ./
+COPY --from=build /app/dist /dist
+COPY . .
+add https://example.com/app.tar.gz ../config /app/
+RUN --mount=type=bind,from=shared,source=scripts,target=/scripts \
+  --mount=type=cache,target=/root/.npm \
+  --mount=target=/src npm ci
+`
+
+	out, err := rewriteNamedContexts([]byte(dockerfile), contexts)
+	require.NoError(t, err)
+
+	assert.Equal(t, `FROM node:16 AS build
+COPY [".fly/contexts/shared/lib","./lib"]
+COPY --chown=node:node [".fly/contexts/shared/package.json",".fly/contexts/shared/yarn.lock","./"]
+
+COPY --from=build /app/dist /dist
+COPY [".fly/context","."]
+ADD ["https://example.com/app.tar.gz",".fly/context/config","/app/"]
+RUN --mount=type=bind,target=/scripts,source=.fly/contexts/shared/scripts \
+  --mount=type=cache,target=/root/.npm \
+  --mount=target=/src,source=.fly/context npm ci
+`, string(out))
+
+	_, err = rewriteNamedContexts([]byte("FROM shared\n"), contexts)
+	assert.Error(t, err)
+}
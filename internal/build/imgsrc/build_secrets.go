@@ -0,0 +1,146 @@
+package imgsrc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/secrets/secretsprovider"
+	"github.com/moby/buildkit/session/sshforward/sshprovider"
+	"github.com/pkg/errors"
+	"github.com/sammccord/flyctl/helpers"
+)
+
+var errBuildSecretsUnsupported = errors.New("build secrets and ssh forwarding require a docker daemon with BuildKit enabled")
+
+// BuildSecret is made available to RUN --mount=type=secret,id=<ID> instructions without being stored in the image.
+// The value is read from the file at Src or the environment variable Env.
+type BuildSecret struct {
+	ID  string
+	Src string
+	Env string
+}
+
+// ParseBuildSecret parses id=<id>[,type=file|env][,src=<path>|,env=<var>], the format of docker build --secret.
+// With a type, src names a file or an environment variable, defaulting to id. Without one the secret is read from
+// the environment variable named id, or the file named id.
+func ParseBuildSecret(spec string) (BuildSecret, error) {
+	var secret BuildSecret
+	var secretType, src string
+
+	for _, field := range strings.Split(spec, ",") {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return secret, fmt.Errorf("invalid build secret %q, expected id=<id>,src=<path>", spec)
+		}
+
+		switch key, value := strings.ToLower(parts[0]), parts[1]; key {
+		case "id":
+			secret.ID = value
+		case "src", "source":
+			src = value
+		case "env":
+			secret.Env = value
+		case "type":
+			if value != "file" && value != "env" {
+				return secret, fmt.Errorf("invalid build secret type %q", value)
+			}
+			secretType = value
+		default:
+			return secret, fmt.Errorf("unexpected key %q in build secret %q", key, spec)
+		}
+	}
+
+	if secret.ID == "" {
+		return secret, fmt.Errorf("build secret %q is missing an id", spec)
+	}
+	if src != "" && secret.Env != "" {
+		return secret, fmt.Errorf("build secret %s can't set both src and env", secret.ID)
+	}
+
+	switch secretType {
+	case "env":
+		if secret.Env == "" {
+			secret.Env = src
+		}
+		if secret.Env == "" {
+			secret.Env = secret.ID
+		}
+	case "file":
+		if secret.Env != "" {
+			return secret, fmt.Errorf("build secret %s is a file and can't set env", secret.ID)
+		}
+		if src == "" {
+			src = secret.ID
+		}
+		secret.Src = helpers.ExpandHome(src)
+	default:
+		if src != "" {
+			secret.Src = helpers.ExpandHome(src)
+		}
+	}
+
+	return secret, nil
+}
+
+// BuildSSH forwards an SSH agent, or the given keys, to RUN --mount=type=ssh,id=<ID> instructions
+type BuildSSH struct {
+	ID    string
+	Paths []string
+}
+
+// ParseBuildSSH parses default|<id>[=<socket>|<key>[,<key>]], the format of docker build --ssh.
+// Without paths the agent at $SSH_AUTH_SOCK is forwarded.
+func ParseBuildSSH(spec string) (BuildSSH, error) {
+	parts := strings.SplitN(spec, "=", 2)
+
+	forward := BuildSSH{ID: parts[0]}
+	if forward.ID == "" {
+		return forward, fmt.Errorf("invalid ssh forward %q, expected default or <id>=<path>", spec)
+	}
+
+	if len(parts) == 2 {
+		for _, p := range strings.Split(parts[1], ",") {
+			forward.Paths = append(forward.Paths, helpers.ExpandHome(p))
+		}
+	}
+
+	return forward, nil
+}
+
+// hasBuildSecrets reports whether a build needs secrets or SSH forwarding, which only Dockerfile builds with
+// BuildKit can provide
+func hasBuildSecrets(opts ImageOptions) bool {
+	return len(opts.BuildSecrets) > 0 || len(opts.SSH) > 0
+}
+
+// allowBuildSecrets attaches providers for build secrets and SSH forwarding to a build session
+func allowBuildSecrets(s *session.Session, opts ImageOptions) error {
+	if len(opts.BuildSecrets) > 0 {
+		sources := make([]secretsprovider.Source, len(opts.BuildSecrets))
+		for i, secret := range opts.BuildSecrets {
+			sources[i] = secretsprovider.Source{ID: secret.ID, FilePath: secret.Src, Env: secret.Env}
+		}
+
+		store, err := secretsprovider.NewStore(sources)
+		if err != nil {
+			return errors.Wrap(err, "error reading build secrets")
+		}
+		s.Allow(secretsprovider.NewSecretProvider(store))
+	}
+
+	if len(opts.SSH) > 0 {
+		configs := make([]sshprovider.AgentConfig, len(opts.SSH))
+		for i, forward := range opts.SSH {
+			configs[i] = sshprovider.AgentConfig{ID: forward.ID, Paths: forward.Paths}
+		}
+
+		provider, err := sshprovider.NewSSHAgentProvider(configs)
+		if err != nil {
+			return errors.Wrap(err, "error forwarding ssh")
+		}
+		s.Allow(provider)
+	}
+
+	return nil
+}
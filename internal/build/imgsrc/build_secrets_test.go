@@ -0,0 +1,48 @@
+package imgsrc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBuildSecret(t *testing.T) {
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	cases := map[string]BuildSecret{
+		"id=npmrc,src=~/.npmrc":            {ID: "npmrc", Src: filepath.Join(home, ".npmrc")},
+		"id=token,env=NPM_TOKEN":           {ID: "token", Env: "NPM_TOKEN"},
+		"type=file,id=key,source=./id_rsa": {ID: "key", Src: "./id_rsa"},
+		"id=GITHUB_TOKEN":                  {ID: "GITHUB_TOKEN"},
+		"type=env,id=token,src=NPM_TOKEN":  {ID: "token", Env: "NPM_TOKEN"},
+		"type=env,id=NPM_TOKEN":            {ID: "NPM_TOKEN", Env: "NPM_TOKEN"},
+		"type=file,id=/run/token":          {ID: "/run/token", Src: "/run/token"},
+	}
+	for spec, expected := range cases {
+		secret, err := ParseBuildSecret(spec)
+		assert.NoError(t, err, spec)
+		assert.Equal(t, expected, secret, spec)
+	}
+
+	for _, spec := range []string{"src=~/.npmrc", "npmrc", "id=a,src=b,env=c", "id=a,mode=0400", "id=a,type=ssh", "type=file,id=a,env=B"} {
+		_, err := ParseBuildSecret(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestParseBuildSSH(t *testing.T) {
+	forward, err := ParseBuildSSH("default")
+	assert.NoError(t, err)
+	assert.Equal(t, BuildSSH{ID: "default"}, forward)
+
+	forward, err = ParseBuildSSH("github=/keys/a,/keys/b")
+	assert.NoError(t, err)
+	assert.Equal(t, BuildSSH{ID: "github", Paths: []string{"/keys/a", "/keys/b"}}, forward)
+
+	_, err = ParseBuildSSH("=/keys/a")
+	assert.Error(t, err)
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 
 	"github.com/buildpacks/pack"
+	"github.com/pkg/errors"
 	"github.com/sammccord/flyctl/flyctl"
 	"github.com/sammccord/flyctl/internal/cmdfmt"
 	"github.com/sammccord/flyctl/pkg/iostreams"
@@ -30,6 +31,10 @@ func (s *buildpacksBuilder) Run(ctx context.Context, dockerFactory *dockerClient
 		return nil, nil
 	}
 
+	if hasBuildSecrets(opts) {
+		return nil, errors.New("build secrets and ssh forwarding aren't available to buildpacks, only to Dockerfile builds")
+	}
+
 	builder := opts.AppConfig.Build.Builder
 	buildpacks := opts.AppConfig.Build.Buildpacks
 
@@ -38,7 +43,9 @@ func (s *buildpacksBuilder) Run(ctx context.Context, dockerFactory *dockerClient
 		return nil, err
 	}
 
-	defer clearDeploymentTags(ctx, docker, opts.Tag)
+	if opts.Publish {
+		defer clearDeploymentTags(ctx, docker, opts.Tag)
+	}
 
 	packClient, err := pack.NewClient(pack.WithDockerClient(docker), pack.WithLogger(newPackLogger(streams.Out)))
 	if err != nil {
@@ -4,7 +4,6 @@ import (
 	"fmt"
 
 	"github.com/pkg/errors"
-	"github.com/sammccord/flyctl/internal/build/imgsrc/builtins"
 	"github.com/sammccord/flyctl/internal/cmdfmt"
 	"github.com/sammccord/flyctl/pkg/iostreams"
 	"github.com/sammccord/flyctl/terminal"
@@ -28,7 +27,11 @@ func (ds *builtinBuilder) Run(ctx context.Context, dockerFactory *dockerClientFa
 		return nil, nil
 	}
 
-	builtin, err := builtins.GetBuiltin(opts.AppConfig.Build.Builtin)
+	if hasBuildSecrets(opts) {
+		return nil, errors.New("build secrets and ssh forwarding aren't available to builtins, only to Dockerfile builds")
+	}
+
+	builtin, err := opts.builtin()
 	if err != nil {
 		return nil, err
 	}
@@ -43,7 +46,9 @@ func (ds *builtinBuilder) Run(ctx context.Context, dockerFactory *dockerClientFa
 		return nil, errors.Wrap(err, "error connecting to docker")
 	}
 
-	defer clearDeploymentTags(ctx, docker, opts.Tag)
+	if opts.Publish {
+		defer clearDeploymentTags(ctx, docker, opts.Tag)
+	}
 
 	cmdfmt.PrintBegin(streams.ErrOut, "Creating build context")
 	archiveOpts := archiveOptions{
@@ -51,7 +56,7 @@ func (ds *builtinBuilder) Run(ctx context.Context, dockerFactory *dockerClientFa
 		compressed: dockerFactory.mode.IsRemote(),
 	}
 
-	excludes, err := readDockerignore(opts.WorkingDir)
+	excludes, err := readDockerignore(opts.WorkingDir, "")
 	if err != nil {
 		return nil, errors.Wrap(err, "error reading .dockerignore")
 	}
@@ -80,7 +85,7 @@ func (ds *builtinBuilder) Run(ctx context.Context, dockerFactory *dockerClientFa
 	cmdfmt.PrintDone(streams.ErrOut, msg)
 
 	buildArgs := normalizeBuildArgsForDocker(opts.AppConfig, opts.ExtraBuildArgs)
-	imageID, err = runClassicBuild(ctx, streams, docker, r, opts, "", buildArgs)
+	imageID, err = runClassicBuild(ctx, streams, docker, r, opts, "", buildArgs, nil)
 	if err != nil {
 		return nil, errors.Wrap(err, "error building")
 	}
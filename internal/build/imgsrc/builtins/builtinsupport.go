@@ -1,7 +1,6 @@
 package builtins
 
 import (
-	"fmt"
 	"strings"
 	"text/template"
 )
@@ -20,18 +19,14 @@ type Builtin struct {
 	Details     string
 	Template    string
 	Settings    []Setting
+	// Source is where the builtin was loaded from, DefaultSource for those shipped with flyctl
+	Source      string
 	settingsMap map[string]Setting
 }
 
 // GetBuiltin - Finds the Builtin by name
 func GetBuiltin(builtinname string) (*Builtin, error) {
-	for _, builtin := range basicbuiltins {
-		if builtin.Name == builtinname {
-			return &builtin, nil
-		}
-	}
-
-	return nil, fmt.Errorf("no builtin with %s name supported", builtinname)
+	return Defaults().Get(builtinname)
 }
 
 // ResolveSettings - Given defaults abd values return actural settings
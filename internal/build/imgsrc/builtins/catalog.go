@@ -0,0 +1,219 @@
+package builtins
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/sammccord/flyctl/internal/build/gitsrc"
+)
+
+// DefaultSource is the Source of the builtins shipped with flyctl
+const DefaultSource = "flyctl"
+
+// Catalog is a set of builtins, the defaults overridden by any loaded from custom sources
+type Catalog struct {
+	builtins []Builtin
+}
+
+// Defaults returns a catalog of the builtins shipped with flyctl
+func Defaults() *Catalog {
+	c := &Catalog{}
+	for _, b := range basicbuiltins {
+		b.Source = DefaultSource
+		c.add(b)
+	}
+	return c
+}
+
+// Get finds a builtin by name
+func (c *Catalog) Get(name string) (*Builtin, error) {
+	for _, b := range c.builtins {
+		if b.Name == name {
+			return &b, nil
+		}
+	}
+	return nil, fmt.Errorf("no builtin with %s name supported", name)
+}
+
+// List returns every builtin in the catalog, sorted by name
+func (c *Catalog) List() []Builtin {
+	list := append([]Builtin{}, c.builtins...)
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// add inserts b, replacing any builtin with the same name
+func (c *Catalog) add(b Builtin) {
+	for i := range c.builtins {
+		if c.builtins[i].Name == b.Name {
+			c.builtins[i] = b
+			return
+		}
+	}
+	c.builtins = append(c.builtins, b)
+}
+
+// Load returns the default builtins overridden by those in sources, in order, so later sources win.
+// A source is a directory, a single .toml file or a git repository as <url>[#<ref>[:<subdir>]].
+// Relative paths are resolved against baseDir.
+func Load(ctx context.Context, baseDir string, sources ...string) (*Catalog, error) {
+	c := Defaults()
+	for _, source := range sources {
+		loaded, err := LoadSource(ctx, baseDir, source)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range loaded {
+			c.add(b)
+		}
+	}
+	return c, nil
+}
+
+// LoadSource reads the builtins defined in a single source
+func LoadSource(ctx context.Context, baseDir string, source string) ([]Builtin, error) {
+	if source == "" {
+		return nil, nil
+	}
+
+	if isGitSource(source) {
+		src, err := gitsrc.ParseSource(source)
+		if err != nil {
+			return nil, err
+		}
+		checkout, err := gitsrc.Clone(ctx, src)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching builtins from %s: %w", source, err)
+		}
+		defer checkout.Remove()
+
+		return loadPath(checkout.Dir, source)
+	}
+
+	path := source
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	return loadPath(path, source)
+}
+
+// isGitSource tells git repositories apart from local paths by the url syntax, a local repository can be
+// used with a file:// url
+func isGitSource(source string) bool {
+	url := source
+	if i := strings.LastIndex(url, "#"); i >= 0 {
+		url = url[:i]
+	}
+	return strings.Contains(url, "://") || strings.HasPrefix(url, "git@") || strings.HasSuffix(url, ".git")
+}
+
+// loadPath reads a builtin file, or a directory of <name>.toml files and <name>/builtin.toml directories
+func loadPath(path string, source string) ([]Builtin, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading builtins from %s: %w", source, err)
+	}
+	if !info.IsDir() {
+		b, err := loadFile(path, source)
+		if err != nil {
+			return nil, err
+		}
+		return []Builtin{*b}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading builtins from %s: %w", source, err)
+	}
+
+	loaded := []Builtin{}
+	files := map[string]string{}
+	for _, entry := range entries {
+		file := filepath.Join(path, entry.Name())
+		switch {
+		case entry.IsDir():
+			file = filepath.Join(file, "builtin.toml")
+			if _, err := os.Stat(file); err != nil {
+				continue
+			}
+		case filepath.Ext(entry.Name()) != ".toml":
+			continue
+		}
+
+		b, err := loadFile(file, source)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := files[b.Name]; ok {
+			return nil, fmt.Errorf("builtin %s is defined in both %s and %s", b.Name, other, file)
+		}
+		files[b.Name] = file
+		loaded = append(loaded, *b)
+	}
+
+	return loaded, nil
+}
+
+// builtinFile is the layout of a builtin definition, the template is inline or in a separate Dockerfile
+type builtinFile struct {
+	Name        string `toml:"name"`
+	Description string `toml:"description"`
+	Details     string `toml:"details"`
+	Template    string `toml:"template"`
+	Dockerfile  string `toml:"dockerfile"`
+	Settings    []struct {
+		Name        string      `toml:"name"`
+		Default     interface{} `toml:"default"`
+		Description string      `toml:"description"`
+	} `toml:"settings"`
+}
+
+func loadFile(path string, source string) (*Builtin, error) {
+	var def builtinFile
+	md, err := toml.DecodeFile(path, &def)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing builtin %s: %w", path, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("error parsing builtin %s: unknown key %s", path, undecoded[0])
+	}
+
+	if def.Name == "" {
+		// name it after the file, or the directory for builtin.toml
+		def.Name = strings.TrimSuffix(filepath.Base(path), ".toml")
+		if def.Name == "builtin" {
+			def.Name = filepath.Base(filepath.Dir(path))
+		}
+	}
+
+	switch {
+	case def.Template != "" && def.Dockerfile != "":
+		return nil, fmt.Errorf("builtin %s sets both template and dockerfile", path)
+	case def.Dockerfile != "":
+		data, err := os.ReadFile(filepath.Join(filepath.Dir(path), def.Dockerfile))
+		if err != nil {
+			return nil, fmt.Errorf("error reading template of builtin %s: %w", def.Name, err)
+		}
+		def.Template = string(data)
+	case def.Template == "":
+		return nil, fmt.Errorf("builtin %s has no template or dockerfile", path)
+	}
+
+	b := &Builtin{
+		Name:        def.Name,
+		Description: def.Description,
+		Details:     def.Details,
+		Template:    def.Template,
+		Source:      source,
+	}
+	for _, s := range def.Settings {
+		b.Settings = append(b.Settings, Setting{Name: s.Name, Default: s.Default, Description: s.Description})
+	}
+
+	return b, nil
+}
@@ -0,0 +1,103 @@
+package builtins
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func TestDefaultsValidate(t *testing.T) {
+	for _, b := range Defaults().List() {
+		assert.Empty(t, b.Validate(), b.Name)
+		assert.Equal(t, DefaultSource, b.Source)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "builtins", "node.toml"), `
+description = "Our node image"
+template = "FROM registry.example.com/node:{{ .version }}"
+
+[[settings]]
+name = "version"
+default = "16"
+description = "Node.js version"
+`)
+	writeFile(t, filepath.Join(dir, "builtins", "go-service", "builtin.toml"), `
+description = "Go services"
+dockerfile = "Dockerfile.tmpl"
+`)
+	writeFile(t, filepath.Join(dir, "builtins", "go-service", "Dockerfile.tmpl"), "FROM golang:1.17\nRUN go build -o /app .\n")
+	writeFile(t, filepath.Join(dir, "builtins", "README.md"), "not a builtin")
+
+	catalog, err := Load(context.Background(), dir, "builtins")
+	require.NoError(t, err)
+
+	node, err := catalog.Get("node")
+	require.NoError(t, err)
+	assert.Equal(t, "Our node image", node.Description)
+	assert.Equal(t, "builtins", node.Source)
+	assert.Empty(t, node.Validate())
+
+	dockerfile, err := node.GetVDockerfile(map[string]interface{}{"version": "17"})
+	require.NoError(t, err)
+	assert.Equal(t, "FROM registry.example.com/node:17", dockerfile)
+
+	goService, err := catalog.Get("go-service")
+	require.NoError(t, err)
+	assert.Contains(t, goService.Template, "go build")
+
+	python, err := catalog.Get("python")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultSource, python.Source)
+
+	assert.Len(t, catalog.List(), len(basicbuiltins)+1)
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := Load(context.Background(), dir, "missing")
+	assert.Error(t, err)
+
+	writeFile(t, filepath.Join(dir, "empty.toml"), `description = "no template"`)
+	_, err = Load(context.Background(), dir, "empty.toml")
+	assert.Error(t, err)
+
+	writeFile(t, filepath.Join(dir, "typo.toml"), "template = \"FROM alpine\"\n[[setting]]\nname = \"x\"\n")
+	_, err = Load(context.Background(), dir, "typo.toml")
+	assert.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	b := &Builtin{
+		Name:     "Bad Name",
+		Template: "RUN echo {{ .missing }}",
+		Settings: []Setting{{Name: "a"}, {Name: "a"}},
+	}
+	assert.Len(t, b.Validate(), 3)
+
+	b = &Builtin{Name: "nofrom", Template: "RUN echo hi"}
+	assert.Len(t, b.Validate(), 1)
+
+	b = &Builtin{Name: "broken", Template: "FROM {{ .x "}
+	assert.Len(t, b.Validate(), 1)
+}
+
+func TestIsGitSource(t *testing.T) {
+	assert.True(t, isGitSource("https://github.com/example/builtins"))
+	assert.True(t, isGitSource("git@github.com:example/builtins.git#main:builtins"))
+	assert.True(t, isGitSource("../builtins.git"))
+	assert.False(t, isGitSource("./builtins"))
+	assert.False(t, isGitSource("/etc/flyctl/builtins"))
+}
@@ -0,0 +1,60 @@
+package builtins
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// Validate checks the builtin renders to a usable Dockerfile with its default settings, returning every problem found
+func (b *Builtin) Validate() []error {
+	problems := []error{}
+
+	if !validName.MatchString(b.Name) {
+		problems = append(problems, fmt.Errorf("name %q must be lowercase letters, digits, '.', '_' or '-'", b.Name))
+	}
+
+	seen := map[string]bool{}
+	for _, s := range b.Settings {
+		switch {
+		case s.Name == "":
+			problems = append(problems, fmt.Errorf("a setting has no name"))
+		case seen[s.Name]:
+			problems = append(problems, fmt.Errorf("setting %s is defined more than once", s.Name))
+		}
+		seen[s.Name] = true
+	}
+
+	// settings the template uses but doesn't declare would render as <no value>
+	tmpl, err := template.New(b.Name).Option("missingkey=error").Parse(b.Template)
+	if err != nil {
+		return append(problems, fmt.Errorf("template doesn't parse: %w", err))
+	}
+
+	rendered := strings.Builder{}
+	if err := tmpl.Execute(&rendered, b.ResolveSettings(nil)); err != nil {
+		return append(problems, fmt.Errorf("template doesn't render with the default settings: %w", err))
+	}
+
+	result, err := parser.Parse(strings.NewReader(rendered.String()))
+	if err != nil {
+		return append(problems, fmt.Errorf("rendered Dockerfile doesn't parse: %w", err))
+	}
+
+	hasFrom := false
+	for _, node := range result.AST.Children {
+		if strings.EqualFold(node.Value, "from") {
+			hasFrom = true
+		}
+	}
+	if !hasFrom {
+		problems = append(problems, fmt.Errorf("rendered Dockerfile has no FROM instruction"))
+	}
+
+	return problems
+}
@@ -0,0 +1,226 @@
+package imgsrc
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/pkg/fileutils"
+	"github.com/dustin/go-humanize"
+	"github.com/sammccord/flyctl/internal/sourcecode"
+)
+
+const contextReportLimit = 10
+
+// ContextReport describes what a build would upload from a working directory
+type ContextReport struct {
+	Root           string          `json:"root"`
+	Files          int             `json:"files"`
+	Size           int64           `json:"size"`
+	CompressedSize int64           `json:"compressed_size"`
+	LargestFiles   []ContextEntry  `json:"largest_files"`
+	LargestDirs    []ContextEntry  `json:"largest_dirs"`
+	Rules          []IgnoreRule    `json:"rules"`
+	Suggestions    []ContextAdvice `json:"suggestions"`
+}
+
+// ContextEntry is a file or top level directory in the build context
+type ContextEntry struct {
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	Files int    `json:"files,omitempty"`
+}
+
+// IgnoreRule is a .dockerignore pattern and the files it decided on
+type IgnoreRule struct {
+	Pattern string `json:"pattern"`
+	Files   int    `json:"files"`
+	Size    int64  `json:"size"`
+}
+
+// ContextAdvice is a suggested exclusion and how much it would remove from the context
+type ContextAdvice struct {
+	Pattern string `json:"pattern"`
+	Reason  string `json:"reason"`
+	Files   int    `json:"files"`
+	Size    int64  `json:"size"`
+}
+
+// NewContextReport walks workingDir the way the build context is archived, applying the ignore file
+// for dockerfile, or for the Dockerfile found in workingDir when it's empty
+func NewContextReport(workingDir, dockerfile string) (*ContextReport, error) {
+	if dockerfile == "" {
+		dockerfile = resolveDockerfile(workingDir)
+	}
+
+	excludes, err := readDockerignore(workingDir, dockerfile)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ContextReport{Root: workingDir}
+
+	rules := make([]IgnoreRule, len(excludes))
+	matchers := make([]*fileutils.PatternMatcher, len(excludes))
+	for i, pattern := range excludes {
+		rules[i].Pattern = pattern
+		if matchers[i], err = fileutils.NewPatternMatcher([]string{strings.TrimPrefix(pattern, "!")}); err != nil {
+			return nil, err
+		}
+	}
+
+	suggestions := []ContextAdvice{}
+	suggestionMatchers := []*fileutils.PatternMatcher{}
+	for _, e := range sourcecode.SuggestExclusions(workingDir) {
+		if matched, _ := fileutils.Matches(e.Pattern, excludes); matched {
+			continue
+		}
+		pm, err := fileutils.NewPatternMatcher([]string{e.Pattern})
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, ContextAdvice{Pattern: e.Pattern, Reason: e.Reason})
+		suggestionMatchers = append(suggestionMatchers, pm)
+	}
+
+	files := []ContextEntry{}
+	dirs := map[string]*ContextEntry{}
+
+	err = filepath.Walk(workingDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(workingDir, p)
+		if err != nil {
+			return err
+		}
+
+		// as in docker, the last matching pattern decides whether a file is excluded
+		decided := -1
+		for i, pm := range matchers {
+			if matched, _ := pm.Matches(rel); matched {
+				decided = i
+			}
+		}
+		if decided >= 0 {
+			rules[decided].Files++
+			rules[decided].Size += info.Size()
+			if !strings.HasPrefix(rules[decided].Pattern, "!") {
+				return nil
+			}
+		}
+
+		report.Files++
+		report.Size += info.Size()
+		files = append(files, ContextEntry{Path: filepath.ToSlash(rel), Size: info.Size()})
+
+		if top := strings.SplitN(filepath.ToSlash(rel), "/", 2); len(top) == 2 {
+			dir, ok := dirs[top[0]]
+			if !ok {
+				dir = &ContextEntry{Path: top[0] + "/"}
+				dirs[top[0]] = dir
+			}
+			dir.Size += info.Size()
+			dir.Files++
+		}
+
+		for i, pm := range suggestionMatchers {
+			if matched, _ := pm.Matches(rel); matched {
+				suggestions[i].Files++
+				suggestions[i].Size += info.Size()
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report.LargestFiles = largestEntries(files)
+
+	dirEntries := make([]ContextEntry, 0, len(dirs))
+	for _, d := range dirs {
+		dirEntries = append(dirEntries, *d)
+	}
+	report.LargestDirs = largestEntries(dirEntries)
+
+	report.Rules = rules
+
+	report.Suggestions = []ContextAdvice{}
+	for _, s := range suggestions {
+		if s.Files > 0 {
+			report.Suggestions = append(report.Suggestions, s)
+		}
+	}
+	sort.SliceStable(report.Suggestions, func(i, j int) bool { return report.Suggestions[i].Size > report.Suggestions[j].Size })
+
+	r, err := archiveDirectory(archiveOptions{sourcePath: workingDir, exclusions: excludes, compressed: true})
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if report.CompressedSize, err = io.Copy(io.Discard, r); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func largestEntries(entries []ContextEntry) []ContextEntry {
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Size > entries[j].Size })
+	if len(entries) > contextReportLimit {
+		entries = entries[:contextReportLimit]
+	}
+	return entries
+}
+
+// Fprint renders the report as human readable text
+func (r *ContextReport) Fprint(w io.Writer) {
+	fmt.Fprintf(w, "Build context %s\n", r.Root)
+	fmt.Fprintf(w, "  %d files, %s (%s compressed)\n", r.Files, humanize.Bytes(uint64(r.Size)), humanize.Bytes(uint64(r.CompressedSize)))
+
+	fmt.Fprintln(w, "\nLargest directories:")
+	if len(r.LargestDirs) == 0 {
+		fmt.Fprintln(w, "  none")
+	}
+	for _, e := range r.LargestDirs {
+		fmt.Fprintf(w, "  %10s  %s (%d files)\n", humanize.Bytes(uint64(e.Size)), e.Path, e.Files)
+	}
+
+	fmt.Fprintln(w, "\nLargest files:")
+	if len(r.LargestFiles) == 0 {
+		fmt.Fprintln(w, "  none")
+	}
+	for _, e := range r.LargestFiles {
+		fmt.Fprintf(w, "  %10s  %s\n", humanize.Bytes(uint64(e.Size)), e.Path)
+	}
+
+	fmt.Fprintln(w, "\nIgnore rules:")
+	if len(r.Rules) == 0 {
+		fmt.Fprintln(w, "  none, add a .dockerignore file to exclude files")
+	}
+	for _, rule := range r.Rules {
+		verb := "excluded"
+		if strings.HasPrefix(rule.Pattern, "!") {
+			verb = "included"
+		}
+		fmt.Fprintf(w, "  %-30s %s %d files, %s\n", rule.Pattern, verb, rule.Files, humanize.Bytes(uint64(rule.Size)))
+	}
+
+	if len(r.Suggestions) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "\nSuggested .dockerignore additions:")
+	for _, s := range r.Suggestions {
+		fmt.Fprintf(w, "  %-30s saves %s in %d files (%s)\n", s.Pattern, humanize.Bytes(uint64(s.Size)), s.Files, s.Reason)
+	}
+}
@@ -0,0 +1,43 @@
+package imgsrc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewContextReport(t *testing.T) {
+	dir := writeContext(t, map[string]string{
+		".dockerignore":               "*.log\n!keep.log\n",
+		"package.json":                "{}",
+		"index.js":                    "console.log('hi')",
+		"debug.log":                   strings.Repeat("x", 100),
+		"keep.log":                    "kept",
+		"node_modules/left-pad/index": strings.Repeat("x", 1000),
+		".git/HEAD":                   "ref: refs/heads/main",
+	})
+
+	report, err := NewContextReport(dir, "")
+	require.NoError(t, err)
+
+	assert.Equal(t, 6, report.Files)
+	assert.Greater(t, report.CompressedSize, int64(0))
+	assert.Equal(t, "node_modules/", report.LargestDirs[0].Path)
+	assert.Equal(t, "node_modules/left-pad/index", report.LargestFiles[0].Path)
+
+	assert.Equal(t, IgnoreRule{Pattern: "*.log", Files: 1, Size: 100}, report.Rules[0])
+	assert.Equal(t, IgnoreRule{Pattern: "!keep.log", Files: 1, Size: 4}, report.Rules[1])
+
+	patterns := []string{}
+	for _, s := range report.Suggestions {
+		patterns = append(patterns, s.Pattern)
+	}
+	assert.Equal(t, []string{"node_modules", ".git"}, patterns)
+
+	var buf bytes.Buffer
+	report.Fprint(&buf)
+	assert.Contains(t, buf.String(), "Suggested .dockerignore additions:")
+}
@@ -0,0 +1,37 @@
+package imgsrc
+
+import (
+	"path/filepath"
+
+	"github.com/moby/buildkit/session/filesync"
+	fstypes "github.com/tonistiigi/fsutil/types"
+)
+
+// contextSyncedDirs exposes the build context and the Dockerfile's directory to the builder over the build session.
+// The builder keeps the context from previous builds with the same session shared key. flyctl sends it the list of
+// files with their size, mode and modification time, and the builder requests the contents of the ones that differ
+// from its copy, so repeated deploys upload just what was edited. Files are compared by metadata, not content
+// hashes, so a file that is touched without being changed is sent again.
+func contextSyncedDirs(contextDir string, dockerfile string, excludes []string) []filesync.SyncedDir {
+	return []filesync.SyncedDir{
+		{
+			Name:     "context",
+			Dir:      contextDir,
+			Excludes: excludes,
+			Map:      resetOwnership,
+		},
+		{
+			Name: "dockerfile",
+			Dir:  filepath.Dir(dockerfile),
+			Map:  resetOwnership,
+		},
+	}
+}
+
+// resetOwnership makes synced files owned by root, like files in a tar context,
+// so local uids don't invalidate the builder's copy or leak into the image
+func resetOwnership(_ string, st *fstypes.Stat) bool {
+	st.Uid = 0
+	st.Gid = 0
+	return true
+}
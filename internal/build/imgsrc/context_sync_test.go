@@ -0,0 +1,135 @@
+package imgsrc
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moby/buildkit/session/filesync"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tonistiigi/fsutil"
+	fstypes "github.com/tonistiigi/fsutil/types"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestContextSyncedDirs(t *testing.T) {
+	dirs := contextSyncedDirs("/app", "/app/docker/Dockerfile", []string{"node_modules"})
+
+	assert.Len(t, dirs, 2)
+	assert.Equal(t, "context", dirs[0].Name)
+	assert.Equal(t, "/app", dirs[0].Dir)
+	assert.Equal(t, []string{"node_modules"}, dirs[0].Excludes)
+	assert.Equal(t, "dockerfile", dirs[1].Name)
+	assert.Equal(t, filepath.Dir("/app/docker/Dockerfile"), dirs[1].Dir)
+
+	st := &fstypes.Stat{Uid: 501, Gid: 20}
+	assert.True(t, dirs[0].Map("index.js", st))
+	assert.Equal(t, uint32(0), st.Uid)
+	assert.Equal(t, uint32(0), st.Gid)
+}
+
+// packetPipe is one end of an in-memory filesync stream, counting the file data it sends
+type packetPipe struct {
+	ctx  context.Context
+	in   <-chan *fstypes.Packet
+	out  chan<- *fstypes.Packet
+	sent int
+}
+
+func newPacketPipes(ctx context.Context) (*packetPipe, *packetPipe) {
+	a, b := make(chan *fstypes.Packet, 128), make(chan *fstypes.Packet, 128)
+	return &packetPipe{ctx: ctx, in: a, out: b}, &packetPipe{ctx: ctx, in: b, out: a}
+}
+
+func (p *packetPipe) Context() context.Context {
+	return p.ctx
+}
+
+func (p *packetPipe) SendMsg(m interface{}) error {
+	// the sender reuses its buffers once SendMsg returns
+	pkt := *m.(*fstypes.Packet)
+	pkt.Data = append([]byte(nil), pkt.Data...)
+	if pkt.Type == fstypes.PACKET_DATA {
+		p.sent += len(pkt.Data)
+	}
+	select {
+	case p.out <- &pkt:
+		return nil
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+}
+
+// close ends the stream like a finished gRPC call, the other end receives io.EOF
+func (p *packetPipe) close() {
+	close(p.out)
+}
+
+func (p *packetPipe) RecvMsg(m interface{}) error {
+	select {
+	case pkt, ok := <-p.in:
+		if !ok {
+			return io.EOF
+		}
+		*m.(*fstypes.Packet) = *pkt
+		return nil
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+}
+
+// syncContext sends dir to dest like a builder fetching the context over the build session, returning the bytes of
+// file data sent
+func syncContext(t *testing.T, dir filesync.SyncedDir, dest string) int {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, builder := newPacketPipes(ctx)
+	// the test may not run as root, so files keep the current owner instead of the root owner of builds
+	walk := &fsutil.WalkOpt{ExcludePatterns: dir.Excludes, Map: func(path string, st *fstypes.Stat) bool {
+		ok := dir.Map(path, st)
+		st.Uid, st.Gid = uint32(os.Getuid()), uint32(os.Getgid())
+		return ok
+	}}
+
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.Go(func() error {
+		defer client.close()
+		return fsutil.Send(ctx, client, fsutil.NewFS(dir.Dir, walk), nil)
+	})
+	eg.Go(func() error {
+		return fsutil.Receive(ctx, builder, dest, fsutil.ReceiveOpt{})
+	})
+	require.NoError(t, eg.Wait())
+
+	return client.sent
+}
+
+func TestContextSyncSkipsUnchangedFiles(t *testing.T) {
+	dir := writeContext(t, map[string]string{
+		"Dockerfile":           "FROM scratch\n",
+		"server.js":            "console.log('hi')\n",
+		"public/app.js":        string(make([]byte, 64*1024)),
+		"node_modules/left.js": "module.exports = 1\n",
+	})
+	dirs := contextSyncedDirs(dir, filepath.Join(dir, "Dockerfile"), []string{"node_modules"})
+	dest := t.TempDir()
+
+	first := syncContext(t, dirs[0], dest)
+	assert.Equal(t, len("FROM scratch\n")+len("console.log('hi')\n")+64*1024, first)
+	assert.NoFileExists(t, filepath.Join(dest, "node_modules/left.js"))
+
+	assert.Equal(t, 0, syncContext(t, dirs[0], dest), "nothing changed, nothing is sent")
+
+	changed := "console.log('hello')\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "server.js"), []byte(changed), 0644))
+	assert.Equal(t, len(changed), syncContext(t, dirs[0], dest), "only the edited file is sent")
+
+	data, err := os.ReadFile(filepath.Join(dest, "server.js"))
+	require.NoError(t, err)
+	assert.Equal(t, changed, string(data))
+}
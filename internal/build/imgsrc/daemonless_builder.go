@@ -0,0 +1,564 @@
+package imgsrc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/moby/buildkit/frontend/dockerfile/shell"
+	"github.com/pkg/errors"
+	"github.com/sammccord/flyctl/helpers"
+	"github.com/sammccord/flyctl/internal/cmdfmt"
+	"github.com/sammccord/flyctl/pkg/iostreams"
+	"github.com/sammccord/flyctl/terminal"
+)
+
+// daemonlessBuilder assembles images without a docker daemon. It handles Dockerfiles that only copy files
+// from the build context onto a base image, which covers static sites and prebuilt binaries.
+type daemonlessBuilder struct{}
+
+func (ds *daemonlessBuilder) Name() string {
+	return "Daemonless"
+}
+
+func (ds *daemonlessBuilder) Run(ctx context.Context, dockerFactory *dockerClientFactory, streams *iostreams.IOStreams, opts ImageOptions) (*DeploymentImage, error) {
+	if dockerFactory.mode.IsAvailable() {
+		terminal.Debug("docker daemon available, skipping")
+		return nil, nil
+	}
+
+	if opts.AppConfig.HasBuilder() {
+		return nil, errors.New("buildpacks require a docker daemon or a remote builder")
+	}
+
+	if hasBuildSecrets(opts) {
+		return nil, errBuildSecretsUnsupported
+	}
+
+	var dockerfile []byte
+	var dockerfilePath string
+	if opts.AppConfig.HasBuiltin() {
+		builtin, err := opts.builtin()
+		if err != nil {
+			return nil, err
+		}
+		vdockerfile, err := builtin.GetVDockerfile(opts.AppConfig.Build.Settings)
+		if err != nil {
+			return nil, err
+		}
+		dockerfile = []byte(vdockerfile)
+	} else {
+		dockerfilePath = opts.DockerfilePath
+		if dockerfilePath == "" {
+			dockerfilePath = resolveDockerfile(opts.WorkingDir)
+		} else if !helpers.FileExists(dockerfilePath) {
+			return nil, fmt.Errorf("Dockerfile '%s' not found", dockerfilePath)
+		}
+		if dockerfilePath == "" {
+			terminal.Debug("dockerfile not found, skipping")
+			return nil, nil
+		}
+
+		data, err := os.ReadFile(dockerfilePath)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading Dockerfile")
+		}
+		dockerfile = data
+	}
+
+	excludes, err := readDockerignore(opts.WorkingDir, dockerfilePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading .dockerignore")
+	}
+
+	namedContexts, err := loadNamedContexts(opts.BuildContexts)
+	if err != nil {
+		return nil, err
+	}
+
+	scratchDir, err := os.MkdirTemp("", "flyctl-build-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(scratchDir)
+
+	platforms, err := imagePlatforms(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	cmdfmt.PrintBegin(streams.ErrOut, "Building image without Docker")
+
+	images := make([]v1.Image, len(platforms))
+	for i, platform := range platforms {
+		b := &daemonlessBuild{
+			ctx:        ctx,
+			platform:   platform,
+			contextDir: opts.WorkingDir,
+			scratchDir: scratchDir,
+			excludes:   excludes,
+			contexts:   namedContexts,
+			buildArgs:  buildArgsMap(opts),
+			labels:     opts.Labels,
+			lex:        shell.NewLex('\\'),
+			now:        time.Now().UTC(),
+		}
+
+		if images[i], err = b.build(dockerfile, opts.Target); err != nil {
+			return nil, errors.Wrapf(err, "error building for %s", platformString(platform))
+		}
+	}
+
+	cmdfmt.PrintDone(streams.ErrOut, "Building image done")
+
+	if len(platforms) > 1 {
+		return publishIndex(ctx, streams, opts, platforms, images)
+	}
+	img := images[0]
+
+	if opts.OutputPath != "" {
+		cmdfmt.PrintBegin(streams.ErrOut, "Writing image to", opts.OutputPath)
+		if err := writeImageOutput(img, opts.Tag, opts.OutputPath); err != nil {
+			return nil, errors.Wrap(err, "error writing image")
+		}
+		cmdfmt.PrintDone(streams.ErrOut, "Writing image done")
+	}
+
+	if opts.Publish {
+		cmdfmt.PrintBegin(streams.ErrOut, "Pushing image to fly")
+		if err := pushImage(ctx, img, opts.Tag); err != nil {
+			return nil, err
+		}
+		cmdfmt.PrintDone(streams.ErrOut, "Pushing image done")
+	}
+
+	return newDeploymentImage(img, opts.Tag)
+}
+
+// publishIndex writes and pushes the manifest list of a multi-platform build
+func publishIndex(ctx context.Context, streams *iostreams.IOStreams, opts ImageOptions, platforms []v1.Platform, images []v1.Image) (*DeploymentImage, error) {
+	var idx v1.ImageIndex = empty.Index
+	deployed := images[0]
+	for i, img := range images {
+		platform := platforms[i]
+		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: &platform},
+		})
+		if checkDeployPlatform(opts.Tag, platform) == nil {
+			deployed = img
+		}
+	}
+
+	if opts.OutputPath != "" {
+		cmdfmt.PrintBegin(streams.ErrOut, "Writing image to", opts.OutputPath)
+		if err := writeIndexOutput(idx, opts.Tag, opts.OutputPath); err != nil {
+			return nil, errors.Wrap(err, "error writing image")
+		}
+		cmdfmt.PrintDone(streams.ErrOut, "Writing image done")
+	}
+
+	if opts.Publish {
+		cmdfmt.PrintBegin(streams.ErrOut, "Pushing manifest list to fly")
+		if err := pushIndex(ctx, idx, opts.Tag); err != nil {
+			return nil, err
+		}
+		cmdfmt.PrintDone(streams.ErrOut, "Pushing manifest list done")
+	}
+
+	di, err := newDeploymentImage(deployed, opts.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	digest, err := idx.Digest()
+	if err != nil {
+		return nil, err
+	}
+	di.ID = digest.String()
+
+	return di, nil
+}
+
+func buildArgsMap(opts ImageOptions) map[string]string {
+	out := map[string]string{}
+	if opts.AppConfig.Build != nil {
+		for k, v := range opts.AppConfig.Build.Args {
+			out[k] = v
+		}
+	}
+	for k, v := range opts.ExtraBuildArgs {
+		out[k] = v
+	}
+	return out
+}
+
+type daemonlessBuild struct {
+	ctx        context.Context
+	platform   v1.Platform
+	contextDir string
+	scratchDir string
+	excludes   []string
+	contexts   []namedContext
+	buildArgs  map[string]string
+	labels     map[string]string
+	lex        *shell.Lex
+	now        time.Time
+
+	args    map[string]string
+	config  v1.Config
+	shell   []string
+	adds    []mutate.Addendum
+	history []v1.History
+}
+
+func (b *daemonlessBuild) build(dockerfile []byte, target string) (v1.Image, error) {
+	result, err := parser.Parse(bytes.NewReader(dockerfile))
+	if err != nil {
+		return nil, err
+	}
+
+	stages, metaArgs, err := instructions.Parse(result.AST)
+	if err != nil {
+		return nil, err
+	}
+
+	stage, err := selectStage(stages, target)
+	if err != nil {
+		return nil, err
+	}
+
+	b.args = map[string]string{}
+	for _, arg := range metaArgs {
+		for _, kv := range arg.Args {
+			b.declareArg(kv)
+		}
+	}
+
+	baseName, err := b.lex.ProcessWordWithMap(stage.BaseName, b.args)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := b.namedContext(baseName); ok {
+		return nil, fmt.Errorf("build context %s can only be used with COPY --from", baseName)
+	}
+
+	base, err := b.baseImage(baseName)
+	if err != nil {
+		return nil, err
+	}
+
+	baseConfig, err := base.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	b.config = *baseConfig.Config.DeepCopy()
+	b.shell = []string{"/bin/sh", "-c"}
+
+	// ARGs declared before FROM are only in scope for FROM itself
+	b.args = map[string]string{}
+
+	for _, cmd := range stage.Commands {
+		if err := b.apply(cmd); err != nil {
+			return nil, err
+		}
+	}
+	for k, v := range b.labels {
+		if b.config.Labels == nil {
+			b.config.Labels = map[string]string{}
+		}
+		b.config.Labels[k] = v
+	}
+
+	img, err := mutate.Append(base, b.adds...)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	cfg = cfg.DeepCopy()
+	cfg.Config = b.config
+	cfg.Created = v1.Time{Time: b.now}
+	cfg.History = append(baseConfig.History, b.history...)
+	if cfg.OS == "" {
+		cfg.OS = b.platform.OS
+		cfg.Architecture = b.platform.Architecture
+	}
+
+	return mutate.ConfigFile(img, cfg)
+}
+
+func selectStage(stages []instructions.Stage, target string) (*instructions.Stage, error) {
+	if len(stages) == 0 {
+		return nil, errors.New("Dockerfile has no FROM instruction")
+	}
+
+	stage := &stages[len(stages)-1]
+	if target != "" {
+		stage = nil
+		for i := range stages {
+			if strings.EqualFold(stages[i].Name, target) {
+				stage = &stages[i]
+				break
+			}
+		}
+		if stage == nil {
+			return nil, fmt.Errorf("target stage %s not found", target)
+		}
+	}
+
+	for _, s := range stages {
+		if s.Name != "" && strings.EqualFold(s.Name, stage.BaseName) {
+			return nil, errUnsupported("FROM " + stage.BaseName + " (multi-stage builds)")
+		}
+	}
+
+	return stage, nil
+}
+
+func (b *daemonlessBuild) baseImage(ref string) (v1.Image, error) {
+	if ref == "scratch" {
+		return empty.Image, nil
+	}
+
+	img, err := pullImage(b.ctx, ref, b.platform)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error fetching base image %s", ref)
+	}
+	return img, nil
+}
+
+func (b *daemonlessBuild) declareArg(kv instructions.KeyValuePairOptional) {
+	if v, ok := b.buildArgs[kv.Key]; ok {
+		b.args[kv.Key] = v
+	} else {
+		b.args[kv.Key] = kv.ValueString()
+	}
+}
+
+// env returns the variables available for expansion. ENV takes precedence over ARG.
+func (b *daemonlessBuild) env() map[string]string {
+	out := map[string]string{}
+	for k, v := range b.args {
+		out[k] = v
+	}
+	for k, v := range shell.BuildEnvs(b.config.Env) {
+		out[k] = v
+	}
+	return out
+}
+
+func (b *daemonlessBuild) apply(cmd instructions.Command) error {
+	if e, ok := cmd.(instructions.SupportsSingleWordExpansion); ok {
+		env := b.env()
+		err := e.Expand(func(word string) (string, error) {
+			return b.lex.ProcessWordWithMap(word, env)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	switch c := cmd.(type) {
+	case *instructions.ArgCommand:
+		for _, kv := range c.Args {
+			b.declareArg(kv)
+		}
+	case *instructions.EnvCommand:
+		for _, kv := range c.Env {
+			b.setEnv(kv.Key, kv.Value)
+		}
+	case *instructions.LabelCommand:
+		if b.config.Labels == nil {
+			b.config.Labels = map[string]string{}
+		}
+		for _, kv := range c.Labels {
+			b.config.Labels[kv.Key] = kv.Value
+		}
+	case *instructions.MaintainerCommand:
+		// deprecated and not recorded in the image config
+	case *instructions.WorkdirCommand:
+		b.config.WorkingDir = b.resolvePath(c.Path)
+	case *instructions.UserCommand:
+		b.config.User = c.User
+	case *instructions.ExposeCommand:
+		if b.config.ExposedPorts == nil {
+			b.config.ExposedPorts = map[string]struct{}{}
+		}
+		for _, port := range c.Ports {
+			if !strings.Contains(port, "/") {
+				port += "/tcp"
+			}
+			b.config.ExposedPorts[port] = struct{}{}
+		}
+	case *instructions.VolumeCommand:
+		if b.config.Volumes == nil {
+			b.config.Volumes = map[string]struct{}{}
+		}
+		for _, v := range c.Volumes {
+			b.config.Volumes[v] = struct{}{}
+		}
+	case *instructions.StopSignalCommand:
+		b.config.StopSignal = c.Signal
+	case *instructions.ShellCommand:
+		b.shell = append([]string{}, c.Shell...)
+	case *instructions.CmdCommand:
+		b.config.Cmd = b.commandLine(c.ShellDependantCmdLine)
+	case *instructions.EntrypointCommand:
+		b.config.Entrypoint = b.commandLine(c.ShellDependantCmdLine)
+		// like docker, ENTRYPOINT resets a CMD inherited from the base image
+		b.config.Cmd = nil
+	case *instructions.HealthCheckCommand:
+		b.config.Healthcheck = &v1.HealthConfig{
+			Test:        c.Health.Test,
+			Interval:    c.Health.Interval,
+			Timeout:     c.Health.Timeout,
+			StartPeriod: c.Health.StartPeriod,
+			Retries:     c.Health.Retries,
+		}
+	case *instructions.CopyCommand:
+		if c.From != "" {
+			named, ok := b.namedContext(c.From)
+			if !ok {
+				return errUnsupported("COPY --from")
+			}
+			return b.copy(c.String(), named.path, named.exclusions, c.SourcesAndDest, c.Chown, c.Chmod, false)
+		}
+		return b.copy(c.String(), b.contextDir, b.excludes, c.SourcesAndDest, c.Chown, c.Chmod, false)
+	case *instructions.AddCommand:
+		return b.copy(c.String(), b.contextDir, b.excludes, c.SourcesAndDest, c.Chown, c.Chmod, true)
+	default:
+		return errUnsupported(strings.ToUpper(cmd.Name()))
+	}
+
+	b.history = append(b.history, v1.History{
+		Created:    v1.Time{Time: b.now},
+		CreatedBy:  createdBy(cmd),
+		EmptyLayer: true,
+	})
+
+	return nil
+}
+
+func (b *daemonlessBuild) setEnv(key, value string) {
+	prefix := key + "="
+	for i, kv := range b.config.Env {
+		if strings.HasPrefix(kv, prefix) {
+			b.config.Env[i] = prefix + value
+			return
+		}
+	}
+	b.config.Env = append(b.config.Env, prefix+value)
+}
+
+func (b *daemonlessBuild) commandLine(cmd instructions.ShellDependantCmdLine) []string {
+	if !cmd.PrependShell {
+		return append([]string{}, cmd.CmdLine...)
+	}
+	return append(append([]string{}, b.shell...), strings.Join(cmd.CmdLine, " "))
+}
+
+// resolvePath makes p absolute relative to the current WORKDIR
+func (b *daemonlessBuild) resolvePath(p string) string {
+	if path.IsAbs(p) {
+		return path.Clean(p)
+	}
+	wd := b.config.WorkingDir
+	if wd == "" {
+		wd = "/"
+	}
+	return path.Join(wd, p)
+}
+
+func (b *daemonlessBuild) namedContext(name string) (namedContext, bool) {
+	for _, c := range b.contexts {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return namedContext{}, false
+}
+
+// copy adds a layer with sources from contextDir, or inline heredoc contents
+func (b *daemonlessBuild) copy(code, contextDir string, excludes []string, sd instructions.SourcesAndDest, chown, chmod string, isAdd bool) error {
+	dest := sd.DestPath
+	isDir := strings.HasSuffix(dest, "/") || dest == "." || len(sd.SourcePaths)+len(sd.SourceContents) > 1
+	dest = b.resolvePath(dest)
+
+	lw, err := newLayerWriter(b.scratchDir, chown, chmod, b.now)
+	if err != nil {
+		return err
+	}
+
+	for _, src := range sd.SourcePaths {
+		if isAdd && (isURL(src) || isArchive(src)) {
+			lw.Close()
+			return errUnsupported("ADD of urls and archives")
+		}
+		if err := lw.addFromContext(contextDir, excludes, src, dest, isDir); err != nil {
+			lw.Close()
+			return err
+		}
+	}
+
+	for _, content := range sd.SourceContents {
+		target := dest
+		if isDir {
+			target = path.Join(dest, content.Path)
+		}
+		if err := lw.addContent(target, []byte(content.Data)); err != nil {
+			lw.Close()
+			return err
+		}
+	}
+
+	layer, err := lw.Layer()
+	if err != nil {
+		return err
+	}
+
+	b.adds = append(b.adds, mutate.Addendum{Layer: layer})
+	b.history = append(b.history, v1.History{
+		Created:   v1.Time{Time: b.now},
+		CreatedBy: code,
+	})
+
+	return nil
+}
+
+func createdBy(cmd instructions.Command) string {
+	if s, ok := cmd.(fmt.Stringer); ok {
+		return "/bin/sh -c #(nop) " + s.String()
+	}
+	return "/bin/sh -c #(nop) " + strings.ToUpper(cmd.Name())
+}
+
+func isURL(src string) bool {
+	return strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://")
+}
+
+func isArchive(src string) bool {
+	for _, ext := range []string{".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".tar.xz", ".txz"} {
+		if strings.HasSuffix(src, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func errUnsupported(instruction string) error {
+	return fmt.Errorf("%s is not supported without a docker daemon, use a local docker daemon or a remote builder", instruction)
+}
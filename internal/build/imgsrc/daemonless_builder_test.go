@@ -0,0 +1,187 @@
+package imgsrc
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/moby/buildkit/frontend/dockerfile/shell"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const scratchDockerfile = `
+ARG BASE=scratch
+FROM ${BASE}
+ARG VERSION=dev
+ENV APP_VERSION=$VERSION
+WORKDIR /app
+COPY public/ ./public/
+COPY --chown=1000:1000 server ./
+EXPOSE 8080
+CMD ["./server"]
+`
+
+func newTestBuild(t *testing.T, contextDir string) *daemonlessBuild {
+	return &daemonlessBuild{
+		ctx:        context.Background(),
+		platform:   defaultPlatform,
+		contextDir: contextDir,
+		scratchDir: t.TempDir(),
+		excludes:   []string{"public/*.map"},
+		buildArgs:  map[string]string{"VERSION": "1.2.3"},
+		lex:        shell.NewLex('\\'),
+		now:        time.Now().UTC(),
+	}
+}
+
+func writeContext(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, contents := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(contents), 0644))
+	}
+	return dir
+}
+
+func TestDaemonlessBuildFromScratch(t *testing.T) {
+	dir := writeContext(t, map[string]string{
+		"public/index.html": "<h1>hi</h1>",
+		"public/app.js.map": "{}",
+		"server":            "binary",
+	})
+
+	img, err := newTestBuild(t, dir).build([]byte(scratchDockerfile), "")
+	require.NoError(t, err)
+
+	cfg, err := img.ConfigFile()
+	require.NoError(t, err)
+	assert.Equal(t, "linux", cfg.OS)
+	assert.Equal(t, "amd64", cfg.Architecture)
+	assert.Equal(t, []string{"APP_VERSION=1.2.3"}, cfg.Config.Env)
+	assert.Equal(t, "/app", cfg.Config.WorkingDir)
+	assert.Equal(t, []string{"./server"}, cfg.Config.Cmd)
+	assert.Contains(t, cfg.Config.ExposedPorts, "8080/tcp")
+
+	layers, err := img.Layers()
+	require.NoError(t, err)
+	assert.Len(t, layers, 2)
+	assert.Len(t, cfg.History, 7)
+
+	files := map[string]*tar.Header{}
+	tr := tar.NewReader(mutate.Extract(img))
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		files[h.Name] = h
+	}
+
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"app/", "app/public/", "app/public/index.html", "app/server"}, names)
+	assert.Equal(t, 1000, files["app/server"].Uid)
+	assert.Equal(t, 0, files["app/public/index.html"].Uid)
+}
+
+func TestDaemonlessBuildLabels(t *testing.T) {
+	dir := writeContext(t, map[string]string{"server": "binary"})
+
+	b := newTestBuild(t, dir)
+	b.labels = map[string]string{"org.opencontainers.image.revision": "0123abcd"}
+	img, err := b.build([]byte("FROM scratch\nLABEL maintainer=ops\nCOPY server /server\n"), "")
+	require.NoError(t, err)
+
+	cfg, err := img.ConfigFile()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"maintainer": "ops", "org.opencontainers.image.revision": "0123abcd"}, cfg.Config.Labels)
+}
+
+func TestDaemonlessBuildUnsupported(t *testing.T) {
+	dir := writeContext(t, map[string]string{"main.go": "package main"})
+
+	_, err := newTestBuild(t, dir).build([]byte("FROM scratch\nRUN go build\n"), "")
+	assert.EqualError(t, err, "RUN is not supported without a docker daemon, use a local docker daemon or a remote builder")
+
+	_, err = newTestBuild(t, dir).build([]byte("FROM scratch AS build\nFROM build\n"), "")
+	assert.Error(t, err)
+
+	_, err = newTestBuild(t, dir).build([]byte("FROM scratch\nCOPY missing /\n"), "")
+	assert.Error(t, err)
+}
+
+func TestWriteImageOutput(t *testing.T) {
+	dir := writeContext(t, map[string]string{"index.html": "hi"})
+
+	img, err := newTestBuild(t, dir).build([]byte("FROM scratch\nCOPY index.html /\n"), "")
+	require.NoError(t, err)
+
+	out := t.TempDir()
+	tag := "registry.fly.io/test-app:deployment-1"
+
+	layoutPath := filepath.Join(out, "oci")
+	require.NoError(t, writeImageOutput(img, tag, layoutPath))
+	p, err := layout.FromPath(layoutPath)
+	require.NoError(t, err)
+	index, err := p.ImageIndex()
+	require.NoError(t, err)
+	manifest, err := index.IndexManifest()
+	require.NoError(t, err)
+	assert.Len(t, manifest.Manifests, 1)
+	assert.Equal(t, tag, manifest.Manifests[0].Annotations["org.opencontainers.image.ref.name"])
+
+	tarPath := filepath.Join(out, "image.tar")
+	require.NoError(t, writeImageOutput(img, tag, tarPath))
+	loaded, err := tarball.ImageFromPath(tarPath, nil)
+	require.NoError(t, err)
+
+	want, err := img.ConfigName()
+	require.NoError(t, err)
+	got, err := loaded.ConfigName()
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	deploymentImage, err := newDeploymentImage(img, tag)
+	require.NoError(t, err)
+	assert.Equal(t, want.String(), deploymentImage.ID)
+}
+
+func TestDaemonlessBuildNamedContext(t *testing.T) {
+	dir := writeContext(t, map[string]string{"index.html": "hi"})
+	shared := writeContext(t, map[string]string{"lib/util.js": "util", ".dockerignore": "lib/*.map", "lib/util.js.map": "{}"})
+
+	b := newTestBuild(t, dir)
+	contexts, err := loadNamedContexts(map[string]string{"shared": shared})
+	require.NoError(t, err)
+	b.contexts = contexts
+
+	img, err := b.build([]byte("FROM scratch\nCOPY --from=shared /lib /lib/\n"), "")
+	require.NoError(t, err)
+
+	names := []string{}
+	tr := tar.NewReader(mutate.Extract(img))
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, h.Name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"lib/", "lib/util.js"}, names)
+}
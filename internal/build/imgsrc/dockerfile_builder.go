@@ -8,6 +8,7 @@ import (
 	"io"
 	"net"
 	"os"
+	"path"
 	"path/filepath"
 	"time"
 
@@ -18,6 +19,8 @@ import (
 	"github.com/docker/docker/pkg/progress"
 	"github.com/docker/docker/pkg/streamformatter"
 	"github.com/docker/docker/pkg/stringid"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/moby/buildkit/session/filesync"
 	"github.com/moby/buildkit/util/progress/progressui"
 	"github.com/moby/term"
 	"github.com/pkg/errors"
@@ -78,56 +81,26 @@ func (ds *dockerfileBuilder) Run(ctx context.Context, dockerFactory *dockerClien
 		return nil, errors.Wrap(err, "error connecting to docker")
 	}
 
-	defer clearDeploymentTags(ctx, docker, opts.Tag)
-
-	cmdfmt.PrintBegin(streams.ErrOut, "Creating build context")
-	archiveOpts := archiveOptions{
-		sourcePath: opts.WorkingDir,
-		compressed: dockerFactory.mode.IsRemote(),
+	// images built with --build-only are kept, they aren't anywhere else
+	if opts.Publish {
+		defer clearDeploymentTags(ctx, docker, opts.Tag)
 	}
 
-	excludes, err := readDockerignore(opts.WorkingDir)
+	platforms, err := imagePlatforms(opts)
 	if err != nil {
-		return nil, errors.Wrap(err, "error reading .dockerignore")
+		return nil, err
 	}
-	archiveOpts.exclusions = excludes
-
-	var relativedockerfilePath string
 
-	// copy dockerfile into the archive if it's outside the context dir
-	if !isPathInRoot(dockerfile, opts.WorkingDir) {
-		dockerfileData, err := os.ReadFile(dockerfile)
-		if err != nil {
-			return nil, errors.Wrap(err, "error reading Dockerfile")
-		}
-		archiveOpts.additions = map[string][]byte{
-			"Dockerfile": dockerfileData,
-		}
-	} else {
-		// pass the relative path to Dockerfile within the context
-		p, err := filepath.Rel(opts.WorkingDir, dockerfile)
-		if err != nil {
-			return nil, err
-		}
-		relativedockerfilePath = p
-	}
-
-	r, err := archiveDirectory(archiveOpts)
+	excludes, err := readDockerignore(opts.WorkingDir, dockerfile)
 	if err != nil {
-		return nil, errors.Wrap(err, "error archiving build context")
+		return nil, errors.Wrap(err, "error reading .dockerignore")
 	}
-	cmdfmt.PrintDone(streams.ErrOut, "Creating build context done")
 
-	// Setup an upload progress bar
-	progressOutput := streamformatter.NewProgressOutput(streams.Out)
-	if !streams.IsStdoutTTY() {
-		progressOutput = &lastProgressOutput{output: progressOutput}
+	namedContexts, err := loadNamedContexts(opts.BuildContexts)
+	if err != nil {
+		return nil, err
 	}
 
-	r = progress.NewProgressReader(r, progressOutput, 0, "", "Sending build context to Docker daemon")
-
-	var imageID string
-
 	terminal.Debug("fetching docker server info")
 	serverInfo, err := func() (types.Info, error) {
 		infoCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
@@ -138,31 +111,111 @@ func (ds *dockerfileBuilder) Run(ctx context.Context, dockerFactory *dockerClien
 		return nil, errors.Wrap(err, "error fetching docker server info")
 	}
 
-	cmdfmt.PrintBegin(streams.ErrOut, "Building image with Docker")
-	msg := fmt.Sprintf("docker host: %s %s %s", serverInfo.ServerVersion, serverInfo.OSType, serverInfo.Architecture)
-	cmdfmt.PrintDone(streams.ErrOut, msg)
-
-	buildArgs := normalizeBuildArgsForDocker(opts.AppConfig, opts.ExtraBuildArgs)
-
 	buildkitEnabled, err := buildkitEnabled(docker)
 	terminal.Debugf("buildkitEnabled", buildkitEnabled)
 	if err != nil {
 		return nil, errors.Wrap(err, "error checking for buildkit support")
 	}
-	if buildkitEnabled {
-		imageID, err = runBuildKitBuild(ctx, streams, docker, r, opts, relativedockerfilePath, buildArgs)
-		if err != nil {
-			return nil, errors.Wrap(err, "error building")
+
+	if !buildkitEnabled && hasBuildSecrets(opts) {
+		return nil, errBuildSecretsUnsupported
+	}
+
+	buildArgs := normalizeBuildArgsForDocker(opts.AppConfig, opts.ExtraBuildArgs)
+
+	cacheFrom := importBuildCache(ctx, docker, opts.AppName, opts.CacheFrom, !buildkitEnabled)
+	if buildkitEnabled && len(opts.CacheTo) > 0 {
+		// BuildKit only writes cache metadata into the image when asked to
+		inlineCache := "1"
+		buildArgs["BUILDKIT_INLINE_CACHE"] = &inlineCache
+	}
+
+	cmdfmt.PrintBegin(streams.ErrOut, "Building image with Docker")
+	msg := fmt.Sprintf("docker host: %s %s %s", serverInfo.ServerVersion, serverInfo.OSType, serverInfo.Architecture)
+	cmdfmt.PrintDone(streams.ErrOut, msg)
+
+	// build runs the build with opts.Tag and opts.Platforms set for one platform
+	build := func(opts ImageOptions) (string, error) {
+		if buildkitEnabled && len(namedContexts) == 0 {
+			// buildkit pulls the context through the build session, sending only files the builder doesn't have yet
+			return runBuildKitBuild(ctx, streams, docker, opts, buildKitContext{dockerfile: dockerfile, excludes: excludes}, buildArgs, cacheFrom)
 		}
-	} else {
-		imageID, err = runClassicBuild(ctx, streams, docker, r, opts, relativedockerfilePath, buildArgs)
+
+		cmdfmt.PrintBegin(streams.ErrOut, "Creating build context")
+		archiveOpts := archiveOptions{
+			sourcePath:    opts.WorkingDir,
+			compressed:    dockerFactory.mode.IsRemote(),
+			exclusions:    excludes,
+			namedContexts: namedContexts,
+		}
+
+		relativedockerfilePath := "Dockerfile"
+		dockerfileInContext := isPathInRoot(dockerfile, opts.WorkingDir)
+		if dockerfileInContext {
+			// pass the relative path to Dockerfile within the context
+			p, err := filepath.Rel(opts.WorkingDir, dockerfile)
+			if err != nil {
+				return "", err
+			}
+			relativedockerfilePath = filepath.ToSlash(p)
+		}
+
+		// copy dockerfile into the archive if it's outside the context dir, or rewritten for named contexts
+		if !dockerfileInContext || len(namedContexts) > 0 {
+			dockerfileData, err := os.ReadFile(dockerfile)
+			if err != nil {
+				return "", errors.Wrap(err, "error reading Dockerfile")
+			}
+			if len(namedContexts) > 0 {
+				if dockerfileData, err = rewriteNamedContexts(dockerfileData, namedContexts); err != nil {
+					return "", errors.Wrap(err, "error resolving build contexts")
+				}
+			}
+			archiveOpts.additions = map[string][]byte{
+				relativedockerfilePath: dockerfileData,
+			}
+		}
+
+		r, err := archiveDirectory(archiveOpts)
 		if err != nil {
-			return nil, errors.Wrap(err, "error building")
+			return "", errors.Wrap(err, "error archiving build context")
+		}
+		if len(namedContexts) > 0 {
+			relativedockerfilePath = path.Join(mainContextDir, relativedockerfilePath)
 		}
+		cmdfmt.PrintDone(streams.ErrOut, "Creating build context done")
+
+		// Setup an upload progress bar
+		progressOutput := streamformatter.NewProgressOutput(streams.Out)
+		if !streams.IsStdoutTTY() {
+			progressOutput = &lastProgressOutput{output: progressOutput}
+		}
+
+		r = progress.NewProgressReader(r, progressOutput, 0, "", "Sending build context to Docker daemon")
+
+		if buildkitEnabled {
+			return runBuildKitBuild(ctx, streams, docker, opts, buildKitContext{archive: r, dockerfile: relativedockerfilePath}, buildArgs, cacheFrom)
+		}
+		return runClassicBuild(ctx, streams, docker, r, opts, relativedockerfilePath, buildArgs, cacheFrom)
+
+	}
+
+	if len(platforms) > 1 {
+		return buildPlatforms(ctx, docker, streams, opts, platforms, build)
+	}
+
+	opts.Platforms = []string{platformString(platforms[0])}
+	imageID, err := build(opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "error building")
 	}
 
 	cmdfmt.PrintDone(streams.ErrOut, "Building image done")
 
+	if len(opts.CacheTo) > 0 {
+		exportCache(ctx, docker, streams, opts, imageID)
+	}
+
 	if opts.Publish {
 		cmdfmt.PrintBegin(streams.ErrOut, "Pushing image to fly")
 
@@ -185,6 +238,78 @@ func (ds *dockerfileBuilder) Run(ctx context.Context, dockerFactory *dockerClien
 	}, nil
 }
 
+// buildPlatforms builds and pushes an image per platform, then tags a manifest list of them.
+// Docker can't keep manifest lists locally, so when they aren't published only the image for the platform of
+// Fly VMs, or the first platform, is returned.
+func buildPlatforms(ctx context.Context, docker *dockerclient.Client, streams *iostreams.IOStreams, opts ImageOptions, platforms []v1.Platform, build func(ImageOptions) (string, error)) (*DeploymentImage, error) {
+	images := []platformImage{}
+	var local *DeploymentImage
+
+	for _, p := range platforms {
+		platformOpts := opts
+		platformOpts.Tag = platformTag(opts.Tag, p)
+		platformOpts.Platforms = []string{platformString(p)}
+		if opts.Publish {
+			defer clearDeploymentTags(ctx, docker, platformOpts.Tag)
+		}
+
+		cmdfmt.PrintBegin(streams.ErrOut, "Building image for", platformString(p))
+		imageID, err := build(platformOpts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error building for %s", platformString(p))
+		}
+
+		deployable := checkDeployPlatform(opts.Tag, p) == nil
+		if deployable || local == nil {
+			if deployable && len(opts.CacheTo) > 0 {
+				exportCache(ctx, docker, streams, opts, imageID)
+			}
+
+			img, _, err := docker.ImageInspectWithRaw(ctx, imageID)
+			if err != nil {
+				return nil, errors.Wrap(err, "count not find built image")
+			}
+			local = &DeploymentImage{ID: img.ID, Tag: platformOpts.Tag, Size: img.Size}
+		}
+
+		if opts.Publish {
+			if err := pushToFly(ctx, docker, streams, platformOpts.Tag); err != nil {
+				return nil, err
+			}
+		}
+		cmdfmt.PrintDone(streams.ErrOut, "Building image for", platformString(p), "done")
+
+		images = append(images, platformImage{platform: p, tag: platformOpts.Tag})
+	}
+
+	if !opts.Publish {
+		return local, nil
+	}
+
+	cmdfmt.PrintBegin(streams.ErrOut, "Pushing manifest list to fly")
+	digest, err := pushManifestList(ctx, opts.Tag, images)
+	if err != nil {
+		return nil, errors.Wrap(err, "error pushing manifest list")
+	}
+	cmdfmt.PrintDone(streams.ErrOut, "Pushing manifest list done")
+
+	return &DeploymentImage{
+		ID:   digest.String(),
+		Tag:  opts.Tag,
+		Size: local.Size,
+	}, nil
+}
+
+// exportCache saves the build cache. A build that succeeded shouldn't fail the deployment because its cache couldn't be saved.
+func exportCache(ctx context.Context, docker *dockerclient.Client, streams *iostreams.IOStreams, opts ImageOptions, imageID string) {
+	cmdfmt.PrintBegin(streams.ErrOut, "Exporting build cache")
+	if err := exportBuildCache(ctx, docker, streams, opts.AppName, imageID, opts.CacheTo); err != nil {
+		terminal.Warn(err)
+		return
+	}
+	cmdfmt.PrintDone(streams.ErrOut, "Exporting build cache done")
+}
+
 func normalizeBuildArgsForDocker(appConfig *flyctl.AppConfig, extra map[string]string) map[string]*string {
 	var out = map[string]*string{}
 
@@ -205,15 +330,17 @@ func normalizeBuildArgsForDocker(appConfig *flyctl.AppConfig, extra map[string]s
 	return out
 }
 
-func runClassicBuild(ctx context.Context, streams *iostreams.IOStreams, docker *dockerclient.Client, r io.ReadCloser, opts ImageOptions, dockerfilePath string, buildArgs map[string]*string) (imageID string, err error) {
+func runClassicBuild(ctx context.Context, streams *iostreams.IOStreams, docker *dockerclient.Client, r io.ReadCloser, opts ImageOptions, dockerfilePath string, buildArgs map[string]*string, cacheFrom []string) (imageID string, err error) {
 	options := types.ImageBuildOptions{
 		Tags:        []string{opts.Tag},
 		BuildArgs:   buildArgs,
+		Labels:      opts.Labels,
 		AuthConfigs: authConfigs(),
-		Platform:    "linux/amd64",
+		Platform:    buildPlatform(opts),
 		Dockerfile:  dockerfilePath,
 		Target:      opts.Target,
 		NoCache:     opts.NoCache,
+		CacheFrom:   cacheFrom,
 	}
 
 	resp, err := docker.ImageBuild(ctx, r, options)
@@ -237,17 +364,41 @@ func runClassicBuild(ctx context.Context, streams *iostreams.IOStreams, docker *
 	return imageID, nil
 }
 
-const uploadRequestRemote = "upload-request"
+const (
+	// clientSessionRemote tells the daemon to read the build context and Dockerfile from the session's synced dirs
+	clientSessionRemote = "client-session"
+	// uploadRequestRemote tells the daemon to wait for the build context to be uploaded as an archive
+	uploadRequestRemote = "upload-request"
+)
+
+// buildKitContext is how a BuildKit build gets its context. Contexts are synced through the session unless
+// archive is set, which is the case when the context has to be assembled, like with named build contexts.
+type buildKitContext struct {
+	archive io.ReadCloser
+	// dockerfile is the path of the Dockerfile, relative to the archive when there is one
+	dockerfile string
+	excludes   []string
+}
 
-func runBuildKitBuild(ctx context.Context, streams *iostreams.IOStreams, docker *dockerclient.Client, r io.ReadCloser, opts ImageOptions, dockerfilePath string, buildArgs map[string]*string) (imageID string, err error) {
+func runBuildKitBuild(ctx context.Context, streams *iostreams.IOStreams, docker *dockerclient.Client, opts ImageOptions, buildContext buildKitContext, buildArgs map[string]*string, cacheFrom []string) (imageID string, err error) {
 	s, err := createBuildSession(opts.WorkingDir)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 	s.Allow(newBuildkitAuthProvider())
 
-	if s == nil {
-		panic("buildkit not supported")
+	if err := allowBuildSecrets(s, opts); err != nil {
+		s.Close()
+		return "", err
+	}
+
+	remote := clientSessionRemote
+	dockerfilePath := filepath.Base(buildContext.dockerfile)
+	if buildContext.archive != nil {
+		remote = uploadRequestRemote
+		dockerfilePath = buildContext.dockerfile
+	} else {
+		s.Allow(filesync.NewFSSyncProvider(contextSyncedDirs(opts.WorkingDir, buildContext.dockerfile, buildContext.excludes)))
 	}
 
 	eg, errCtx := errgroup.WithContext(ctx)
@@ -260,19 +411,22 @@ func runBuildKitBuild(ctx context.Context, streams *iostreams.IOStreams, docker
 	})
 
 	buildID := stringid.GenerateRandomID()
-	eg.Go(func() error {
-		buildOptions := types.ImageBuildOptions{
-			Version: types.BuilderBuildKit,
-			BuildID: uploadRequestRemote + ":" + buildID,
-		}
 
-		response, err := docker.ImageBuild(context.Background(), r, buildOptions)
-		if err != nil {
-			return err
-		}
-		defer response.Body.Close()
-		return nil
-	})
+	if buildContext.archive != nil {
+		eg.Go(func() error {
+			uploadOpts := types.ImageBuildOptions{
+				Version: types.BuilderBuildKit,
+				BuildID: uploadRequestRemote + ":" + buildID,
+			}
+
+			resp, err := docker.ImageBuild(context.Background(), buildContext.archive, uploadOpts)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			return nil
+		})
+	}
 
 	eg.Go(func() error {
 		defer s.Close()
@@ -280,15 +434,17 @@ func runBuildKitBuild(ctx context.Context, streams *iostreams.IOStreams, docker
 		buildOpts := types.ImageBuildOptions{
 			Tags:          []string{opts.Tag},
 			BuildArgs:     buildArgs,
+			Labels:        opts.Labels,
 			Version:       types.BuilderBuildKit,
 			AuthConfigs:   authConfigs(),
 			SessionID:     s.ID(),
-			RemoteContext: uploadRequestRemote,
+			RemoteContext: remote,
 			BuildID:       buildID,
-			Platform:      "linux/amd64",
+			Platform:      buildPlatform(opts),
 			Dockerfile:    dockerfilePath,
 			Target:        opts.Target,
 			NoCache:       opts.NoCache,
+			CacheFrom:     cacheFrom,
 		}
 
 		return func() error {
@@ -1,6 +1,9 @@
 package imgsrc
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type RegistryUnauthorizedError struct {
 	Tag string
@@ -9,3 +12,14 @@ type RegistryUnauthorizedError struct {
 func (err *RegistryUnauthorizedError) Error() string {
 	return fmt.Sprintf("you are not authorized to push \"%s\"", err.Tag)
 }
+
+// PlatformMismatchError is returned for images that can't run on Fly VMs, usually images built on Apple Silicon
+type PlatformMismatchError struct {
+	Ref       string
+	Platforms []string
+}
+
+func (err *PlatformMismatchError) Error() string {
+	return fmt.Sprintf("image \"%s\" is built for %s, but Fly VMs run %s. Rebuild it with docker build --platform %[3]s, or deploy from source to build for %[3]s",
+		err.Ref, strings.Join(err.Platforms, ", "), platformString(defaultPlatform))
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/docker/docker/api/types"
 	dockerclient "github.com/docker/docker/client"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	dockerparser "github.com/novln/docker-parser"
 	"github.com/pkg/errors"
 	"github.com/sammccord/flyctl/internal/cmdfmt"
@@ -67,6 +68,20 @@ func (s *localImageResolver) Run(ctx context.Context, dockerFactory *dockerClien
 
 	fmt.Fprintf(streams.ErrOut, "image found: %s\n", img.ID)
 
+	// images built on Apple Silicon default to arm64 and crash on Fly VMs, but may be what a build-only run wants
+	inspect, _, err := docker.ImageInspectWithRaw(ctx, img.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "error inspecting image")
+	}
+	platform := v1.Platform{OS: inspect.Os, Architecture: inspect.Architecture}
+	if opts.Publish || len(opts.Platforms) == 0 {
+		if err := checkDeployPlatform(ref, platform); err != nil {
+			return nil, err
+		}
+	} else if !platformRequested(platform, opts.Platforms) {
+		return nil, fmt.Errorf("image \"%s\" is built for %s, not %s", ref, platformString(platform), strings.Join(opts.Platforms, ", "))
+	}
+
 	if opts.Publish {
 		err = docker.ImageTag(ctx, img.ID, opts.Tag)
 		if err != nil {
@@ -0,0 +1,425 @@
+package imgsrc
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/pkg/fileutils"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/pkg/errors"
+	"github.com/sammccord/flyctl/flyctl"
+	"github.com/spf13/viper"
+)
+
+// defaultPlatform is the platform of Fly VMs
+var defaultPlatform = v1.Platform{OS: "linux", Architecture: "amd64"}
+
+// registryKeychain authenticates to the fly registry with the user's token and to docker hub
+// with DOCKER_HUB_USERNAME and DOCKER_HUB_PASSWORD, like the docker builders do
+type registryKeychain struct{}
+
+func (registryKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	registry := target.RegistryStr()
+
+	if registry == viper.GetString(flyctl.ConfigRegistryHost) {
+		cfg := registryAuth(flyctl.GetAPIToken())
+		return &authn.Basic{Username: cfg.Username, Password: cfg.Password}, nil
+	}
+
+	for _, cfg := range authConfigs() {
+		if cfg.ServerAddress == registry || (registry == name.DefaultRegistry && cfg.ServerAddress == "index.docker.io") {
+			return &authn.Basic{Username: cfg.Username, Password: cfg.Password}, nil
+		}
+	}
+
+	return authn.Anonymous, nil
+}
+
+func pullImage(ctx context.Context, ref string, platform v1.Platform) (v1.Image, error) {
+	r, err := name.ParseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return remote.Image(r,
+		remote.WithContext(ctx),
+		remote.WithAuthFromKeychain(registryKeychain{}),
+		remote.WithPlatform(platform),
+	)
+}
+
+// pushImage uploads the image's blobs and manifest to the registry
+func pushImage(ctx context.Context, img v1.Image, tag string) error {
+	ref, err := name.ParseReference(tag)
+	if err != nil {
+		return err
+	}
+
+	err = remote.Write(ref, img,
+		remote.WithContext(ctx),
+		remote.WithAuthFromKeychain(registryKeychain{}),
+	)
+
+	return pushError(err, tag)
+}
+
+// pushIndex uploads the images of a multi-platform build and tags their manifest list
+func pushIndex(ctx context.Context, idx v1.ImageIndex, tag string) error {
+	ref, err := name.ParseReference(tag)
+	if err != nil {
+		return err
+	}
+
+	err = remote.WriteIndex(ref, idx,
+		remote.WithContext(ctx),
+		remote.WithAuthFromKeychain(registryKeychain{}),
+	)
+
+	return pushError(err, tag)
+}
+
+func pushError(err error, tag string) error {
+	var terr *transport.Error
+	if errors.As(err, &terr) && (terr.StatusCode == 401 || terr.StatusCode == 403) {
+		return &RegistryUnauthorizedError{Tag: tag}
+	}
+	if err != nil {
+		return errors.Wrap(err, "error pushing image to registry")
+	}
+
+	return nil
+}
+
+// writeImageOutput saves the image as a tarball when path ends in .tar, otherwise to an OCI image layout directory.
+// Images are added to an existing layout.
+func writeImageOutput(img v1.Image, tag string, outputPath string) error {
+	if strings.HasSuffix(outputPath, ".tar") {
+		ref, err := name.ParseReference(tag)
+		if err != nil {
+			return err
+		}
+		return tarball.WriteToFile(outputPath, ref, img)
+	}
+
+	p, err := layout.FromPath(outputPath)
+	if err != nil {
+		if p, err = layout.Write(outputPath, empty.Index); err != nil {
+			return err
+		}
+	}
+
+	return p.AppendImage(img, layout.WithAnnotations(map[string]string{
+		"org.opencontainers.image.ref.name": tag,
+	}))
+}
+
+// writeIndexOutput adds the manifest list of a multi-platform build to an OCI image layout directory
+func writeIndexOutput(idx v1.ImageIndex, tag string, outputPath string) error {
+	if strings.HasSuffix(outputPath, ".tar") {
+		return errors.New("tarballs hold a single image, write multi-platform images to an OCI layout directory")
+	}
+
+	p, err := layout.FromPath(outputPath)
+	if err != nil {
+		if p, err = layout.Write(outputPath, empty.Index); err != nil {
+			return err
+		}
+	}
+
+	return p.AppendIndex(idx, layout.WithAnnotations(map[string]string{
+		"org.opencontainers.image.ref.name": tag,
+	}))
+}
+
+func newDeploymentImage(img v1.Image, tag string) (*DeploymentImage, error) {
+	id, err := img.ConfigName()
+	if err != nil {
+		return nil, err
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+
+	var size int64
+	for _, layer := range layers {
+		s, err := layer.Size()
+		if err != nil {
+			return nil, err
+		}
+		size += s
+	}
+
+	return &DeploymentImage{
+		ID:   id.String(),
+		Tag:  tag,
+		Size: size,
+	}, nil
+}
+
+// layerWriter writes files from the build context into an uncompressed layer tarball
+type layerWriter struct {
+	file    *os.File
+	tw      *tar.Writer
+	uid     int
+	gid     int
+	mode    os.FileMode
+	modTime time.Time
+	written map[string]bool
+}
+
+func newLayerWriter(dir string, chown string, chmod string, modTime time.Time) (*layerWriter, error) {
+	lw := &layerWriter{modTime: modTime, written: map[string]bool{}}
+
+	if chown != "" {
+		parts := strings.SplitN(chown, ":", 2)
+		uid, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, errUnsupported("--chown with user names")
+		}
+		lw.uid, lw.gid = uid, uid
+		if len(parts) == 2 {
+			if lw.gid, err = strconv.Atoi(parts[1]); err != nil {
+				return nil, errUnsupported("--chown with group names")
+			}
+		}
+	}
+
+	if chmod != "" {
+		mode, err := strconv.ParseUint(chmod, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --chmod %s", chmod)
+		}
+		lw.mode = os.FileMode(mode)
+	}
+
+	f, err := os.CreateTemp(dir, "layer-*.tar")
+	if err != nil {
+		return nil, err
+	}
+	lw.file = f
+	lw.tw = tar.NewWriter(f)
+
+	return lw, nil
+}
+
+// addFromContext copies src, a path or glob relative to the context, to dest.
+// Directories are copied by contents and files are placed inside dest when destIsDir is set.
+func (lw *layerWriter) addFromContext(contextDir string, excludes []string, src, dest string, destIsDir bool) error {
+	pm, err := fileutils.NewPatternMatcher(excludes)
+	if err != nil {
+		return err
+	}
+
+	matches, err := filepath.Glob(filepath.Join(contextDir, filepath.FromSlash(src)))
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, match := range matches {
+		rel, err := filepath.Rel(contextDir, match)
+		if err != nil || !isPathInRoot(match, contextDir) {
+			return fmt.Errorf("%s is outside of the build context", src)
+		}
+		if rel != "." {
+			if excluded, _ := pm.Matches(rel); excluded {
+				continue
+			}
+		}
+
+		info, err := os.Lstat(match)
+		if err != nil {
+			return err
+		}
+		found = true
+
+		if info.IsDir() {
+			if err := lw.addParents(dest, true); err != nil {
+				return err
+			}
+			if err := lw.addTree(contextDir, match, dest, pm); err != nil {
+				return err
+			}
+			continue
+		}
+
+		target := dest
+		if destIsDir || len(matches) > 1 {
+			target = path.Join(dest, filepath.Base(match))
+		}
+		if err := lw.addParents(target, false); err != nil {
+			return err
+		}
+		if err := lw.addFile(match, target, info); err != nil {
+			return err
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("%s: no such file or directory in the build context", src)
+	}
+
+	return nil
+}
+
+func (lw *layerWriter) addTree(contextDir, root, dest string, pm *fileutils.PatternMatcher) error {
+	return filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(contextDir, p)
+		if err != nil {
+			return err
+		}
+		excluded, err := pm.Matches(rel)
+		if err != nil {
+			return err
+		}
+		if excluded {
+			// keep walking excluded directories when a later ! pattern may include something inside them
+			if info.IsDir() && !pm.Exclusions() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		within, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+
+		return lw.addFile(p, path.Join(dest, filepath.ToSlash(within)), info)
+	})
+}
+
+// addParents writes directory entries for the parents of target, and target itself when it's a directory
+func (lw *layerWriter) addParents(target string, includeTarget bool) error {
+	dir := target
+	if !includeTarget {
+		dir = path.Dir(target)
+	}
+
+	parents := []string{}
+	for ; dir != "/" && dir != "."; dir = path.Dir(dir) {
+		parents = append([]string{dir}, parents...)
+	}
+
+	for _, p := range parents {
+		if err := lw.writeHeader(&tar.Header{Typeflag: tar.TypeDir, Name: p, Mode: 0755}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (lw *layerWriter) addFile(src, target string, info os.FileInfo) error {
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = target
+	header.ModTime = info.ModTime()
+	if lw.mode != 0 {
+		header.Mode = int64(lw.mode)
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		if header.Linkname, err = os.Readlink(src); err != nil {
+			return err
+		}
+	}
+
+	if err := lw.writeHeader(header); err != nil {
+		return err
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(lw.tw, f)
+	return err
+}
+
+func (lw *layerWriter) addContent(target string, data []byte) error {
+	if err := lw.addParents(target, false); err != nil {
+		return err
+	}
+
+	mode := int64(0644)
+	if lw.mode != 0 {
+		mode = int64(lw.mode)
+	}
+
+	if err := lw.writeHeader(&tar.Header{Typeflag: tar.TypeReg, Name: target, Mode: mode, Size: int64(len(data))}); err != nil {
+		return err
+	}
+	_, err := lw.tw.Write(data)
+	return err
+}
+
+func (lw *layerWriter) writeHeader(h *tar.Header) error {
+	// tar paths in layers are relative to the root
+	h.Name = strings.TrimPrefix(h.Name, "/")
+	if h.Typeflag == tar.TypeDir {
+		if lw.written[h.Name] {
+			return nil
+		}
+		h.Name += "/"
+	}
+	lw.written[strings.TrimSuffix(h.Name, "/")] = true
+
+	h.Uid, h.Gid = lw.uid, lw.gid
+	h.Uname, h.Gname = "", ""
+	if h.ModTime.IsZero() {
+		h.ModTime = lw.modTime
+	}
+	h.Format = tar.FormatPAX
+
+	return lw.tw.WriteHeader(h)
+}
+
+// Layer finishes the tarball and returns it as an image layer
+func (lw *layerWriter) Layer() (v1.Layer, error) {
+	name := lw.file.Name()
+	if err := lw.Close(); err != nil {
+		return nil, err
+	}
+	return tarball.LayerFromFile(name)
+}
+
+func (lw *layerWriter) Close() error {
+	if err := lw.tw.Close(); err != nil {
+		lw.file.Close()
+		return err
+	}
+	return lw.file.Close()
+}
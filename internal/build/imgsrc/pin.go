@@ -0,0 +1,41 @@
+package imgsrc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// PinImage returns tag pinned to the digest it resolves to in its registry, after checking that digest is still
+// the image with id. Images are identified by their config digest, or by their own digest for multi-platform ones.
+func PinImage(ctx context.Context, tag, id string) (string, error) {
+	ref, err := name.ParseReference(tag)
+	if err != nil {
+		return "", err
+	}
+
+	desc, err := remote.Get(ref, remote.WithContext(ctx), remote.WithAuthFromKeychain(registryKeychain{}))
+	if err != nil {
+		return "", fmt.Errorf("error resolving %s: %w", tag, err)
+	}
+
+	resolved := desc.Digest.String()
+	if resolved != id && desc.MediaType.IsImage() {
+		img, err := desc.Image()
+		if err != nil {
+			return "", err
+		}
+		config, err := img.ConfigName()
+		if err != nil {
+			return "", err
+		}
+		resolved = config.String()
+	}
+	if resolved != id {
+		return "", fmt.Errorf("%s was pushed again and is now %s, not %s", tag, resolved, id)
+	}
+
+	return ref.Context().Digest(desc.Digest.String()).String(), nil
+}
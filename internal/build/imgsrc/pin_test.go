@@ -0,0 +1,50 @@
+package imgsrc
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinImage(t *testing.T) {
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer server.Close()
+
+	repo, err := name.NewRepository(strings.TrimPrefix(server.URL, "http://") + "/my-app")
+	require.NoError(t, err)
+	tag := repo.Tag("deployment-1")
+
+	img, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(tag, img))
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	config, err := img.ConfigName()
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	pinned, err := PinImage(ctx, tag.String(), config.String())
+	require.NoError(t, err)
+	assert.Equal(t, repo.Digest(digest.String()).String(), pinned)
+
+	pinned, err = PinImage(ctx, tag.String(), digest.String())
+	require.NoError(t, err)
+	assert.Equal(t, repo.Digest(digest.String()).String(), pinned)
+
+	other, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(tag, other))
+
+	_, err = PinImage(ctx, tag.String(), config.String())
+	assert.Error(t, err)
+}
@@ -0,0 +1,231 @@
+package imgsrc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// ParsePlatforms normalizes os/arch[/variant] platforms, which may also be comma separated, and drops duplicates
+func ParsePlatforms(specs []string) ([]string, error) {
+	out := []string{}
+	seen := map[string]bool{}
+
+	for _, spec := range specs {
+		for _, s := range strings.Split(spec, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+
+			p, err := parsePlatform(s)
+			if err != nil {
+				return nil, err
+			}
+
+			if platform := platformString(p); !seen[platform] {
+				seen[platform] = true
+				out = append(out, platform)
+			}
+		}
+	}
+
+	return out, nil
+}
+
+func parsePlatform(s string) (v1.Platform, error) {
+	parts := strings.Split(strings.ToLower(s), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return v1.Platform{}, fmt.Errorf("invalid platform %q, expected os/arch[/variant] like linux/amd64", s)
+	}
+
+	p := v1.Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+
+	// like docker, aarch64 and x86_64 are aliases and arm64 defaults to the v8 variant
+	switch p.Architecture {
+	case "x86_64", "x86-64":
+		p.Architecture = "amd64"
+	case "aarch64":
+		p.Architecture = "arm64"
+	}
+	if p.Architecture == "arm64" && p.Variant == "v8" {
+		p.Variant = ""
+	}
+
+	return p, nil
+}
+
+func platformString(p v1.Platform) string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// imagePlatforms returns the platforms to build for, defaulting to the platform of Fly VMs
+func imagePlatforms(opts ImageOptions) ([]v1.Platform, error) {
+	specs := opts.Platforms
+	if len(specs) == 0 && opts.AppConfig != nil {
+		specs = opts.AppConfig.BuildPlatforms()
+	}
+	if len(specs) == 0 {
+		return []v1.Platform{defaultPlatform}, nil
+	}
+
+	platforms := []v1.Platform{}
+	for _, spec := range specs {
+		p, err := parsePlatform(spec)
+		if err != nil {
+			return nil, err
+		}
+		platforms = append(platforms, p)
+	}
+	return platforms, nil
+}
+
+// platformTag is the tag of the image for a single platform in a multi-platform build
+func platformTag(tag string, p v1.Platform) string {
+	return tag + "-" + strings.ReplaceAll(platformString(p), "/", "-")
+}
+
+// checkDeployPlatform returns a PlatformMismatchError when an image with the given platform can't run on Fly VMs
+func checkDeployPlatform(ref string, p v1.Platform) error {
+	if p.OS == defaultPlatform.OS && p.Architecture == defaultPlatform.Architecture {
+		return nil
+	}
+	return &PlatformMismatchError{Ref: ref, Platforms: []string{platformString(p)}}
+}
+
+// platformRequested reports whether p is one of platforms, ignoring variants which docker doesn't always record
+func platformRequested(p v1.Platform, platforms []string) bool {
+	for _, spec := range platforms {
+		if requested, err := parsePlatform(spec); err == nil && requested.OS == p.OS && requested.Architecture == p.Architecture {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRemotePlatform looks up ref in its registry and makes sure it's an image, or a manifest list with an image,
+// that runs on Fly VMs. Images that can't be inspected are assumed to be fine and left to the deployment.
+func checkRemotePlatform(ctx context.Context, ref string) error {
+	r, err := name.ParseReference(ref)
+	if err != nil {
+		return nil
+	}
+
+	desc, err := remote.Get(r, remote.WithContext(ctx), remote.WithAuthFromKeychain(registryKeychain{}))
+	if err != nil {
+		return nil
+	}
+
+	if desc.MediaType.IsIndex() {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return nil
+		}
+		manifest, err := idx.IndexManifest()
+		if err != nil {
+			return nil
+		}
+
+		found := []string{}
+		for _, m := range manifest.Manifests {
+			if m.Platform == nil {
+				continue
+			}
+			if checkDeployPlatform(ref, *m.Platform) == nil {
+				return nil
+			}
+			found = append(found, platformString(*m.Platform))
+		}
+		return &PlatformMismatchError{Ref: ref, Platforms: found}
+	}
+
+	img, err := desc.Image()
+	if err != nil {
+		return nil
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil || cfg.Architecture == "" {
+		return nil
+	}
+
+	return checkDeployPlatform(ref, v1.Platform{OS: cfg.OS, Architecture: cfg.Architecture})
+}
+
+// platformImage is an image pushed for one platform of a multi-platform build
+type platformImage struct {
+	platform v1.Platform
+	tag      string
+}
+
+// pushManifestList tags a manifest list pointing at images that were already pushed for each platform
+func pushManifestList(ctx context.Context, tag string, images []platformImage) (v1.Hash, error) {
+	options := []remote.Option{remote.WithContext(ctx), remote.WithAuthFromKeychain(registryKeychain{})}
+
+	var idx v1.ImageIndex = empty.Index
+	idx = mutate.IndexMediaType(idx, types.DockerManifestList)
+
+	for _, image := range images {
+		ref, err := name.ParseReference(image.tag)
+		if err != nil {
+			return v1.Hash{}, err
+		}
+
+		img, err := remote.Image(ref, options...)
+		if err != nil {
+			return v1.Hash{}, err
+		}
+
+		platform := image.platform
+		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: &platform},
+		})
+	}
+
+	ref, err := name.ParseReference(tag)
+	if err != nil {
+		return v1.Hash{}, err
+	}
+
+	if err := remote.WriteIndex(ref, idx, options...); err != nil {
+		return v1.Hash{}, err
+	}
+
+	return idx.Digest()
+}
+
+// buildPlatform is the platform passed to docker, which builds one platform at a time
+func buildPlatform(opts ImageOptions) string {
+	if len(opts.Platforms) == 0 {
+		return platformString(defaultPlatform)
+	}
+	return opts.Platforms[0]
+}
+
+// CheckDeployPlatforms returns an error when none of platforms run on Fly VMs. No platforms means the default.
+func CheckDeployPlatforms(platforms []string) error {
+	if len(platforms) == 0 {
+		return nil
+	}
+
+	for _, spec := range platforms {
+		if p, err := parsePlatform(spec); err == nil && checkDeployPlatform("", p) == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Fly VMs run %s, which isn't one of the platforms %s", platformString(defaultPlatform), strings.Join(platforms, ", "))
+}
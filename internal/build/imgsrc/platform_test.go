@@ -0,0 +1,73 @@
+package imgsrc
+
+import (
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePlatforms(t *testing.T) {
+	platforms, err := ParsePlatforms([]string{"linux/amd64,linux/arm64", "linux/aarch64", "Linux/x86_64", "linux/arm/v7"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"linux/amd64", "linux/arm64", "linux/arm/v7"}, platforms)
+
+	for _, spec := range []string{"amd64", "linux/", "linux/arm/v7/extra"} {
+		_, err := ParsePlatforms([]string{spec})
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestCheckDeployPlatforms(t *testing.T) {
+	assert.NoError(t, CheckDeployPlatforms(nil))
+	assert.NoError(t, CheckDeployPlatforms([]string{"linux/arm64", "linux/amd64"}))
+	assert.Error(t, CheckDeployPlatforms([]string{"linux/arm64"}))
+
+	err := checkDeployPlatform("my-image", v1.Platform{OS: "linux", Architecture: "arm64"})
+	assert.EqualError(t, err, `image "my-image" is built for linux/arm64, but Fly VMs run linux/amd64. Rebuild it with docker build --platform linux/amd64, or deploy from source to build for linux/amd64`)
+
+	assert.True(t, platformRequested(v1.Platform{OS: "linux", Architecture: "arm64"}, []string{"linux/amd64", "linux/aarch64"}))
+	assert.True(t, platformRequested(v1.Platform{OS: "linux", Architecture: "arm"}, []string{"linux/arm/v7"}))
+	assert.False(t, platformRequested(v1.Platform{OS: "linux", Architecture: "arm64"}, []string{"linux/amd64"}))
+
+	assert.Equal(t, "registry.fly.io/app:deployment-1-linux-arm-v7", platformTag("registry.fly.io/app:deployment-1", v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}))
+}
+
+func TestDaemonlessBuildPlatforms(t *testing.T) {
+	dir := writeContext(t, map[string]string{"index.html": "hi"})
+	arm64 := v1.Platform{OS: "linux", Architecture: "arm64"}
+
+	var idx v1.ImageIndex = empty.Index
+	for _, platform := range []v1.Platform{defaultPlatform, arm64} {
+		b := newTestBuild(t, dir)
+		b.platform = platform
+
+		img, err := b.build([]byte("FROM scratch\nCOPY index.html /\n"), "")
+		require.NoError(t, err)
+
+		cfg, err := img.ConfigFile()
+		require.NoError(t, err)
+		assert.Equal(t, platform.Architecture, cfg.Architecture)
+
+		p := platform
+		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{Add: img, Descriptor: v1.Descriptor{Platform: &p}})
+	}
+
+	out := filepath.Join(t.TempDir(), "oci")
+	require.NoError(t, writeIndexOutput(idx, "registry.fly.io/test-app:deployment-1", out))
+	assert.Error(t, writeIndexOutput(idx, "registry.fly.io/test-app:deployment-1", out+".tar"))
+
+	p, err := layout.FromPath(out)
+	require.NoError(t, err)
+	root, err := p.ImageIndex()
+	require.NoError(t, err)
+	manifest, err := root.IndexManifest()
+	require.NoError(t, err)
+	require.Len(t, manifest.Manifests, 1)
+	assert.True(t, manifest.Manifests[0].MediaType.IsIndex())
+}
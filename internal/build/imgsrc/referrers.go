@@ -0,0 +1,161 @@
+package imgsrc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pkg/errors"
+)
+
+// emptyJSONMediaType is the config of OCI artifacts, which have no config of their own
+const emptyJSONMediaType types.MediaType = "application/vnd.oci.empty.v1+json"
+
+// referrerDescriptor is an OCI 1.1 descriptor. go-containerregistry's descriptor doesn't have artifactType yet.
+type referrerDescriptor struct {
+	MediaType    types.MediaType   `json:"mediaType"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Digest       v1.Hash           `json:"digest"`
+	Size         int64             `json:"size"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+type artifactManifest struct {
+	SchemaVersion int                  `json:"schemaVersion"`
+	MediaType     types.MediaType      `json:"mediaType"`
+	ArtifactType  string               `json:"artifactType"`
+	Config        referrerDescriptor   `json:"config"`
+	Layers        []referrerDescriptor `json:"layers"`
+	Subject       *referrerDescriptor  `json:"subject,omitempty"`
+	Annotations   map[string]string    `json:"annotations,omitempty"`
+}
+
+type referrersIndex struct {
+	SchemaVersion int                  `json:"schemaVersion"`
+	MediaType     types.MediaType      `json:"mediaType"`
+	Manifests     []referrerDescriptor `json:"manifests"`
+}
+
+// rawManifest lets remote.Put upload manifests go-containerregistry doesn't model
+type rawManifest struct {
+	body      []byte
+	mediaType types.MediaType
+}
+
+func (m rawManifest) RawManifest() ([]byte, error)        { return m.body, nil }
+func (m rawManifest) MediaType() (types.MediaType, error) { return m.mediaType, nil }
+
+// attachReferrer pushes content as an OCI artifact whose subject is the image at subject in repo.
+// Registries with the referrers API index it from the subject field, for the others the
+// sha256-<digest> tag listing the image's referrers is updated too, following the OCI 1.1 fallback.
+func attachReferrer(ctx context.Context, repo name.Repository, subject v1.Descriptor, artifactType string, content []byte) error {
+	options := []remote.Option{remote.WithContext(ctx), remote.WithAuthFromKeychain(registryKeychain{})}
+
+	config := static.NewLayer([]byte("{}"), emptyJSONMediaType)
+	layer := static.NewLayer(content, types.MediaType(artifactType))
+
+	descriptors := []referrerDescriptor{}
+	for _, l := range []v1.Layer{config, layer} {
+		if err := remote.WriteLayer(repo, l, options...); err != nil {
+			return err
+		}
+		desc, err := layerDescriptor(l)
+		if err != nil {
+			return err
+		}
+		descriptors = append(descriptors, desc)
+	}
+
+	annotations := map[string]string{
+		"org.opencontainers.image.created": time.Now().UTC().Format(time.RFC3339),
+	}
+
+	manifest, err := json.Marshal(artifactManifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIManifestSchema1,
+		ArtifactType:  artifactType,
+		Config:        descriptors[0],
+		Layers:        descriptors[1:],
+		Subject: &referrerDescriptor{
+			MediaType: subject.MediaType,
+			Digest:    subject.Digest,
+			Size:      subject.Size,
+		},
+		Annotations: annotations,
+	})
+	if err != nil {
+		return err
+	}
+
+	digest, size, err := v1.SHA256(bytes.NewReader(manifest))
+	if err != nil {
+		return err
+	}
+	if err := remote.Put(repo.Digest(digest.String()), rawManifest{manifest, types.OCIManifestSchema1}, options...); err != nil {
+		return err
+	}
+
+	return addToReferrersTag(repo, subject.Digest, referrerDescriptor{
+		MediaType:    types.OCIManifestSchema1,
+		ArtifactType: artifactType,
+		Digest:       digest,
+		Size:         size,
+		Annotations:  annotations,
+	}, options)
+}
+
+func addToReferrersTag(repo name.Repository, subject v1.Hash, referrer referrerDescriptor, options []remote.Option) error {
+	tag := repo.Tag(subject.Algorithm + "-" + subject.Hex)
+
+	index := referrersIndex{SchemaVersion: 2, MediaType: types.OCIImageIndex, Manifests: []referrerDescriptor{}}
+	desc, err := remote.Get(tag, options...)
+	var terr *transport.Error
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(desc.Manifest, &index); err != nil {
+			return err
+		}
+	case errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound:
+		// the image's first referrer
+	default:
+		return err
+	}
+
+	for _, m := range index.Manifests {
+		if m.Digest == referrer.Digest {
+			return nil
+		}
+	}
+	index.Manifests = append(index.Manifests, referrer)
+
+	body, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	return remote.Put(tag, rawManifest{body, types.OCIImageIndex}, options...)
+}
+
+func layerDescriptor(l v1.Layer) (referrerDescriptor, error) {
+	digest, err := l.Digest()
+	if err != nil {
+		return referrerDescriptor{}, err
+	}
+	size, err := l.Size()
+	if err != nil {
+		return referrerDescriptor{}, err
+	}
+	mediaType, err := l.MediaType()
+	if err != nil {
+		return referrerDescriptor{}, err
+	}
+	return referrerDescriptor{MediaType: mediaType, Digest: digest, Size: size}, nil
+}
@@ -0,0 +1,66 @@
+package imgsrc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttachReferrer(t *testing.T) {
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer server.Close()
+
+	repo, err := name.NewRepository(strings.TrimPrefix(server.URL, "http://") + "/my-app")
+	require.NoError(t, err)
+
+	img, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(repo.Tag("deployment-1"), img))
+
+	desc, err := remote.Get(repo.Tag("deployment-1"))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, attachReferrer(ctx, repo, desc.Descriptor, "application/spdx+json", []byte(`{"spdxVersion":"SPDX-2.3"}`)))
+	require.NoError(t, attachReferrer(ctx, repo, desc.Descriptor, "application/vnd.in-toto+json", []byte(`{}`)))
+
+	tagged, err := remote.Get(repo.Tag("sha256-" + desc.Digest.Hex))
+	require.NoError(t, err)
+	assert.Equal(t, types.OCIImageIndex, tagged.MediaType)
+
+	var index referrersIndex
+	require.NoError(t, json.Unmarshal(tagged.Manifest, &index))
+	require.Len(t, index.Manifests, 2)
+	assert.Equal(t, "application/spdx+json", index.Manifests[0].ArtifactType)
+	assert.Equal(t, "application/vnd.in-toto+json", index.Manifests[1].ArtifactType)
+
+	artifact, err := remote.Get(repo.Digest(index.Manifests[0].Digest.String()))
+	require.NoError(t, err)
+
+	var manifest artifactManifest
+	require.NoError(t, json.Unmarshal(artifact.Manifest, &manifest))
+	assert.Equal(t, desc.Digest, manifest.Subject.Digest)
+	assert.Equal(t, emptyJSONMediaType, manifest.Config.MediaType)
+	require.Len(t, manifest.Layers, 1)
+
+	layer, err := remote.Layer(repo.Digest(manifest.Layers[0].Digest.String()))
+	require.NoError(t, err)
+	rc, err := layer.Compressed()
+	require.NoError(t, err)
+	defer rc.Close()
+	content, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, `{"spdxVersion":"SPDX-2.3"}`, string(content))
+}
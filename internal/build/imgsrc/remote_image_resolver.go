@@ -36,6 +36,10 @@ func (s *remoteImageResolver) Run(ctx context.Context, dockerFactory *dockerClie
 
 	fmt.Fprintf(streams.ErrOut, "image found: %s\n", img.ID)
 
+	if err := checkRemotePlatform(ctx, img.Ref); err != nil {
+		return nil, err
+	}
+
 	di := &DeploymentImage{
 		ID:   img.ID,
 		Tag:  img.Ref,
@@ -2,11 +2,13 @@ package imgsrc
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/sammccord/flyctl/api"
 	"github.com/sammccord/flyctl/flyctl"
+	"github.com/sammccord/flyctl/internal/build/imgsrc/builtins"
 	"github.com/sammccord/flyctl/pkg/iostreams"
 	"github.com/sammccord/flyctl/terminal"
 )
@@ -23,6 +25,37 @@ type ImageOptions struct {
 	Tag            string
 	Target         string
 	NoCache        bool
+	// Labels are added to the config of Dockerfile, builtin and daemonless builds
+	Labels map[string]string
+	// OutputPath is where daemonless builds write the image, as an OCI layout directory or a .tar file
+	OutputPath string
+	// BuildContexts are extra local directories, by name, available to COPY --from=<name>
+	BuildContexts map[string]string
+	// BuildSecrets and SSH are exposed to RUN --mount instructions in BuildKit builds
+	BuildSecrets []BuildSecret
+	SSH          []BuildSSH
+	// CacheFrom and CacheTo are where layer cache is imported from and exported to
+	CacheFrom []BuildCache
+	CacheTo   []BuildCache
+	// Platforms to build for as os/arch[/variant], building more than one pushes a manifest list
+	Platforms []string
+	// SBOM is the format of the software bill of materials generated for the image, spdx or cyclonedx
+	SBOM string
+	// Provenance generates a SLSA provenance statement for the image
+	Provenance bool
+	// SBOMOut is a directory the SBOM and provenance are saved to, besides attaching them to pushed images
+	SBOMOut string
+	// Builtins are the builtins the app's builtin is looked up in, the defaults when nil
+	Builtins *builtins.Catalog
+}
+
+// builtin returns the builtin named in the app config
+func (opts ImageOptions) builtin() (*builtins.Builtin, error) {
+	catalog := opts.Builtins
+	if catalog == nil {
+		catalog = builtins.Defaults()
+	}
+	return catalog.Get(opts.AppConfig.Build.Builtin)
 }
 
 type RefOptions struct {
@@ -33,6 +66,8 @@ type RefOptions struct {
 	ImageLabel string
 	Publish    bool
 	Tag        string
+	// Platforms the image may be built for when it isn't published, otherwise it has to run on Fly VMs
+	Platforms []string
 }
 
 type DeploymentImage struct {
@@ -69,19 +104,19 @@ func (r *Resolver) ResolveReference(ctx context.Context, streams *iostreams.IOSt
 }
 
 // BuildImage converts source code to an image using a Dockerfile, buildpacks, or builtins.
+// Without a docker daemon, images are assembled directly from simple Dockerfiles.
 func (r *Resolver) BuildImage(ctx context.Context, streams *iostreams.IOStreams, opts ImageOptions) (img *DeploymentImage, err error) {
-	if !r.dockerFactory.mode.IsAvailable() {
-		return nil, errors.New("docker is unavailable to build the deployment image")
-	}
-
 	if opts.Tag == "" {
 		opts.Tag = newDeploymentTag(opts.AppName, opts.ImageLabel)
 	}
 
+	started := time.Now()
+
 	strategies := []imageBuilder{
 		&buildpacksBuilder{},
 		&dockerfileBuilder{},
 		&builtinBuilder{},
+		&daemonlessBuilder{},
 	}
 
 	for _, s := range strategies {
@@ -92,6 +127,9 @@ func (r *Resolver) BuildImage(ctx context.Context, streams *iostreams.IOStreams,
 			return nil, err
 		}
 		if img != nil {
+			if err := r.attest(ctx, streams, s.Name(), opts, img, started); err != nil {
+				return nil, errors.Wrap(err, "error generating attestations")
+			}
 			return img, nil
 		}
 	}
@@ -0,0 +1,193 @@
+// Package vulnscan matches the packages installed in an image against an offline snapshot of
+// an OSV vulnerability database, https://ossf.github.io/osv-schema/
+package vulnscan
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Entry is a vulnerability in the OSV format
+type Entry struct {
+	ID               string                 `json:"id"`
+	Aliases          []string               `json:"aliases"`
+	Summary          string                 `json:"summary"`
+	Withdrawn        string                 `json:"withdrawn"`
+	Severity         []SeverityScore        `json:"severity"`
+	Affected         []Affected             `json:"affected"`
+	DatabaseSpecific map[string]interface{} `json:"database_specific"`
+}
+
+// SeverityScore is a CVSS vector or, for some databases, a severity name
+type SeverityScore struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+// Affected is a package with affected versions
+type Affected struct {
+	Package           AffectedPackage        `json:"package"`
+	Ranges            []Range                `json:"ranges"`
+	Versions          []string               `json:"versions"`
+	Severity          []SeverityScore        `json:"severity"`
+	EcosystemSpecific map[string]interface{} `json:"ecosystem_specific"`
+	DatabaseSpecific  map[string]interface{} `json:"database_specific"`
+}
+
+// AffectedPackage names a package in an ecosystem, like npm or Debian:11
+type AffectedPackage struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+}
+
+// Range is a sequence of events that introduce and fix a vulnerability
+type Range struct {
+	Type   string  `json:"type"`
+	Events []Event `json:"events"`
+}
+
+// Event sets exactly one of its fields
+type Event struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+	Limit        string `json:"limit,omitempty"`
+}
+
+// DB is an OSV database loaded from disk, indexed by ecosystem and package
+type DB struct {
+	// Entries is the number of vulnerabilities loaded
+	Entries int
+	index   map[string]map[string][]match
+}
+
+type match struct {
+	entry    *Entry
+	affected *Affected
+}
+
+// LoadDB reads a database snapshot: a directory of OSV JSON files, a zip of them like the per-ecosystem
+// all.zip exports of osv.dev, or a JSON file with one entry or an array of entries
+func LoadDB(path string) (*DB, error) {
+	db := &DB{index: map[string]map[string][]match{}}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case info.IsDir():
+		err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || !strings.HasSuffix(p, ".json") {
+				return err
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			return db.add(p, data)
+		})
+	case strings.HasSuffix(path, ".zip"):
+		err = db.addZip(path)
+	default:
+		var data []byte
+		if data, err = os.ReadFile(path); err == nil {
+			err = db.add(path, data)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if db.Entries == 0 {
+		return nil, fmt.Errorf("no vulnerabilities found in %s", path)
+	}
+
+	return db, nil
+}
+
+func (db *DB) addZip(path string) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		if !strings.HasSuffix(f.Name, ".json") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if err := db.add(path+":"+f.Name, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) add(name string, data []byte) error {
+	entries := []*Entry{}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &entries); err != nil {
+			return fmt.Errorf("error reading %s: %w", name, err)
+		}
+	} else {
+		entry := &Entry{}
+		if err := json.Unmarshal(trimmed, entry); err != nil {
+			return fmt.Errorf("error reading %s: %w", name, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	for _, entry := range entries {
+		if entry.ID == "" || entry.Withdrawn != "" {
+			continue
+		}
+		db.Entries++
+
+		for i := range entry.Affected {
+			affected := &entry.Affected[i]
+			ecosystem := baseEcosystem(affected.Package.Ecosystem)
+			if db.index[ecosystem] == nil {
+				db.index[ecosystem] = map[string][]match{}
+			}
+			key := packageKey(ecosystem, affected.Package.Name)
+			db.index[ecosystem][key] = append(db.index[ecosystem][key], match{entry, affected})
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) lookup(ecosystem, name string) []match {
+	return db.index[ecosystem][packageKey(ecosystem, name)]
+}
+
+// baseEcosystem drops the release from ecosystems like Debian:11
+func baseEcosystem(ecosystem string) string {
+	return strings.SplitN(ecosystem, ":", 2)[0]
+}
+
+// packageKey normalizes names the way the ecosystem compares them
+func packageKey(ecosystem, name string) string {
+	if ecosystem == "PyPI" {
+		return strings.NewReplacer("_", "-", ".", "-").Replace(strings.ToLower(name))
+	}
+	return name
+}
@@ -0,0 +1,258 @@
+package vulnscan
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/sammccord/flyctl/internal/build/attest"
+)
+
+// Policy decides which vulnerabilities block a deployment
+type Policy struct {
+	// Threshold is the lowest severity that blocks, vulnerabilities of unknown severity never do
+	Threshold Severity
+	// Allow lists vulnerability IDs, or their CVE aliases, that are accepted
+	Allow []string
+}
+
+// Finding is a vulnerability affecting an installed package
+type Finding struct {
+	ID       string         `json:"id"`
+	Aliases  []string       `json:"aliases,omitempty"`
+	Summary  string         `json:"summary,omitempty"`
+	Severity Severity       `json:"severity"`
+	Package  attest.Package `json:"package"`
+	// Fixed is the first version without the vulnerability, empty when there isn't a fix
+	Fixed   string `json:"fixed,omitempty"`
+	Allowed bool   `json:"allowed"`
+	Blocks  bool   `json:"blocks"`
+}
+
+// Report is the result of scanning an image
+type Report struct {
+	Packages  int       `json:"packages"`
+	Threshold Severity  `json:"threshold"`
+	Findings  []Finding `json:"findings"`
+}
+
+// Scan matches the inventory against db and applies policy to what it finds
+func Scan(db *DB, inv *attest.Inventory, policy Policy) *Report {
+	report := &Report{Packages: len(inv.Packages), Threshold: policy.Threshold, Findings: []Finding{}}
+
+	allowed := map[string]bool{}
+	for _, id := range policy.Allow {
+		allowed[strings.ToUpper(strings.TrimSpace(id))] = true
+	}
+
+	seen := map[string]bool{}
+	for _, pkg := range inv.Packages {
+		ecosystem, release := packageEcosystem(pkg, inv.Distro)
+		if ecosystem == "" {
+			continue
+		}
+		name := pkg.Name
+		if pkg.Source != "" {
+			name = pkg.Source
+		}
+		cmp := comparator(ecosystem)
+
+		for _, m := range db.lookup(ecosystem, name) {
+			if key := m.entry.ID + " " + pkg.PURL; seen[key] || !matchesRelease(m.affected.Package.Ecosystem, release) {
+				continue
+			}
+
+			affected, fixed := isAffected(pkg.Version, m.affected, cmp)
+			if !affected {
+				continue
+			}
+			seen[m.entry.ID+" "+pkg.PURL] = true
+
+			finding := Finding{
+				ID:       m.entry.ID,
+				Aliases:  m.entry.Aliases,
+				Summary:  m.entry.Summary,
+				Severity: severity(m.entry, m.affected),
+				Package:  pkg,
+				Fixed:    fixed,
+			}
+			for _, id := range append([]string{finding.ID}, finding.Aliases...) {
+				if allowed[strings.ToUpper(id)] {
+					finding.Allowed = true
+				}
+			}
+			finding.Blocks = !finding.Allowed && finding.Severity != SeverityUnknown && finding.Severity >= policy.Threshold
+
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if a.Severity != b.Severity {
+			return a.Severity > b.Severity
+		}
+		if a.Package.Name != b.Package.Name {
+			return a.Package.Name < b.Package.Name
+		}
+		return a.ID < b.ID
+	})
+
+	return report
+}
+
+// Blocking returns the findings at or above the threshold that weren't allowed
+func (r *Report) Blocking() []Finding {
+	blocking := []Finding{}
+	for _, f := range r.Findings {
+		if f.Blocks {
+			blocking = append(blocking, f)
+		}
+	}
+	return blocking
+}
+
+func (r *Report) Fprint(w io.Writer) {
+	counts := map[Severity]int{}
+	for _, f := range r.Findings {
+		counts[f.Severity]++
+	}
+
+	fmt.Fprintf(w, "Scanned %d packages, found %d vulnerabilities", r.Packages, len(r.Findings))
+	if len(r.Findings) > 0 {
+		summary := []string{}
+		for s := SeverityCritical; s >= SeverityUnknown; s-- {
+			if counts[s] > 0 {
+				summary = append(summary, fmt.Sprintf("%d %s", counts[s], s))
+			}
+		}
+		fmt.Fprintf(w, " (%s)", strings.Join(summary, ", "))
+	}
+	fmt.Fprintln(w)
+
+	for _, f := range r.Findings {
+		fixed := "no fix"
+		if f.Fixed != "" {
+			fixed = "fixed in " + f.Fixed
+		}
+		status := ""
+		switch {
+		case f.Blocks:
+			status = "  BLOCKING"
+		case f.Allowed:
+			status = "  allowed"
+		}
+		fmt.Fprintf(w, "  %-8s %-20s %s %s, %s%s\n", f.Severity, f.ID, f.Package.Name, f.Package.Version, fixed, status)
+	}
+}
+
+// packageEcosystem returns the OSV ecosystem of pkg, and for OS packages the distribution release
+func packageEcosystem(pkg attest.Package, distro attest.Distro) (string, string) {
+	switch pkg.Type {
+	case attest.TypeDeb:
+		switch distro.ID {
+		case "ubuntu":
+			return "Ubuntu", distro.VersionID
+		default:
+			return "Debian", distro.VersionID
+		}
+	case attest.TypeApk:
+		// Alpine releases are named after the minor version, like v3.14
+		parts := strings.SplitN(distro.VersionID, ".", 3)
+		if len(parts) < 2 {
+			return "Alpine", ""
+		}
+		return "Alpine", "v" + parts[0] + "." + parts[1]
+	case attest.TypeNpm:
+		return "npm", ""
+	case attest.TypePyPI:
+		return "PyPI", ""
+	case attest.TypeGem:
+		return "RubyGems", ""
+	}
+	return "", ""
+}
+
+// matchesRelease compares the release in ecosystems like Debian:11 or Ubuntu:22.04:LTS with the image's.
+// Either being unknown matches.
+func matchesRelease(ecosystem, release string) bool {
+	parts := strings.SplitN(ecosystem, ":", 3)
+	if len(parts) < 2 || release == "" {
+		return true
+	}
+	return parts[1] == release
+}
+
+// isAffected checks version against the affected versions and ranges, returning the version that fixed it
+func isAffected(version string, affected *Affected, cmp compareFunc) (bool, string) {
+	listed := false
+	for _, v := range affected.Versions {
+		if v == version {
+			listed = true
+		}
+	}
+
+	for _, r := range affected.Ranges {
+		if r.Type == "GIT" {
+			continue
+		}
+		if in, fixed := inRange(version, r.Events, cmp); in {
+			return true, fixed
+		}
+	}
+
+	return listed, ""
+}
+
+// inRange walks the events in version order: introduced versions start affected ranges, fixed and
+// last_affected versions end them
+func inRange(version string, events []Event, cmp compareFunc) (bool, string) {
+	sorted := append([]Event{}, events...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := eventVersion(sorted[i]), eventVersion(sorted[j])
+		if a == "0" || b == "0" {
+			return a == "0" && b != "0"
+		}
+		return cmp(a, b) < 0
+	})
+
+	affected := false
+	fixed := ""
+	for _, e := range sorted {
+		switch {
+		case e.Introduced != "":
+			if e.Introduced == "0" || cmp(version, e.Introduced) >= 0 {
+				affected = true
+			}
+		case e.Fixed != "":
+			if cmp(version, e.Fixed) >= 0 {
+				affected = false
+			} else if affected && fixed == "" {
+				fixed = e.Fixed
+			}
+		case e.LastAffected != "":
+			if cmp(version, e.LastAffected) > 0 {
+				affected = false
+			}
+		case e.Limit != "":
+			if cmp(version, e.Limit) >= 0 {
+				affected = false
+			}
+		}
+	}
+
+	if !affected {
+		return false, ""
+	}
+	return true, fixed
+}
+
+func eventVersion(e Event) string {
+	for _, v := range []string{e.Introduced, e.Fixed, e.LastAffected, e.Limit} {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,127 @@
+package vulnscan
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sammccord/flyctl/internal/build/attest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testEntries = map[string]string{
+	"DSA-5000-1.json": `{
+		"id": "DSA-5000-1",
+		"aliases": ["CVE-2021-3999"],
+		"affected": [{
+			"package": {"ecosystem": "Debian:11", "name": "glibc"},
+			"ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "2.31-13+deb11u3"}]}]
+		}],
+		"severity": [{"type": "CVSS_V3", "score": "CVSS:3.1/AV:L/AC:L/PR:L/UI:N/S:U/C:H/I:H/A:H"}]
+	}`,
+	"DSA-4000-1.json": `{
+		"id": "DSA-4000-1",
+		"affected": [{
+			"package": {"ecosystem": "Debian:10", "name": "glibc"},
+			"ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "2.28-10+deb10u1"}]}]
+		}]
+	}`,
+	"GHSA-1.json": `{
+		"id": "GHSA-1",
+		"aliases": ["CVE-2022-24999"],
+		"summary": "qs vulnerable to prototype pollution",
+		"affected": [{
+			"package": {"ecosystem": "npm", "name": "qs"},
+			"ranges": [{"type": "SEMVER", "events": [{"introduced": "6.5.0"}, {"fixed": "6.5.3"}, {"introduced": "6.7.0"}, {"fixed": "6.7.3"}]}]
+		}],
+		"database_specific": {"severity": "HIGH"}
+	}`,
+	"PYSEC-1.json": `{
+		"id": "PYSEC-1",
+		"affected": [{
+			"package": {"ecosystem": "PyPI", "name": "django"},
+			"versions": ["3.2"]
+		}]
+	}`,
+	"WITHDRAWN.json": `{
+		"id": "GHSA-2",
+		"withdrawn": "2022-01-01T00:00:00Z",
+		"affected": [{"package": {"ecosystem": "npm", "name": "qs"}, "versions": ["6.7.0"]}]
+	}`,
+}
+
+var testInventory = &attest.Inventory{
+	Distro: attest.Distro{ID: "debian", VersionID: "11"},
+	Packages: []attest.Package{
+		{Name: "libc6", Source: "glibc", Version: "2.31-13+deb11u2", Type: attest.TypeDeb, PURL: "pkg:deb/debian/libc6"},
+		{Name: "libc-bin", Source: "glibc", Version: "2.31-13+deb11u3", Type: attest.TypeDeb, PURL: "pkg:deb/debian/libc-bin"},
+		{Name: "qs", Version: "6.7.0", Type: attest.TypeNpm, PURL: "pkg:npm/qs@6.7.0"},
+		{Name: "qs", Version: "6.6.0", Type: attest.TypeNpm, PURL: "pkg:npm/qs@6.6.0"},
+		{Name: "Django", Version: "3.2", Type: attest.TypePyPI, PURL: "pkg:pypi/django@3.2"},
+	},
+}
+
+func writeTestDB(t *testing.T) string {
+	dir := t.TempDir()
+	for name, content := range testEntries {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestScan(t *testing.T) {
+	db, err := LoadDB(writeTestDB(t))
+	require.NoError(t, err)
+	assert.Equal(t, 4, db.Entries)
+
+	report := Scan(db, testInventory, Policy{Threshold: SeverityHigh})
+	require.Len(t, report.Findings, 3)
+
+	assert.Equal(t, "DSA-5000-1", report.Findings[0].ID)
+	assert.Equal(t, "libc6", report.Findings[0].Package.Name)
+	assert.Equal(t, SeverityHigh, report.Findings[0].Severity)
+	assert.Equal(t, "2.31-13+deb11u3", report.Findings[0].Fixed)
+
+	assert.Equal(t, "GHSA-1", report.Findings[1].ID)
+	assert.Equal(t, "6.7.0", report.Findings[1].Package.Version)
+	assert.Equal(t, "6.7.3", report.Findings[1].Fixed)
+	assert.True(t, report.Findings[1].Blocks)
+
+	assert.Equal(t, "PYSEC-1", report.Findings[2].ID)
+	assert.Equal(t, SeverityUnknown, report.Findings[2].Severity)
+	assert.False(t, report.Findings[2].Blocks)
+
+	assert.Len(t, report.Blocking(), 2)
+
+	report = Scan(db, testInventory, Policy{Threshold: SeverityHigh, Allow: []string{"cve-2021-3999", "GHSA-1"}})
+	assert.Empty(t, report.Blocking())
+	assert.True(t, report.Findings[0].Allowed)
+
+	report = Scan(db, testInventory, Policy{Threshold: SeverityCritical})
+	assert.Empty(t, report.Blocking())
+}
+
+func TestLoadDBZip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "all.zip")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+
+	zw := zip.NewWriter(f)
+	for name, content := range testEntries {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, f.Close())
+
+	db, err := LoadDB(path)
+	require.NoError(t, err)
+	assert.Equal(t, 4, db.Entries)
+
+	_, err = LoadDB(t.TempDir())
+	assert.Error(t, err)
+}
@@ -0,0 +1,170 @@
+package vulnscan
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Severity is how bad a vulnerability is, from the CVSS score bands
+type Severity int
+
+const (
+	SeverityUnknown Severity = iota
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+var severityNames = map[Severity]string{
+	SeverityUnknown:  "unknown",
+	SeverityLow:      "low",
+	SeverityMedium:   "medium",
+	SeverityHigh:     "high",
+	SeverityCritical: "critical",
+}
+
+func (s Severity) String() string {
+	return severityNames[s]
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ParseSeverity parses low, medium, high or critical, and the moderate and important synonyms some databases use
+func ParseSeverity(s string) (Severity, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "low", "negligible":
+		return SeverityLow, nil
+	case "medium", "moderate":
+		return SeverityMedium, nil
+	case "high", "important":
+		return SeverityHigh, nil
+	case "critical":
+		return SeverityCritical, nil
+	}
+	return SeverityUnknown, fmt.Errorf("unknown severity %q, use low, medium, high or critical", s)
+}
+
+func scoreSeverity(score float64) Severity {
+	switch {
+	case score >= 9:
+		return SeverityCritical
+	case score >= 7:
+		return SeverityHigh
+	case score >= 4:
+		return SeverityMedium
+	case score > 0:
+		return SeverityLow
+	}
+	return SeverityUnknown
+}
+
+// severity is the highest severity given for the affected package or the vulnerability: a CVSS v3 vector, or
+// a severity name in the database or ecosystem specific fields, like GitHub advisories have
+func severity(entry *Entry, affected *Affected) Severity {
+	best := SeverityUnknown
+
+	for _, score := range append(append([]SeverityScore{}, affected.Severity...), entry.Severity...) {
+		var s Severity
+		switch score.Type {
+		case "CVSS_V3":
+			if base, err := cvss3BaseScore(score.Score); err == nil {
+				s = scoreSeverity(base)
+			}
+		default:
+			s, _ = ParseSeverity(score.Score)
+		}
+		if s > best {
+			best = s
+		}
+	}
+
+	for _, fields := range []map[string]interface{}{affected.EcosystemSpecific, affected.DatabaseSpecific, entry.DatabaseSpecific} {
+		if name, ok := fields["severity"].(string); ok {
+			if s, err := ParseSeverity(name); err == nil && s > best {
+				best = s
+			}
+		}
+	}
+
+	return best
+}
+
+var cvss3Weights = map[string]map[string]float64{
+	"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC": {"L": 0.77, "H": 0.44},
+	"UI": {"N": 0.85, "R": 0.62},
+	"C":  {"H": 0.56, "L": 0.22, "N": 0},
+	"I":  {"H": 0.56, "L": 0.22, "N": 0},
+	"A":  {"H": 0.56, "L": 0.22, "N": 0},
+}
+
+// cvss3BaseScore computes the base score of a CVSS v3.0 or v3.1 vector, like CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H
+func cvss3BaseScore(vector string) (float64, error) {
+	parts := strings.Split(vector, "/")
+	if len(parts) < 9 || !strings.HasPrefix(parts[0], "CVSS:3") {
+		return 0, fmt.Errorf("invalid CVSS v3 vector %q", vector)
+	}
+
+	metrics := map[string]string{}
+	for _, part := range parts[1:] {
+		if kv := strings.SplitN(part, ":", 2); len(kv) == 2 {
+			metrics[kv[0]] = kv[1]
+		}
+	}
+
+	changed := metrics["S"] == "C"
+	values := map[string]float64{}
+	for metric, weights := range cvss3Weights {
+		w, ok := weights[metrics[metric]]
+		if !ok {
+			return 0, fmt.Errorf("invalid CVSS v3 vector %q", vector)
+		}
+		values[metric] = w
+	}
+
+	var pr float64
+	switch metrics["PR"] {
+	case "N":
+		pr = 0.85
+	case "L":
+		pr = 0.62
+		if changed {
+			pr = 0.68
+		}
+	case "H":
+		pr = 0.27
+		if changed {
+			pr = 0.5
+		}
+	default:
+		return 0, fmt.Errorf("invalid CVSS v3 vector %q", vector)
+	}
+
+	iss := 1 - (1-values["C"])*(1-values["I"])*(1-values["A"])
+	impact := 6.42 * iss
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	exploitability := 8.22 * values["AV"] * values["AC"] * pr * values["UI"]
+
+	if impact <= 0 {
+		return 0, nil
+	}
+	if changed {
+		return roundUp(math.Min(1.08*(impact+exploitability), 10)), nil
+	}
+	return roundUp(math.Min(impact+exploitability, 10)), nil
+}
+
+// roundUp rounds up to one decimal place as defined by CVSS v3.1
+func roundUp(x float64) float64 {
+	i := int(math.Round(x * 100000))
+	if i%10000 == 0 {
+		return float64(i) / 100000
+	}
+	return float64(i/10000+1) / 10
+}
@@ -0,0 +1,183 @@
+package vulnscan
+
+import (
+	"strconv"
+	"strings"
+)
+
+// compareFunc returns a negative number, zero or a positive number when a is older than, the same as or newer than b
+type compareFunc func(a, b string) int
+
+func comparator(ecosystem string) compareFunc {
+	switch ecosystem {
+	case "Debian", "Ubuntu":
+		return compareDpkg
+	default:
+		return compareGeneric
+	}
+}
+
+// compareDpkg compares versions the way dpkg does: [epoch:]upstream[-revision], with ~ sorting before anything
+func compareDpkg(a, b string) int {
+	aEpoch, aUpstream, aRevision := splitDpkg(a)
+	bEpoch, bUpstream, bRevision := splitDpkg(b)
+
+	if aEpoch != bEpoch {
+		return aEpoch - bEpoch
+	}
+	if c := verrevcmp(aUpstream, bUpstream); c != 0 {
+		return c
+	}
+	return verrevcmp(aRevision, bRevision)
+}
+
+func splitDpkg(v string) (epoch int, upstream, revision string) {
+	if i := strings.Index(v, ":"); i >= 0 {
+		epoch, _ = strconv.Atoi(v[:i])
+		v = v[i+1:]
+	}
+	if i := strings.LastIndex(v, "-"); i >= 0 {
+		return epoch, v[:i], v[i+1:]
+	}
+	return epoch, v, ""
+}
+
+func dpkgOrder(c byte) int {
+	switch {
+	case isDigit(c):
+		return 0
+	case c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+		return int(c)
+	case c == '~':
+		return -1
+	default:
+		return int(c) + 256
+	}
+}
+
+func verrevcmp(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for (i < len(a) && !isDigit(a[i])) || (j < len(b) && !isDigit(b[j])) {
+			ac, bc := 0, 0
+			if i < len(a) {
+				ac = dpkgOrder(a[i])
+			}
+			if j < len(b) {
+				bc = dpkgOrder(b[j])
+			}
+			if ac != bc {
+				return ac - bc
+			}
+			i++
+			j++
+		}
+
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+
+		firstDiff := 0
+		for i < len(a) && isDigit(a[i]) && j < len(b) && isDigit(b[j]) {
+			if firstDiff == 0 {
+				firstDiff = int(a[i]) - int(b[j])
+			}
+			i++
+			j++
+		}
+		if i < len(a) && isDigit(a[i]) {
+			return 1
+		}
+		if j < len(b) && isDigit(b[j]) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return firstDiff
+		}
+	}
+	return 0
+}
+
+// preReleases are the markers semver, PEP 440 and RubyGems use for versions older than the release
+var preReleases = []string{"alpha", "beta", "dev", "pre", "preview", "rc", "a", "b", "c"}
+
+// compareGeneric compares dotted numeric versions with pre-release suffixes, which covers semver, PEP 440,
+// RubyGems and apk versions well enough to find affected packages
+func compareGeneric(a, b string) int {
+	at, bt := tokenize(a), tokenize(b)
+
+	for i := 0; i < len(at) || i < len(bt); i++ {
+		switch {
+		case i >= len(at):
+			if isPreRelease(bt[i]) {
+				return 1
+			}
+			return -1
+		case i >= len(bt):
+			if isPreRelease(at[i]) {
+				return -1
+			}
+			return 1
+		}
+
+		x, y := at[i], bt[i]
+		if x == y {
+			continue
+		}
+
+		if isDigit(x[0]) && isDigit(y[0]) {
+			x, y = strings.TrimLeft(x, "0"), strings.TrimLeft(y, "0")
+			if len(x) != len(y) {
+				return len(x) - len(y)
+			}
+			return strings.Compare(x, y)
+		}
+
+		if xp, yp := isPreRelease(x), isPreRelease(y); xp != yp {
+			if xp {
+				return -1
+			}
+			return 1
+		}
+
+		return strings.Compare(x, y)
+	}
+
+	return 0
+}
+
+// tokenize splits a version into runs of digits and runs of anything else, lowercased
+func tokenize(v string) []string {
+	v = strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(v, "v"), "V"))
+
+	tokens := []string{}
+	for i := 0; i < len(v); {
+		j := i + 1
+		for j < len(v) && isDigit(v[j]) == isDigit(v[i]) {
+			j++
+		}
+		tokens = append(tokens, v[i:j])
+		i = j
+	}
+	return tokens
+}
+
+func isPreRelease(token string) bool {
+	token = strings.Trim(token, ".-_+")
+	if strings.HasPrefix(token, "~") {
+		return true
+	}
+	for _, marker := range preReleases {
+		if token == marker {
+			return true
+		}
+	}
+	return false
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,72 @@
+package vulnscan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+func TestCompareDpkg(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"2.31-13+deb11u2", "2.31-13+deb11u3", -1},
+		{"2.31-13", "2.31-13+deb11u1", -1},
+		{"1:1.0", "2.0", 1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0.10", "1.0.9", 1},
+		{"1.00", "1.0", 0},
+		{"2021a-1+deb11u1", "2021a-1", 1},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, sign(compareDpkg(c.a, c.b)), "%s vs %s", c.a, c.b)
+	}
+}
+
+func TestCompareGeneric(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"4.17.1", "4.17.3", -1},
+		{"1.10.0", "1.9.0", 1},
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0rc1", "1.0", -1},
+		{"1.0.0-alpha", "1.0.0-beta", -1},
+		{"1.2.2-r3", "1.2.2-r10", -1},
+		{"1.2.2", "1.2.2-r0", -1},
+		{"v2.0", "2.0", 0},
+		{"3.2", "3.2.1", -1},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, sign(compareGeneric(c.a, c.b)), "%s vs %s", c.a, c.b)
+	}
+}
+
+func TestCVSS3BaseScore(t *testing.T) {
+	score, err := cvss3BaseScore("CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H")
+	assert.NoError(t, err)
+	assert.Equal(t, 10.0, score)
+
+	score, err = cvss3BaseScore("CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:U/C:H/I:H/A:H")
+	assert.NoError(t, err)
+	assert.Equal(t, 8.8, score)
+
+	score, err = cvss3BaseScore("CVSS:3.0/AV:L/AC:H/PR:L/UI:N/S:U/C:L/I:N/A:N")
+	assert.NoError(t, err)
+	assert.Equal(t, 2.5, score)
+
+	_, err = cvss3BaseScore("CVSS:2.0/AV:N")
+	assert.Error(t, err)
+}
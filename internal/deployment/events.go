@@ -0,0 +1,201 @@
+package deployment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sammccord/flyctl/api"
+)
+
+// EventFormatNDJSON emits one JSON object per line
+const EventFormatNDJSON = "ndjson"
+
+const (
+	EventDeploymentStarted   = "deployment_started"
+	EventDeploymentUpdated   = "deployment_updated"
+	EventDeploymentFailed    = "deployment_failed"
+	EventDeploymentSucceeded = "deployment_succeeded"
+)
+
+// Event is a single machine readable deployment monitor event
+type Event struct {
+	Type        string            `json:"type"`
+	App         string            `json:"app"`
+	Timestamp   time.Time         `json:"timestamp"`
+	ElapsedMs   int64             `json:"elapsed_ms"`
+	Deployment  EventDeployment   `json:"deployment"`
+	Allocations []EventAllocation `json:"allocations"`
+}
+
+type EventDeployment struct {
+	ID             string `json:"id"`
+	Version        int    `json:"version"`
+	Status         string `json:"status"`
+	Description    string `json:"description"`
+	InProgress     bool   `json:"in_progress"`
+	Successful     bool   `json:"successful"`
+	DesiredCount   int    `json:"desired_count"`
+	PlacedCount    int    `json:"placed_count"`
+	HealthyCount   int    `json:"healthy_count"`
+	UnhealthyCount int    `json:"unhealthy_count"`
+}
+
+type EventAllocation struct {
+	ID            string       `json:"id"`
+	Region        string       `json:"region"`
+	Version       int          `json:"version"`
+	Status        string       `json:"status"`
+	DesiredStatus string       `json:"desired_status"`
+	Healthy       bool         `json:"healthy"`
+	Canary        bool         `json:"canary"`
+	Failed        bool         `json:"failed"`
+	Restarts      int          `json:"restarts"`
+	Checks        []EventCheck `json:"checks"`
+}
+
+type EventCheck struct {
+	Name        string `json:"name,omitempty"`
+	ServiceName string `json:"service_name,omitempty"`
+	Status      string `json:"status"`
+}
+
+// EventEmitter writes deployment monitor callbacks to a stream of events
+type EventEmitter struct {
+	appName string
+
+	mu      sync.Mutex
+	enc     *json.Encoder
+	started map[string]time.Time
+	now     func() time.Time
+}
+
+// NewEventEmitter returns an emitter for the given format writing to w
+func NewEventEmitter(w io.Writer, format string, appName string) (*EventEmitter, error) {
+	if format != EventFormatNDJSON {
+		return nil, fmt.Errorf("unsupported event format %q, only %s is supported", format, EventFormatNDJSON)
+	}
+
+	return &EventEmitter{
+		appName: appName,
+		enc:     json.NewEncoder(w),
+		started: map[string]time.Time{},
+		now:     time.Now,
+	}, nil
+}
+
+// Attach wraps the monitor's callbacks so each emits an event before calling through to the existing one
+func (e *EventEmitter) Attach(monitor *DeploymentMonitor) {
+	started, updated, failed, succeeded := monitor.DeploymentStarted, monitor.DeploymentUpdated, monitor.DeploymentFailed, monitor.DeploymentSucceeded
+
+	monitor.DeploymentStarted = func(idx int, d *api.DeploymentStatus) error {
+		if err := e.emit(EventDeploymentStarted, d, d.Allocations); err != nil {
+			return err
+		}
+		if started == nil {
+			return nil
+		}
+		return started(idx, d)
+	}
+	monitor.DeploymentUpdated = func(d *api.DeploymentStatus, updatedAllocs []*api.AllocationStatus) error {
+		if err := e.emit(EventDeploymentUpdated, d, updatedAllocs); err != nil {
+			return err
+		}
+		if updated == nil {
+			return nil
+		}
+		return updated(d, updatedAllocs)
+	}
+	monitor.DeploymentFailed = func(d *api.DeploymentStatus, failedAllocs []*api.AllocationStatus) error {
+		if err := e.emit(EventDeploymentFailed, d, failedAllocs); err != nil {
+			return err
+		}
+		if failed == nil {
+			return nil
+		}
+		return failed(d, failedAllocs)
+	}
+	monitor.DeploymentSucceeded = func(d *api.DeploymentStatus) error {
+		if err := e.emit(EventDeploymentSucceeded, d, d.Allocations); err != nil {
+			return err
+		}
+		if succeeded == nil {
+			return nil
+		}
+		return succeeded(d)
+	}
+}
+
+func (e *EventEmitter) emit(eventType string, d *api.DeploymentStatus, allocs []*api.AllocationStatus) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	started, ok := e.started[d.ID]
+	if !ok {
+		started = now
+		e.started[d.ID] = now
+	}
+
+	event := Event{
+		Type:      eventType,
+		App:       e.appName,
+		Timestamp: now.UTC(),
+		ElapsedMs: now.Sub(started).Milliseconds(),
+		Deployment: EventDeployment{
+			ID:             d.ID,
+			Version:        d.Version,
+			Status:         d.Status,
+			Description:    d.Description,
+			InProgress:     d.InProgress,
+			Successful:     d.Successful,
+			DesiredCount:   d.DesiredCount,
+			PlacedCount:    d.PlacedCount,
+			HealthyCount:   d.HealthyCount,
+			UnhealthyCount: d.UnhealthyCount,
+		},
+		Allocations: make([]EventAllocation, 0, len(allocs)),
+	}
+
+	for _, alloc := range allocs {
+		a := EventAllocation{
+			ID:            alloc.ID,
+			Region:        alloc.Region,
+			Version:       alloc.Version,
+			Status:        alloc.Status,
+			DesiredStatus: alloc.DesiredStatus,
+			Healthy:       alloc.Healthy,
+			Canary:        alloc.Canary,
+			Failed:        alloc.Failed,
+			Restarts:      alloc.Restarts,
+			Checks:        make([]EventCheck, 0, len(alloc.Checks)),
+		}
+		for _, check := range alloc.Checks {
+			a.Checks = append(a.Checks, EventCheck{
+				Name:        check.Name,
+				ServiceName: check.ServiceName,
+				Status:      check.Status,
+			})
+		}
+		event.Allocations = append(event.Allocations, a)
+	}
+
+	return e.enc.Encode(event)
+}
+
+type contextKey struct{}
+
+// NewContext derives a context that carries emitter from ctx.
+func NewContext(ctx context.Context, emitter *EventEmitter) context.Context {
+	return context.WithValue(ctx, contextKey{}, emitter)
+}
+
+// EmitterFromContext returns the EventEmitter ctx carries, or nil when
+// events weren't requested.
+func EmitterFromContext(ctx context.Context) *EventEmitter {
+	emitter, _ := ctx.Value(contextKey{}).(*EventEmitter)
+	return emitter
+}
@@ -0,0 +1,68 @@
+package deployment
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/sammccord/flyctl/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventEmitter(t *testing.T) {
+	var buf bytes.Buffer
+	emitter, err := NewEventEmitter(&buf, EventFormatNDJSON, "test-app")
+	assert.NoError(t, err)
+
+	start := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	emitter.now = func() time.Time { return now }
+
+	monitor := NewDeploymentMonitor(nil, "test-app")
+	var succeeded []int
+	monitor.DeploymentSucceeded = func(d *api.DeploymentStatus) error {
+		succeeded = append(succeeded, d.Version)
+		return nil
+	}
+	emitter.Attach(monitor)
+
+	d := &api.DeploymentStatus{ID: "d1", Version: 7, Status: "running", InProgress: true}
+	alloc := &api.AllocationStatus{
+		ID:     "a1",
+		Region: "ord",
+		Status: "running",
+		Checks: []api.CheckState{{Name: "http", ServiceName: "app", Status: "passing"}},
+	}
+
+	assert.NoError(t, monitor.DeploymentStarted(0, d))
+	now = start.Add(1500 * time.Millisecond)
+	assert.NoError(t, monitor.DeploymentUpdated(d, []*api.AllocationStatus{alloc}))
+	now = start.Add(3 * time.Second)
+	assert.NoError(t, monitor.DeploymentSucceeded(d))
+
+	events := []Event{}
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var e Event
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		events = append(events, e)
+	}
+
+	assert.Len(t, events, 3)
+	assert.Equal(t, EventDeploymentStarted, events[0].Type)
+	assert.Equal(t, EventDeploymentUpdated, events[1].Type)
+	assert.Equal(t, int64(1500), events[1].ElapsedMs)
+	assert.Equal(t, "ord", events[1].Allocations[0].Region)
+	assert.Equal(t, "passing", events[1].Allocations[0].Checks[0].Status)
+	assert.Equal(t, EventDeploymentSucceeded, events[2].Type)
+	assert.Equal(t, int64(3000), events[2].ElapsedMs)
+	assert.Equal(t, 7, events[2].Deployment.Version)
+	assert.Equal(t, []int{7}, succeeded, "existing callbacks still run")
+}
+
+func TestEventEmitterFormat(t *testing.T) {
+	_, err := NewEventEmitter(&bytes.Buffer{}, "xml", "test-app")
+	assert.Error(t, err)
+}
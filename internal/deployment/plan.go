@@ -0,0 +1,362 @@
+package deployment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/sammccord/flyctl/api"
+)
+
+// Plan describes what a deployment will change relative to the app's current release.
+// Plans can be written to disk and applied later so reviewers approve exactly what goes out.
+type Plan struct {
+	AppName        string          `json:"app_name"`
+	CreatedAt      time.Time       `json:"created_at"`
+	BaseVersion    int             `json:"base_version"`
+	Image          PlanImage       `json:"image"`
+	Definition     api.Definition  `json:"definition"`
+	ConfigChanges  []ConfigChange  `json:"config_changes"`
+	ChangedSecrets []string        `json:"changed_secrets"`
+	StagedSecrets  []string        `json:"staged_secrets,omitempty"`
+	Strategy       string          `json:"strategy"`
+	Regions        []RegionVMCount `json:"regions"`
+	ReleaseCommand string          `json:"release_command,omitempty"`
+}
+
+// PlanImage is the image currently running and the image that will be deployed
+type PlanImage struct {
+	Tag           string `json:"tag"`
+	ID            string `json:"id"`
+	CurrentRef    string `json:"current_ref,omitempty"`
+	CurrentDigest string `json:"current_digest,omitempty"`
+}
+
+// ConfigChange is a single difference between the current and the new app configuration
+type ConfigChange struct {
+	Path   string      `json:"path"`
+	Action string      `json:"action"`
+	Old    interface{} `json:"old,omitempty"`
+	New    interface{} `json:"new,omitempty"`
+}
+
+const (
+	ConfigAdded   = "added"
+	ConfigRemoved = "removed"
+	ConfigChanged = "changed"
+)
+
+// RegionVMCount is the number of running VMs in a region that the deployment will replace
+type RegionVMCount struct {
+	Region string `json:"region"`
+	Count  int    `json:"count"`
+}
+
+// PlanInput is what a deployment is about to send to DeployImage
+type PlanInput struct {
+	AppName       string
+	Image         string
+	ImageID       string
+	Definition    api.Definition
+	Strategy      string
+	StagedSecrets []string
+}
+
+// DefaultStrategy is reported when neither the command line nor fly.toml pick a strategy
+const DefaultStrategy = "canary (rolling when max-per-region is set)"
+
+// NewPlan builds a deployment plan by comparing the input against the app's current state
+func NewPlan(ctx context.Context, client *api.Client, input PlanInput) (*Plan, error) {
+	plan := &Plan{
+		AppName:        input.AppName,
+		CreatedAt:      time.Now().UTC(),
+		Definition:     input.Definition,
+		Strategy:       input.Strategy,
+		StagedSecrets:  input.StagedSecrets,
+		ReleaseCommand: ReleaseCommand(input.Definition),
+		Image: PlanImage{
+			Tag: input.Image,
+			ID:  input.ImageID,
+		},
+	}
+
+	if plan.Strategy == "" {
+		plan.Strategy = configuredStrategy(input.Definition)
+	}
+
+	imageInfo, err := client.GetImageInfo(ctx, input.AppName)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching current image: %w", err)
+	}
+	if d := imageInfo.ImageDetails; d.Repository != "" {
+		plan.Image.CurrentRef = fmt.Sprintf("%s/%s:%s", d.Registry, d.Repository, d.Tag)
+		plan.Image.CurrentDigest = d.Digest
+	}
+
+	current, err := client.GetConfig(ctx, input.AppName)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching current configuration: %w", err)
+	}
+	plan.ConfigChanges = DiffDefinitions(current.Definition, input.Definition)
+
+	releases, err := client.GetAppReleases(ctx, input.AppName, 25)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching releases: %w", err)
+	}
+	if len(releases) > 0 {
+		plan.BaseVersion = releases[0].Version
+	}
+
+	secrets, err := client.GetAppSecrets(ctx, input.AppName)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching secrets: %w", err)
+	}
+	plan.ChangedSecrets = SecretsChangedSince(secrets, LastImageRelease(releases))
+
+	status, err := client.GetAppStatus(ctx, input.AppName, false)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching app status: %w", err)
+	}
+	plan.Regions = CountVMsByRegion(status.Allocations)
+
+	return plan, nil
+}
+
+// LastImageRelease returns the most recent release that deployed an image, or nil when there is none
+func LastImageRelease(releases []api.Release) *api.Release {
+	for i := range releases {
+		if releases[i].Reason == "change_image" {
+			return &releases[i]
+		}
+	}
+	return nil
+}
+
+// SecretsChangedSince lists the secrets set after the given release. All secrets are returned when release is nil.
+func SecretsChangedSince(secrets []api.Secret, release *api.Release) []string {
+	out := []string{}
+	for _, secret := range secrets {
+		if release == nil || secret.CreatedAt.After(release.CreatedAt) {
+			out = append(out, secret.Name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// CountVMsByRegion tallies allocations per region, sorted by region name
+func CountVMsByRegion(allocs []*api.AllocationStatus) []RegionVMCount {
+	counts := map[string]int{}
+	for _, alloc := range allocs {
+		counts[alloc.Region]++
+	}
+
+	out := make([]RegionVMCount, 0, len(counts))
+	for region, count := range counts {
+		out = append(out, RegionVMCount{Region: region, Count: count})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Region < out[j].Region })
+
+	return out
+}
+
+// ReleaseCommand returns the release command configured in the [deploy] section, if any
+func ReleaseCommand(definition api.Definition) string {
+	return deploySetting(definition, "release_command")
+}
+
+func configuredStrategy(definition api.Definition) string {
+	if strategy := deploySetting(definition, "strategy"); strategy != "" {
+		return strategy
+	}
+	return DefaultStrategy
+}
+
+func deploySetting(definition api.Definition, key string) string {
+	switch deploy := definition["deploy"].(type) {
+	case map[string]interface{}:
+		if v, ok := deploy[key]; ok {
+			return fmt.Sprint(v)
+		}
+	case map[string]string:
+		return deploy[key]
+	}
+	return ""
+}
+
+// DiffDefinitions compares two app definitions and returns the changed keys as dotted paths.
+// Values are compared after a JSON roundtrip so numbers decoded from TOML and JSON compare equal.
+func DiffDefinitions(current, next api.Definition) []ConfigChange {
+	changes := []ConfigChange{}
+	diffMaps("", normalizeDefinition(current), normalizeDefinition(next), &changes)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+func normalizeDefinition(definition api.Definition) map[string]interface{} {
+	out := map[string]interface{}{}
+	if len(definition) == 0 {
+		return out
+	}
+	data, err := json.Marshal(definition)
+	if err != nil {
+		return out
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return map[string]interface{}{}
+	}
+	return out
+}
+
+func diffMaps(prefix string, current, next map[string]interface{}, changes *[]ConfigChange) {
+	for key, oldValue := range current {
+		path := joinPath(prefix, key)
+		newValue, ok := next[key]
+		if !ok {
+			*changes = append(*changes, ConfigChange{Path: path, Action: ConfigRemoved, Old: oldValue})
+			continue
+		}
+
+		oldMap, oldIsMap := oldValue.(map[string]interface{})
+		newMap, newIsMap := newValue.(map[string]interface{})
+		if oldIsMap && newIsMap {
+			diffMaps(path, oldMap, newMap, changes)
+			continue
+		}
+
+		if !reflect.DeepEqual(oldValue, newValue) {
+			*changes = append(*changes, ConfigChange{Path: path, Action: ConfigChanged, Old: oldValue, New: newValue})
+		}
+	}
+
+	for key, newValue := range next {
+		if _, ok := current[key]; !ok {
+			*changes = append(*changes, ConfigChange{Path: joinPath(prefix, key), Action: ConfigAdded, New: newValue})
+		}
+	}
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// Verify checks that the app hasn't been released since the plan was made
+func (p *Plan) Verify(ctx context.Context, client *api.Client) error {
+	releases, err := client.GetAppReleases(ctx, p.AppName, 1)
+	if err != nil {
+		return fmt.Errorf("error fetching releases: %w", err)
+	}
+
+	version := 0
+	if len(releases) > 0 {
+		version = releases[0].Version
+	}
+
+	if version != p.BaseVersion {
+		return fmt.Errorf("plan is stale: it was made against v%d but %s is now at v%d", p.BaseVersion, p.AppName, version)
+	}
+
+	return nil
+}
+
+// Fprint renders the plan as human readable text
+func (p *Plan) Fprint(w io.Writer) {
+	fmt.Fprintf(w, "Deployment plan for %s (current release v%d)\n\n", p.AppName, p.BaseVersion)
+
+	fmt.Fprintln(w, "Image:")
+	if p.Image.CurrentRef != "" {
+		fmt.Fprintf(w, "  current: %s (%s)\n", p.Image.CurrentRef, p.Image.CurrentDigest)
+	} else {
+		fmt.Fprintln(w, "  current: none")
+	}
+	fmt.Fprintf(w, "  new:     %s (%s)\n", p.Image.Tag, p.Image.ID)
+
+	fmt.Fprintln(w, "\nConfiguration changes:")
+	if len(p.ConfigChanges) == 0 {
+		fmt.Fprintln(w, "  none")
+	}
+	for _, c := range p.ConfigChanges {
+		switch c.Action {
+		case ConfigAdded:
+			fmt.Fprintf(w, "  + %s = %s\n", c.Path, formatValue(c.New))
+		case ConfigRemoved:
+			fmt.Fprintf(w, "  - %s = %s\n", c.Path, formatValue(c.Old))
+		default:
+			fmt.Fprintf(w, "  ~ %s: %s -> %s\n", c.Path, formatValue(c.Old), formatValue(c.New))
+		}
+	}
+
+	fmt.Fprintln(w, "\nSecrets changed since the last deployment:")
+	if len(p.ChangedSecrets) == 0 {
+		fmt.Fprintln(w, "  none")
+	}
+	for _, name := range p.ChangedSecrets {
+		fmt.Fprintf(w, "  %s\n", name)
+	}
+
+	if len(p.StagedSecrets) > 0 {
+		fmt.Fprintln(w, "\nStaged secret changes, release them with `flyctl secrets apply` before deploying:")
+		for _, change := range p.StagedSecrets {
+			fmt.Fprintf(w, "  %s\n", change)
+		}
+	}
+
+	fmt.Fprintf(w, "\nStrategy: %s\n", p.Strategy)
+
+	fmt.Fprintln(w, "\nVMs per region:")
+	if len(p.Regions) == 0 {
+		fmt.Fprintln(w, "  none running")
+	}
+	for _, r := range p.Regions {
+		fmt.Fprintf(w, "  %s: %d\n", r.Region, r.Count)
+	}
+
+	if p.ReleaseCommand != "" {
+		fmt.Fprintf(w, "\nRelease command will run: %s\n", p.ReleaseCommand)
+	} else {
+		fmt.Fprintln(w, "\nNo release command will run")
+	}
+}
+
+func formatValue(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// WritePlanFile saves the plan as JSON
+func WritePlanFile(path string, plan *Plan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// ReadPlanFile loads a plan saved by WritePlanFile
+func ReadPlanFile(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("invalid plan file %s: %w", path, err)
+	}
+	if plan.AppName == "" || plan.Image.Tag == "" {
+		return nil, fmt.Errorf("invalid plan file %s: missing app name or image", path)
+	}
+
+	return &plan, nil
+}
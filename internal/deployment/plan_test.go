@@ -0,0 +1,95 @@
+package deployment
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sammccord/flyctl/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffDefinitions(t *testing.T) {
+	current := api.Definition{
+		"kill_signal": "SIGINT",
+		"env":         map[string]interface{}{"LOG_LEVEL": "info", "PORT": int64(8080)},
+		"deploy":      map[string]interface{}{"strategy": "rolling"},
+	}
+	next := api.Definition{
+		"kill_signal": "SIGTERM",
+		"env":         map[string]string{"LOG_LEVEL": "info", "PORT": "8080"},
+		"statics":     []interface{}{map[string]interface{}{"url_prefix": "/static"}},
+	}
+
+	changes := DiffDefinitions(current, next)
+
+	assert.Equal(t, []ConfigChange{
+		{Path: "deploy", Action: ConfigRemoved, Old: map[string]interface{}{"strategy": "rolling"}},
+		{Path: "env.PORT", Action: ConfigChanged, Old: float64(8080), New: "8080"},
+		{Path: "kill_signal", Action: ConfigChanged, Old: "SIGINT", New: "SIGTERM"},
+		{Path: "statics", Action: ConfigAdded, New: []interface{}{map[string]interface{}{"url_prefix": "/static"}}},
+	}, changes)
+}
+
+func TestDiffDefinitionsUnchanged(t *testing.T) {
+	def := api.Definition{"env": map[string]interface{}{"PORT": int64(8080)}}
+	assert.Empty(t, DiffDefinitions(def, api.Definition{"env": map[string]interface{}{"PORT": float64(8080)}}))
+}
+
+func TestSecretsChangedSince(t *testing.T) {
+	now := time.Now()
+	releases := []api.Release{
+		{Version: 3, Reason: "change_secrets", CreatedAt: now.Add(-1 * time.Minute)},
+		{Version: 2, Reason: "change_image", CreatedAt: now.Add(-1 * time.Hour)},
+	}
+	secrets := []api.Secret{
+		{Name: "OLD", CreatedAt: now.Add(-2 * time.Hour)},
+		{Name: "NEW", CreatedAt: now.Add(-1 * time.Minute)},
+	}
+
+	release := LastImageRelease(releases)
+	assert.Equal(t, 2, release.Version)
+	assert.Equal(t, []string{"NEW"}, SecretsChangedSince(secrets, release))
+	assert.Equal(t, []string{"NEW", "OLD"}, SecretsChangedSince(secrets, nil))
+}
+
+func TestCountVMsByRegion(t *testing.T) {
+	counts := CountVMsByRegion([]*api.AllocationStatus{
+		{Region: "ord"}, {Region: "ams"}, {Region: "ord"},
+	})
+	assert.Equal(t, []RegionVMCount{{Region: "ams", Count: 1}, {Region: "ord", Count: 2}}, counts)
+}
+
+func TestPlanFileRoundtrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.json")
+	plan := &Plan{
+		AppName:        "test-app",
+		BaseVersion:    4,
+		Image:          PlanImage{Tag: "registry.fly.io/test-app:deployment-1", ID: "sha256:abc"},
+		Definition:     api.Definition{"deploy": map[string]interface{}{"release_command": "migrate"}},
+		Strategy:       DefaultStrategy,
+		ReleaseCommand: "migrate",
+	}
+
+	assert.NoError(t, WritePlanFile(path, plan))
+
+	loaded, err := ReadPlanFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, plan.Image, loaded.Image)
+	assert.Equal(t, plan.BaseVersion, loaded.BaseVersion)
+	assert.Equal(t, "migrate", ReleaseCommand(loaded.Definition))
+}
+
+func TestPlanFprintStagedSecrets(t *testing.T) {
+	plan := &Plan{AppName: "test-app", Strategy: DefaultStrategy}
+
+	var out strings.Builder
+	plan.Fprint(&out)
+	assert.NotContains(t, out.String(), "Staged secret changes")
+
+	plan.StagedSecrets = []string{"set API_KEY", "unset OLD_TOKEN"}
+	out.Reset()
+	plan.Fprint(&out)
+	assert.Contains(t, out.String(), "Staged secret changes, release them with `flyctl secrets apply` before deploying:\n  set API_KEY\n  unset OLD_TOKEN\n")
+}
@@ -0,0 +1,192 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sammccord/flyctl/flyctl"
+)
+
+// DialFunc opens connections for smoke checks run against instances, usually over WireGuard
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// SmokeTarget is where a smoke check is sent: either the app's public hostname or a single instance. Checks
+// against the hostname go through the Fly proxy, which picks an instance without saying which one answered.
+type SmokeTarget struct {
+	Name     string
+	Region   string
+	Address  string
+	Port     int
+	Hostname string
+	Instance bool
+}
+
+// SmokeResult is the outcome of running one check against one target. Allocation is the instance that served the
+// check, which is only known for instance targets.
+type SmokeResult struct {
+	Check      flyctl.SmokeCheck
+	Target     SmokeTarget
+	Allocation string
+	URL        string
+	Status     int
+	Latency    time.Duration
+	Err        error
+}
+
+func (r SmokeResult) Passed() bool {
+	return r.Err == nil
+}
+
+const defaultSmokeTimeout = 30 * time.Second
+
+// ValidateSmokeTests checks the body expressions up front so a typo doesn't fail a deployment that is already live
+func ValidateSmokeTests(tests *flyctl.SmokeTests) error {
+	for _, check := range tests.Checks {
+		if check.Body == "" {
+			continue
+		}
+		if _, err := regexp.Compile(check.Body); err != nil {
+			return fmt.Errorf("invalid body pattern for smoke check %s: %w", check.Path, err)
+		}
+	}
+	return nil
+}
+
+// RunSmokeTests runs every check against every target. dial is required for instance targets.
+func RunSmokeTests(ctx context.Context, tests *flyctl.SmokeTests, targets []SmokeTarget, dial DialFunc) []SmokeResult {
+	results := []SmokeResult{}
+	for _, target := range targets {
+		client := &http.Client{Timeout: defaultSmokeTimeout}
+		if target.Instance {
+			client.Transport = &http.Transport{DialContext: dial}
+		}
+
+		for _, check := range tests.Checks {
+			results = append(results, runSmokeCheck(ctx, client, check, target))
+		}
+	}
+	return results
+}
+
+func runSmokeCheck(ctx context.Context, client *http.Client, check flyctl.SmokeCheck, target SmokeTarget) SmokeResult {
+	result := SmokeResult{
+		Check:  check,
+		Target: target,
+		URL:    smokeURL(check, target),
+	}
+
+	method := check.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, result.URL, nil)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if target.Hostname != "" {
+		req.Host = target.Hostname
+	}
+	if target.Instance {
+		result.Allocation = target.Name
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	result.Latency = time.Since(start)
+	result.Status = resp.StatusCode
+	if err != nil {
+		result.Err = fmt.Errorf("error reading response: %w", err)
+		return result
+	}
+
+	result.Err = checkSmokeResponse(check, resp.StatusCode, body, result.Latency)
+
+	return result
+}
+
+func checkSmokeResponse(check flyctl.SmokeCheck, status int, body []byte, latency time.Duration) error {
+	expected := check.Status
+	if expected == 0 {
+		expected = http.StatusOK
+	}
+	if status != expected {
+		return fmt.Errorf("expected status %d, got %d", expected, status)
+	}
+
+	if check.Body != "" {
+		matched, err := regexp.Match(check.Body, body)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			return fmt.Errorf("body does not match %q", check.Body)
+		}
+	}
+
+	if check.MaxLatency > 0 && latency > check.MaxLatency {
+		return fmt.Errorf("took %s, over the %s budget", latency.Round(time.Millisecond), check.MaxLatency)
+	}
+
+	return nil
+}
+
+func smokeURL(check flyctl.SmokeCheck, target SmokeTarget) string {
+	path := check.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	if !target.Instance {
+		return "https://" + target.Address + path
+	}
+
+	port := check.Port
+	if port == 0 {
+		port = target.Port
+	}
+
+	return "http://" + net.JoinHostPort(target.Address, strconv.Itoa(port)) + path
+}
+
+// InternalPort returns the internal port of the app's first service, falling back to 8080
+func InternalPort(definition map[string]interface{}) int {
+	var service map[string]interface{}
+
+	switch services := definition["services"].(type) {
+	case []interface{}:
+		if len(services) > 0 {
+			service, _ = services[0].(map[string]interface{})
+		}
+	case []map[string]interface{}:
+		if len(services) > 0 {
+			service = services[0]
+		}
+	}
+
+	switch port := service["internal_port"].(type) {
+	case int:
+		return port
+	case int64:
+		return int(port)
+	case float64:
+		return int(port)
+	}
+
+	return 8080
+}
@@ -0,0 +1,71 @@
+package deployment
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sammccord/flyctl/flyctl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunSmokeTestsAgainstInstances(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "test-app.fly.dev", r.Host)
+		switch r.URL.Path {
+		case "/healthz":
+			w.Write([]byte("ok"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	assert.NoError(t, err)
+	portNum, _ := strconv.Atoi(port)
+
+	tests := &flyctl.SmokeTests{
+		Instances: true,
+		Checks: []flyctl.SmokeCheck{
+			{Path: "/healthz", Body: "^ok$"},
+			{Path: "/missing"},
+			{Path: "healthz", Body: "^nope$"},
+		},
+	}
+	targets := []SmokeTarget{
+		{Name: "abcd1234", Region: "ord", Address: host, Port: portNum, Hostname: "test-app.fly.dev", Instance: true},
+	}
+
+	results := RunSmokeTests(context.Background(), tests, targets, (&net.Dialer{}).DialContext)
+
+	assert.Len(t, results, 3)
+	assert.True(t, results[0].Passed())
+	assert.Equal(t, "abcd1234", results[0].Allocation)
+	assert.Equal(t, "abcd1234", results[2].Allocation)
+	assert.EqualError(t, results[1].Err, "expected status 200, got 404")
+	assert.EqualError(t, results[2].Err, `body does not match "^nope$"`)
+}
+
+func TestCheckSmokeResponseLatency(t *testing.T) {
+	check := flyctl.SmokeCheck{Path: "/", Status: 204, MaxLatency: 100 * time.Millisecond}
+
+	assert.NoError(t, checkSmokeResponse(check, 204, nil, 50*time.Millisecond))
+	assert.Error(t, checkSmokeResponse(check, 204, nil, 150*time.Millisecond))
+}
+
+func TestValidateSmokeTests(t *testing.T) {
+	err := ValidateSmokeTests(&flyctl.SmokeTests{Checks: []flyctl.SmokeCheck{{Path: "/", Body: "("}}})
+	assert.Error(t, err)
+}
+
+func TestInternalPort(t *testing.T) {
+	assert.Equal(t, 3000, InternalPort(map[string]interface{}{
+		"services": []interface{}{map[string]interface{}{"internal_port": int64(3000)}},
+	}))
+	assert.Equal(t, 8080, InternalPort(map[string]interface{}{}))
+}
@@ -0,0 +1,71 @@
+// Package launchanswers holds the decisions fly launch otherwise prompts for, read from --from or
+// --json-answers.
+package launchanswers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Answers are the decisions fly launch otherwise prompts for
+type Answers struct {
+	Name         string            `json:"name" yaml:"name"`
+	GenerateName bool              `json:"generate_name" yaml:"generate_name"`
+	Org          string            `json:"org" yaml:"org"`
+	Region       string            `json:"region" yaml:"region"`
+	Image        string            `json:"image" yaml:"image"`
+	Dockerfile   string            `json:"dockerfile" yaml:"dockerfile"`
+	CopyConfig   bool              `json:"copy_config" yaml:"copy_config"`
+	Overwrite    bool              `json:"overwrite" yaml:"overwrite"`
+	Secrets      map[string]string `json:"secrets" yaml:"secrets"`
+	Postgres     bool              `json:"postgres" yaml:"postgres"`
+	Deploy       bool              `json:"deploy" yaml:"deploy"`
+	Apps         []string          `json:"apps" yaml:"apps"`
+}
+
+var validAppName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Validate checks the answers before anything is created, so a bad file doesn't leave a half launched app.
+// All problems are reported at once.
+func (a *Answers) Validate() error {
+	problems := []string{}
+
+	if a.Org == "" {
+		problems = append(problems, "org is required")
+	}
+	if a.Region == "" {
+		problems = append(problems, "region is required")
+	}
+
+	switch {
+	case len(a.Apps) > 0:
+		if a.Name != "" || a.GenerateName {
+			problems = append(problems, "name and generate_name can't be used with apps, each app gets its detected name")
+		}
+		if a.Image != "" || a.Dockerfile != "" {
+			problems = append(problems, "image and dockerfile can't be used with apps")
+		}
+	case a.Name != "" && a.GenerateName:
+		problems = append(problems, "name and generate_name are mutually exclusive")
+	case a.Name == "" && !a.GenerateName:
+		problems = append(problems, "name is required unless generate_name is set")
+	case a.Name != "" && !validAppName.MatchString(a.Name):
+		problems = append(problems, fmt.Sprintf("name %q may only contain lowercase letters, digits and dashes", a.Name))
+	}
+
+	if a.Image != "" && a.Dockerfile != "" {
+		problems = append(problems, "image and dockerfile are mutually exclusive")
+	}
+
+	for key := range a.Secrets {
+		if key == "" {
+			problems = append(problems, "secrets can't have an empty name")
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid launch answers:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
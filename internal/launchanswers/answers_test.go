@@ -0,0 +1,76 @@
+package launchanswers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name     string
+		answers  Answers
+		problems []string
+	}{
+		{
+			name:    "named app",
+			answers: Answers{Name: "acme-web", Org: "acme", Region: "ams"},
+		},
+		{
+			name:    "generated name",
+			answers: Answers{GenerateName: true, Org: "acme", Region: "ams", Dockerfile: "Dockerfile.prod"},
+		},
+		{
+			name:    "monorepo apps",
+			answers: Answers{Apps: []string{"apps/web", "apps/api"}, Org: "acme", Region: "ams"},
+		},
+		{
+			name:     "missing everything",
+			answers:  Answers{},
+			problems: []string{"org is required", "region is required", "name is required unless generate_name is set"},
+		},
+		{
+			name:     "name and generate_name",
+			answers:  Answers{Name: "acme-web", GenerateName: true, Org: "acme", Region: "ams"},
+			problems: []string{"name and generate_name are mutually exclusive"},
+		},
+		{
+			name:     "invalid name",
+			answers:  Answers{Name: "Acme_Web", Org: "acme", Region: "ams"},
+			problems: []string{`name "Acme_Web" may only contain lowercase letters, digits and dashes`},
+		},
+		{
+			name:    "apps with name and image",
+			answers: Answers{Apps: []string{"apps/web"}, Name: "acme-web", Image: "nginx", Org: "acme", Region: "ams"},
+			problems: []string{
+				"name and generate_name can't be used with apps, each app gets its detected name",
+				"image and dockerfile can't be used with apps",
+			},
+		},
+		{
+			name:     "image and dockerfile",
+			answers:  Answers{Name: "acme-web", Image: "nginx", Dockerfile: "Dockerfile", Org: "acme", Region: "ams"},
+			problems: []string{"image and dockerfile are mutually exclusive"},
+		},
+		{
+			name:     "empty secret name",
+			answers:  Answers{Name: "acme-web", Org: "acme", Region: "ams", Secrets: map[string]string{"": "value"}},
+			problems: []string{"secrets can't have an empty name"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.answers.Validate()
+			if len(c.problems) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, problem := range c.problems {
+				assert.Contains(t, err.Error(), problem)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package pgbackup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+)
+
+// Files stores backups on the local filesystem
+type Files struct{}
+
+// Create writes to a partial file next to the backup, renamed into place once committed
+func (Files) Create(ctx context.Context, loc Location) (Upload, error) {
+	if loc.IsS3() {
+		return nil, errors.New("not a local path: " + loc.String())
+	}
+	f, err := os.OpenFile(loc.Path+".partial", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &fileUpload{File: f, path: loc.Path}, nil
+}
+
+func (Files) Open(ctx context.Context, loc Location) (io.ReadCloser, error) {
+	if loc.IsS3() {
+		return nil, errors.New("not a local path: " + loc.String())
+	}
+	return os.Open(loc.Path)
+}
+
+type fileUpload struct {
+	*os.File
+	path string
+}
+
+func (u *fileUpload) Commit() error {
+	if err := u.Sync(); err != nil {
+		u.Abort()
+		return err
+	}
+	if err := u.Close(); err != nil {
+		os.Remove(u.Name())
+		return err
+	}
+	return os.Rename(u.Name(), u.path)
+}
+
+func (u *fileUpload) Abort() error {
+	u.Close()
+	return os.Remove(u.Name())
+}
@@ -0,0 +1,116 @@
+// Package pgbackup stores logical Postgres backups, pg_dump archives in custom format, in local files or
+// S3-compatible buckets next to a SHA-256 checksum.
+package pgbackup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// ChecksumSuffix is appended to the name of a backup for the file holding its checksum
+const ChecksumSuffix = ".sha256"
+
+// Location is where a backup is stored, a local path or a key in a bucket
+type Location struct {
+	Path   string
+	Bucket string
+	Key    string
+}
+
+// ParseLocation reads s3://<bucket>/<key> or a local path
+func ParseLocation(s string) (Location, error) {
+	if !strings.HasPrefix(s, "s3://") {
+		if s == "" {
+			return Location{}, errors.New("backup location is empty")
+		}
+		return Location{Path: s}, nil
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(s, "s3://"), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" || strings.HasSuffix(parts[1], "/") {
+		return Location{}, fmt.Errorf("invalid S3 location %q, expected s3://<bucket>/<key>", s)
+	}
+	return Location{Bucket: parts[0], Key: parts[1]}, nil
+}
+
+// IsS3 reports whether the location is in a bucket
+func (l Location) IsS3() bool {
+	return l.Bucket != ""
+}
+
+// Name is the base name of the backup
+func (l Location) Name() string {
+	if l.IsS3() {
+		return path.Base(l.Key)
+	}
+	return path.Base(strings.ReplaceAll(l.Path, "\\", "/"))
+}
+
+// Checksum is the location of the checksum stored with the backup
+func (l Location) Checksum() Location {
+	if l.IsS3() {
+		l.Key += ChecksumSuffix
+	} else {
+		l.Path += ChecksumSuffix
+	}
+	return l
+}
+
+func (l Location) String() string {
+	if l.IsS3() {
+		return "s3://" + l.Bucket + "/" + l.Key
+	}
+	return l.Path
+}
+
+// Upload is a backup being written, which only becomes visible once committed
+type Upload interface {
+	io.Writer
+	Commit() error
+	Abort() error
+}
+
+// Store writes and reads backups. Open returns an error matching fs.ErrNotExist when there's no backup.
+type Store interface {
+	Create(ctx context.Context, loc Location) (Upload, error)
+	Open(ctx context.Context, loc Location) (io.ReadCloser, error)
+}
+
+// FormatChecksum returns a checksum file for the backup called name, in the format of sha256sum
+func FormatChecksum(sum []byte, name string) []byte {
+	return []byte(fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum), name))
+}
+
+// ParseChecksum reads the hex encoded SHA-256 checksum from a checksum file
+func ParseChecksum(data []byte) (string, error) {
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return "", errors.New("checksum file is empty")
+	}
+	sum := strings.ToLower(fields[0])
+	if b, err := hex.DecodeString(sum); err != nil || len(b) != 32 {
+		return "", fmt.Errorf("invalid SHA-256 checksum %q", fields[0])
+	}
+	return sum, nil
+}
+
+var dumpHeader = []byte("PGDMP")
+
+// CheckHeader fails unless r starts with the header of a pg_dump archive in custom format, leaving it unread
+func CheckHeader(r *bufio.Reader) error {
+	header, err := r.Peek(len(dumpHeader))
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if !bytes.Equal(header, dumpHeader) {
+		return errors.New("not a pg_dump archive in custom format")
+	}
+	return nil
+}
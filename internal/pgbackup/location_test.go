@@ -0,0 +1,85 @@
+package pgbackup
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLocation(t *testing.T) {
+	loc, err := ParseLocation("s3://backups/myapp/2021-06-01.dump")
+	require.NoError(t, err)
+	assert.Equal(t, Location{Bucket: "backups", Key: "myapp/2021-06-01.dump"}, loc)
+	assert.Equal(t, "2021-06-01.dump", loc.Name())
+	assert.Equal(t, "s3://backups/myapp/2021-06-01.dump.sha256", loc.Checksum().String())
+
+	loc, err = ParseLocation("dumps/db.dump")
+	require.NoError(t, err)
+	assert.Equal(t, Location{Path: "dumps/db.dump"}, loc)
+	assert.Equal(t, "db.dump", loc.Name())
+	assert.Equal(t, "dumps/db.dump.sha256", loc.Checksum().String())
+
+	for _, invalid := range []string{"", "s3://backups", "s3://backups/", "s3:///key", "s3://backups/dir/"} {
+		_, err := ParseLocation(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	sum := sha256.Sum256([]byte("dump"))
+	data := FormatChecksum(sum[:], "db.dump")
+	assert.Equal(t, "b6ca0868bca6a2926b70aa1a71592038d9030fe26d4214edcfbd6cf41f2f4654  db.dump\n", string(data))
+
+	parsed, err := ParseChecksum(data)
+	require.NoError(t, err)
+	assert.Equal(t, "b6ca0868bca6a2926b70aa1a71592038d9030fe26d4214edcfbd6cf41f2f4654", parsed)
+
+	_, err = ParseChecksum([]byte("abc  db.dump\n"))
+	assert.Error(t, err)
+	_, err = ParseChecksum(nil)
+	assert.Error(t, err)
+}
+
+func TestCheckHeader(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("PGDMP\x01\x0e"))
+	require.NoError(t, CheckHeader(r))
+	rest, _ := io.ReadAll(r)
+	assert.Equal(t, "PGDMP\x01\x0e", string(rest))
+
+	assert.Error(t, CheckHeader(bufio.NewReader(strings.NewReader("-- PostgreSQL database dump"))))
+	assert.Error(t, CheckHeader(bufio.NewReader(strings.NewReader(""))))
+}
+
+func TestFiles(t *testing.T) {
+	ctx := context.Background()
+	loc := Location{Path: filepath.Join(t.TempDir(), "db.dump")}
+
+	upload, err := Files{}.Create(ctx, loc)
+	require.NoError(t, err)
+	io.WriteString(upload, "partial")
+	require.NoError(t, upload.Abort())
+	_, err = Files{}.Open(ctx, loc)
+	assert.True(t, os.IsNotExist(err))
+
+	upload, err = Files{}.Create(ctx, loc)
+	require.NoError(t, err)
+	io.WriteString(upload, "PGDMP")
+	require.NoError(t, upload.Commit())
+
+	f, err := Files{}.Open(ctx, loc)
+	require.NoError(t, err)
+	defer f.Close()
+	data, _ := io.ReadAll(f)
+	assert.Equal(t, "PGDMP", string(data))
+
+	_, err = os.Stat(loc.Path + ".partial")
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,341 @@
+package pgbackup
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PartSize is the size of the parts of multipart uploads. Backups up to 10,000 parts, about 156GB, fit.
+const PartSize = 16 << 20
+
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3 stores backups in S3 or an S3-compatible service like R2, Tigris or MinIO, addressing buckets by path
+type S3 struct {
+	Endpoint     string
+	Region       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewS3FromEnv reads credentials from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN. An empty
+// endpoint or region falls back to AWS_ENDPOINT_URL and AWS_REGION, then to AWS itself in us-east-1.
+func NewS3FromEnv(endpoint, region string) (*S3, error) {
+	if region == "" {
+		region = firstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	if endpoint == "" {
+		endpoint = firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL")
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+
+	s := &S3{
+		Endpoint:     strings.TrimRight(endpoint, "/"),
+		Region:       region,
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		client:       http.DefaultClient,
+		now:          time.Now,
+	}
+	if s.AccessKey == "" || s.SecretKey == "" {
+		return nil, errors.New("S3 credentials aren't configured, set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return s, nil
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Create uploads a backup in parts as it's written. Nothing is stored until it's committed.
+func (s *S3) Create(ctx context.Context, loc Location) (Upload, error) {
+	if !loc.IsS3() {
+		return nil, errors.New("not an S3 location: " + loc.String())
+	}
+	return &s3Upload{s3: s, ctx: ctx, loc: loc}, nil
+}
+
+func (s *S3) Open(ctx context.Context, loc Location) (io.ReadCloser, error) {
+	if !loc.IsS3() {
+		return nil, errors.New("not an S3 location: " + loc.String())
+	}
+	resp, err := s.do(ctx, http.MethodGet, loc, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+type s3Upload struct {
+	s3       *S3
+	ctx      context.Context
+	loc      Location
+	buf      bytes.Buffer
+	uploadID string
+	parts    []completedPart
+}
+
+type completedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+func (u *s3Upload) Write(p []byte) (int, error) {
+	n, _ := u.buf.Write(p)
+	for u.buf.Len() >= PartSize {
+		if err := u.uploadPart(u.buf.Next(PartSize)); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func (u *s3Upload) uploadPart(data []byte) error {
+	if u.uploadID == "" {
+		var result struct {
+			UploadID string `xml:"UploadId"`
+		}
+		if err := u.s3.doXML(u.ctx, http.MethodPost, u.loc, url.Values{"uploads": {""}}, nil, &result); err != nil {
+			return err
+		}
+		u.uploadID = result.UploadID
+	}
+
+	number := len(u.parts) + 1
+	query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {u.uploadID}}
+	resp, err := u.s3.do(u.ctx, http.MethodPut, u.loc, query, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	u.parts = append(u.parts, completedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+	return nil
+}
+
+// Commit stores small backups with a single request, and completes the multipart upload of larger ones
+func (u *s3Upload) Commit() error {
+	if u.uploadID == "" {
+		resp, err := u.s3.do(u.ctx, http.MethodPut, u.loc, nil, u.buf.Bytes())
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	if u.buf.Len() > 0 {
+		if err := u.uploadPart(u.buf.Bytes()); err != nil {
+			u.Abort()
+			return err
+		}
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: u.parts})
+	if err != nil {
+		return err
+	}
+	// errors completing an upload can come with a 200 status, so the body is always checked
+	var result struct {
+		XMLName xml.Name
+		Code    string
+		Message string
+	}
+	if err := u.s3.doXML(u.ctx, http.MethodPost, u.loc, url.Values{"uploadId": {u.uploadID}}, body, &result); err != nil {
+		u.Abort()
+		return err
+	}
+	if result.XMLName.Local == "Error" {
+		u.Abort()
+		return fmt.Errorf("S3 failed to complete the upload: %s: %s", result.Code, result.Message)
+	}
+	return nil
+}
+
+// Abort discards the parts uploaded so far, which are otherwise kept and billed
+func (u *s3Upload) Abort() error {
+	if u.uploadID == "" {
+		return nil
+	}
+	// the request context may be canceled already, which is often why the upload is aborted
+	resp, err := u.s3.do(context.Background(), http.MethodDelete, u.loc, url.Values{"uploadId": {u.uploadID}}, nil)
+	if err != nil {
+		return err
+	}
+	u.uploadID = ""
+	return resp.Body.Close()
+}
+
+func (s *S3) doXML(ctx context.Context, method string, loc Location, query url.Values, body []byte, out interface{}) error {
+	resp, err := s.do(ctx, method, loc, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return xml.NewDecoder(resp.Body).Decode(out)
+}
+
+// do sends a signed request for the object at loc, turning error responses into errors
+func (s *S3) do(ctx context.Context, method string, loc Location, query url.Values, body []byte) (*http.Response, error) {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint: %w", err)
+	}
+	u.Path = strings.TrimRight(u.Path, "/") + "/" + loc.Bucket + "/" + loc.Key
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	payloadHash := emptySHA256
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	s.sign(req, payloadHash, s.now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var e struct {
+			Code    string
+			Message string
+		}
+		xml.NewDecoder(resp.Body).Decode(&e)
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%s: %w", loc, fs.ErrNotExist)
+		}
+		if e.Code == "" {
+			e.Code = resp.Status
+		}
+		return nil, fmt.Errorf("S3 returned %d for %s: %s", resp.StatusCode, loc, strings.TrimSpace(e.Code+" "+e.Message))
+	}
+	return resp, nil
+}
+
+// sign adds an AWS Signature Version 4 to req, covering its host and all headers set so far
+func (s *S3) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	for _, part := range []string{s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery encodes query sorted by name the way signatures expect, which also works as the URL query
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var pairs []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			pairs = append(pairs, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode escapes everything but unreserved characters, and slashes unless encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
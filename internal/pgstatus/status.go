@@ -0,0 +1,131 @@
+// Package pgstatus reads the replication, connection and disk state of Postgres instances from the output of
+// psql and df.
+package pgstatus
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Query reports the state of an instance as a single row
+const Query = `SELECT
+	pg_is_in_recovery(),
+	COALESCE(CASE WHEN pg_is_in_recovery() THEN pg_last_wal_replay_lsn() ELSE pg_current_wal_lsn() END, '0/0'),
+	(SELECT count(*) FROM pg_stat_activity WHERE backend_type = 'client backend'),
+	current_setting('max_connections'),
+	current_setting('server_version');
+`
+
+// Instance is the state of a single instance, or the Error querying it
+type Instance struct {
+	ID             string
+	Region         string
+	Address        string
+	Role           string
+	ServerVersion  string `json:",omitempty"`
+	WALPosition    string `json:",omitempty"`
+	LagBytes       *int64 `json:",omitempty"`
+	Connections    int
+	MaxConnections int
+	DiskUsed       uint64
+	DiskSize       uint64
+	Error          string `json:",omitempty"`
+
+	lsn uint64
+}
+
+// Cluster is the state of the instances of a postgres app
+type Cluster struct {
+	App       string
+	Image     string
+	Version   string
+	HA        bool
+	Instances []*Instance
+}
+
+// ParseRow reads the row of Query into instance, its fields separated by zero bytes
+func ParseRow(row string, instance *Instance) error {
+	fields := strings.Split(strings.TrimSpace(row), "\x00")
+	if len(fields) != 5 {
+		return fmt.Errorf("unexpected status from psql: %q", row)
+	}
+
+	instance.Role = "leader"
+	if fields[0] == "t" {
+		instance.Role = "replica"
+	}
+
+	lsn, err := ParseLSN(fields[1])
+	if err != nil {
+		return err
+	}
+	instance.WALPosition, instance.lsn = fields[1], lsn
+
+	instance.Connections, _ = strconv.Atoi(fields[2])
+	instance.MaxConnections, _ = strconv.Atoi(fields[3])
+	if version := strings.Fields(fields[4]); len(version) > 0 {
+		instance.ServerVersion = version[0]
+	}
+	return nil
+}
+
+// ParseLSN converts a WAL position like 16/B374D848 to a byte offset
+func ParseLSN(s string) (uint64, error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid WAL position %q", s)
+	}
+	hi, err := strconv.ParseUint(parts[0], 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid WAL position %q", s)
+	}
+	lo, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid WAL position %q", s)
+	}
+	return hi<<32 | lo, nil
+}
+
+// ParseDiskUsage reads the used and total bytes from the output of df -P -k
+func ParseDiskUsage(out string) (used, size uint64) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) < 2 {
+		return 0, 0
+	}
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 3 {
+		return 0, 0
+	}
+	size, _ = strconv.ParseUint(fields[1], 10, 64)
+	used, _ = strconv.ParseUint(fields[2], 10, 64)
+	return used * 1024, size * 1024
+}
+
+// SetReplicationLag sets how far replicas are behind the leader, when there's exactly one, and returns the number
+// of leaders. Instances are queried at slightly different times, so the lag is approximate.
+func SetReplicationLag(instances []*Instance) int {
+	var leader *Instance
+	leaders := 0
+	for _, instance := range instances {
+		if instance.Error == "" && instance.Role == "leader" {
+			leader = instance
+			leaders++
+		}
+	}
+	if leaders != 1 {
+		return leaders
+	}
+
+	for _, instance := range instances {
+		if instance.Error != "" || instance.Role != "replica" {
+			continue
+		}
+		lag := int64(0)
+		if leader.lsn > instance.lsn {
+			lag = int64(leader.lsn - instance.lsn)
+		}
+		instance.LagBytes = &lag
+	}
+	return leaders
+}
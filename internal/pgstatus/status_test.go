@@ -0,0 +1,162 @@
+package pgstatus
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLSN(t *testing.T) {
+	cases := []struct {
+		name string
+		lsn  string
+		want uint64
+		err  bool
+	}{
+		{name: "normal", lsn: "16/B374D848", want: 0x16<<32 | 0xB374D848},
+		{name: "zero", lsn: "0/0", want: 0},
+		{name: "no separator", lsn: "16B374D848", err: true},
+		{name: "not hex", lsn: "16/XYZ", err: true},
+		{name: "overflow", lsn: "100000000/0", err: true},
+		{name: "empty", lsn: "", err: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseLSN(tc.lsn)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseRow(t *testing.T) {
+	cases := []struct {
+		name string
+		row  string
+		want Instance
+		err  bool
+	}{
+		{
+			name: "leader",
+			row:  "f\x0016/B374D848\x003\x00100\x0013.4 (Debian 13.4-1.pgdg100+1)\n",
+			want: Instance{Role: "leader", ServerVersion: "13.4", WALPosition: "16/B374D848", Connections: 3, MaxConnections: 100, lsn: 0x16<<32 | 0xB374D848},
+		},
+		{
+			name: "replica",
+			row:  "t\x0016/B3000000\x001\x00100\x0013.4",
+			want: Instance{Role: "replica", ServerVersion: "13.4", WALPosition: "16/B3000000", Connections: 1, MaxConnections: 100, lsn: 0x16<<32 | 0xB3000000},
+		},
+		{name: "malformed lsn", row: "f\x00garbage\x003\x00100\x0013.4", err: true},
+		{name: "missing columns", row: "f\x0016/B374D848\x003", err: true},
+		{name: "empty", row: "", err: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got Instance
+			err := ParseRow(tc.row, &got)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseDiskUsage(t *testing.T) {
+	cases := []struct {
+		name       string
+		out        string
+		used, size uint64
+	}{
+		{
+			name: "normal",
+			out:  "Filesystem     1024-blocks    Used Available Capacity Mounted on\n/dev/vdb           1031836  524288    454836      54% /data\n",
+			used: 524288 * 1024,
+			size: 1031836 * 1024,
+		},
+		{name: "header only", out: "Filesystem     1024-blocks    Used Available Capacity Mounted on\n"},
+		{name: "missing columns", out: "Filesystem     1024-blocks    Used\n/dev/vdb 1031836\n"},
+		{name: "empty", out: ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			used, size := ParseDiskUsage(tc.out)
+			assert.Equal(t, tc.used, used)
+			assert.Equal(t, tc.size, size)
+		})
+	}
+}
+
+func TestSetReplicationLag(t *testing.T) {
+	lag := func(n int64) *int64 { return &n }
+
+	cases := []struct {
+		name      string
+		instances []*Instance
+		leaders   int
+		lags      []*int64
+	}{
+		{
+			name: "replicas behind",
+			instances: []*Instance{
+				{Role: "leader", lsn: 1000},
+				{Role: "replica", lsn: 400},
+				{Role: "replica", lsn: 1000},
+			},
+			leaders: 1,
+			lags:    []*int64{nil, lag(600), lag(0)},
+		},
+		{
+			name: "replica ahead of the leader",
+			instances: []*Instance{
+				{Role: "leader", lsn: 1000},
+				{Role: "replica", lsn: 1200},
+			},
+			leaders: 1,
+			lags:    []*int64{nil, lag(0)},
+		},
+		{
+			name: "failed replica",
+			instances: []*Instance{
+				{Role: "leader", lsn: 1000},
+				{Role: "unknown", Error: "psql failed"},
+			},
+			leaders: 1,
+			lags:    []*int64{nil, nil},
+		},
+		{
+			name: "no leader",
+			instances: []*Instance{
+				{Role: "replica", lsn: 400},
+				{Role: "leader", Error: "psql failed"},
+			},
+			leaders: 0,
+			lags:    []*int64{nil, nil},
+		},
+		{
+			name: "split cluster",
+			instances: []*Instance{
+				{Role: "leader", lsn: 1000},
+				{Role: "leader", lsn: 900},
+				{Role: "replica", lsn: 400},
+			},
+			leaders: 2,
+			lags:    []*int64{nil, nil, nil},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.leaders, SetReplicationLag(tc.instances))
+			for i, instance := range tc.instances {
+				assert.Equal(t, tc.lags[i], instance.LagBytes, "instance %d", i)
+			}
+		})
+	}
+}
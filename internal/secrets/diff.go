@@ -0,0 +1,66 @@
+package secrets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Diff is how local secrets differ from those set on an app
+type Diff struct {
+	Added     []string
+	Changed   []string
+	Unchanged []string
+	// Unknown are set on the app with a digest that isn't in the format Digest computes, so they can't be compared
+	Unknown []string
+	// Removed are set on the app but missing locally
+	Removed []string
+}
+
+// Empty reports whether syncing would change anything, with removals counting only when pruning
+func (d Diff) Empty(prune bool) bool {
+	return len(d.Added) == 0 && len(d.Changed) == 0 && len(d.Unknown) == 0 && (!prune || len(d.Removed) == 0)
+}
+
+// Digest is the hex SHA-256 of a value, which the digests of app secrets are compared against
+func Digest(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// digestFormat matches digests that look like Digest, possibly truncated. Shorter prefixes could match a
+// different value by chance.
+var digestFormat = regexp.MustCompile(`^[0-9a-f]{16,64}$`)
+
+// Compare diffs local secrets with the digests of the secrets set on an app, by name. Only digests in the
+// format of Digest are compared, other values are Unknown rather than reported as changed.
+func Compare(local map[string]string, remoteDigests map[string]string) Diff {
+	var d Diff
+	for key, value := range local {
+		digest, ok := remoteDigests[key]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, key)
+		case !digestFormat.MatchString(strings.ToLower(digest)):
+			d.Unknown = append(d.Unknown, key)
+		case strings.HasPrefix(Digest(value), strings.ToLower(digest)):
+			d.Unchanged = append(d.Unchanged, key)
+		default:
+			d.Changed = append(d.Changed, key)
+		}
+	}
+	for key := range remoteDigests {
+		if _, ok := local[key]; !ok {
+			d.Removed = append(d.Removed, key)
+		}
+	}
+
+	sort.Strings(d.Added)
+	sort.Strings(d.Changed)
+	sort.Strings(d.Unchanged)
+	sort.Strings(d.Unknown)
+	sort.Strings(d.Removed)
+	return d
+}
@@ -0,0 +1,31 @@
+package secrets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	local := map[string]string{"NEW": "1", "SAME": "unchanged", "EDITED": "new value", "SHORT": "x", "OTHER": "z", "EMPTY": "e"}
+	remote := map[string]string{
+		"SAME":   Digest("unchanged"),
+		"EDITED": Digest("old value"),
+		"SHORT":  Digest("x")[:16],
+		"OTHER":  "sha1:395df8f7c51f007019cb30201c49e884b46b92fa",
+		"EMPTY":  "",
+		"GONE":   Digest("y"),
+	}
+
+	d := Compare(local, remote)
+	assert.Equal(t, []string{"NEW"}, d.Added)
+	assert.Equal(t, []string{"EDITED"}, d.Changed)
+	assert.Equal(t, []string{"SAME", "SHORT"}, d.Unchanged)
+	assert.Equal(t, []string{"EMPTY", "OTHER"}, d.Unknown)
+	assert.Equal(t, []string{"GONE"}, d.Removed)
+	assert.False(t, d.Empty(false))
+
+	d = Compare(map[string]string{"SAME": "unchanged"}, remote)
+	assert.True(t, d.Empty(false))
+	assert.False(t, d.Empty(true))
+}
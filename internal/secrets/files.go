@@ -0,0 +1,84 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Sops reads dotenv, JSON and YAML files encrypted with SOPS, decrypting them with the sops command
+type Sops struct {
+	Command string
+}
+
+func NewSops() *Sops {
+	return &Sops{Command: "sops"}
+}
+
+func (s *Sops) Name() string {
+	return "sops"
+}
+
+// List decrypts the file at path and returns its secrets
+func (s *Sops) List(ctx context.Context, path string) (map[string]string, error) {
+	plaintext, err := decrypt(ctx, s.Command, "--decrypt", path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(path, plaintext, FormatAuto)
+}
+
+// Age reads dotenv, JSON and YAML files encrypted with age, like .env.production.age, decrypting them with the
+// age command and an identity file
+type Age struct {
+	Command      string
+	IdentityFile string
+}
+
+// NewAgeFromEnv uses the identity file in AGE_IDENTITY_FILE, or ~/.config/age/keys.txt
+func NewAgeFromEnv() *Age {
+	a := &Age{Command: "age", IdentityFile: os.Getenv("AGE_IDENTITY_FILE")}
+	if a.IdentityFile == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			a.IdentityFile = filepath.Join(home, ".config", "age", "keys.txt")
+		}
+	}
+	return a
+}
+
+func (a *Age) Name() string {
+	return "age"
+}
+
+// List decrypts the file at path and returns its secrets, in the format of its name without .age
+func (a *Age) List(ctx context.Context, path string) (map[string]string, error) {
+	plaintext, err := decrypt(ctx, a.Command, "--decrypt", "--identity", a.IdentityFile, path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(strings.TrimSuffix(path, ".age"), plaintext, FormatAuto)
+}
+
+// decrypt runs a decryption command and returns what it wrote to stdout
+func decrypt(ctx context.Context, command string, args ...string) ([]byte, error) {
+	binary, err := exec.LookPath(command)
+	if err != nil {
+		return nil, fmt.Errorf("%s not found in $PATH, install it to decrypt secrets", command)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s failed: %s", command, msg)
+		}
+		return nil, fmt.Errorf("%s failed: %w", command, err)
+	}
+	return stdout.Bytes(), nil
+}
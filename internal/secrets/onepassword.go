@@ -0,0 +1,119 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// OnePassword reads the fields of items through a 1Password Connect server
+type OnePassword struct {
+	Host   string
+	Token  string
+	client *http.Client
+}
+
+// NewOnePasswordFromEnv configures 1Password Connect from OP_CONNECT_HOST and OP_CONNECT_TOKEN
+func NewOnePasswordFromEnv() *OnePassword {
+	return &OnePassword{
+		Host:   os.Getenv("OP_CONNECT_HOST"),
+		Token:  os.Getenv("OP_CONNECT_TOKEN"),
+		client: providerHTTPClient,
+	}
+}
+
+func (o *OnePassword) Name() string {
+	return "op"
+}
+
+// List reads the fields of the item at path, a vault and item title or ID like Production/stripe, by label
+func (o *OnePassword) List(ctx context.Context, path string) (map[string]string, error) {
+	if o.Host == "" || o.Token == "" {
+		return nil, fmt.Errorf("1Password Connect isn't configured, set OP_CONNECT_HOST and OP_CONNECT_TOKEN")
+	}
+
+	parts := strings.SplitN(strings.Trim(path, "/"), "/", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid 1Password path %q, expected <vault>/<item>", path)
+	}
+
+	vaultID, err := o.find(ctx, "vaults", "name", parts[0])
+	if err != nil {
+		return nil, err
+	}
+	itemID, err := o.find(ctx, "vaults/"+vaultID+"/items", "title", parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	var item struct {
+		Fields []struct {
+			ID    string `json:"id"`
+			Label string `json:"label"`
+			Value string `json:"value"`
+		} `json:"fields"`
+	}
+	if err := o.get(ctx, "vaults/"+vaultID+"/items/"+itemID, &item); err != nil {
+		return nil, err
+	}
+
+	fields := map[string]string{}
+	for _, f := range item.Fields {
+		if f.Value == "" {
+			continue
+		}
+		name := f.Label
+		if name == "" {
+			name = f.ID
+		}
+		fields[name] = f.Value
+	}
+	return fields, nil
+}
+
+// find returns the ID of the vault or item whose attr is name, or name itself when nothing matches so IDs work too
+func (o *OnePassword) find(ctx context.Context, collection, attr, name string) (string, error) {
+	var found []struct {
+		ID string `json:"id"`
+	}
+	filter := url.QueryEscape(fmt.Sprintf("%s eq %q", attr, name))
+	if err := o.get(ctx, collection+"?filter="+filter, &found); err != nil {
+		return "", err
+	}
+	switch len(found) {
+	case 0:
+		return name, nil
+	case 1:
+		return found[0].ID, nil
+	default:
+		return "", fmt.Errorf("%d 1Password %s are called %s, use an ID instead", len(found), collection, name)
+	}
+}
+
+func (o *OnePassword) get(ctx context.Context, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(o.Host, "/")+"/v1/"+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+o.Token)
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return fmt.Errorf("1Password Connect returned %d: %s", resp.StatusCode, body.Message)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
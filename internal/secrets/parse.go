@@ -0,0 +1,321 @@
+// Package secrets reads app secrets from files and external secret managers. Values are never included in
+// errors.
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats secrets can be read from
+const (
+	FormatAuto   = "auto"
+	FormatDotenv = "dotenv"
+	FormatJSON   = "json"
+	FormatYAML   = "yaml"
+)
+
+var validKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateKey checks that key can be used as an environment variable name
+func ValidateKey(key string) error {
+	if !validKey.MatchString(key) {
+		return fmt.Errorf("invalid secret name %q, names may only contain letters, digits and underscores and can't start with a digit", key)
+	}
+	return nil
+}
+
+// ParseError is an error at a line of the secrets being parsed
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// DetectFormat guesses the format of data, using the extension of filename when there is one
+func DetectFormat(filename string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".env":
+		return FormatDotenv
+	}
+	if strings.HasPrefix(filepath.Base(filename), ".env") {
+		return FormatDotenv
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return FormatJSON
+	}
+	for _, line := range strings.Split(string(trimmed), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if dotenvAssignment.MatchString(line) {
+			return FormatDotenv
+		}
+		return FormatYAML
+	}
+	return FormatDotenv
+}
+
+// Parse reads secrets in format, detecting it from filename and data for FormatAuto or an empty format
+func Parse(filename string, data []byte, format string) (map[string]string, error) {
+	if format == "" || format == FormatAuto {
+		format = DetectFormat(filename, data)
+	}
+
+	switch format {
+	case FormatDotenv:
+		return ParseDotenv(data)
+	case FormatJSON:
+		return ParseJSON(data)
+	case FormatYAML:
+		return ParseYAML(data)
+	default:
+		return nil, fmt.Errorf("unknown secrets format %q, use one of %s, %s, %s or %s", format, FormatAuto, FormatDotenv, FormatJSON, FormatYAML)
+	}
+}
+
+// Keys returns the names of secrets, sorted
+func Keys(secrets map[string]string) []string {
+	keys := make([]string, 0, len(secrets))
+	for key := range secrets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var dotenvAssignment = regexp.MustCompile(`^(?:export\s+)?([^=\s]+)\s*=\s*(.*)$`)
+
+// ParseDotenv reads KEY=VALUE lines. Values may be single quoted and taken literally, double quoted with
+// \n, \r, \t, \", \\ and \$ escapes, or wrapped in """ for literal multiline values; double and single
+// quoted values can span lines too. Unquoted values end at a " #" comment. Blank lines, # comments and
+// export prefixes are ignored.
+func ParseDotenv(data []byte) (map[string]string, error) {
+	secrets := map[string]string{}
+	definedAt := map[string]int{}
+
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		m := dotenvAssignment.FindStringSubmatch(line)
+		if m == nil {
+			return nil, &ParseError{Line: lineNo, Err: fmt.Errorf("expected NAME=VALUE")}
+		}
+		key, rest := m[1], m[2]
+		if err := ValidateKey(key); err != nil {
+			return nil, &ParseError{Line: lineNo, Err: err}
+		}
+		if prev, ok := definedAt[key]; ok {
+			return nil, &ParseError{Line: lineNo, Err: fmt.Errorf("%s is already set on line %d", key, prev)}
+		}
+
+		var value string
+		var err error
+		switch {
+		case strings.HasPrefix(rest, `"""`):
+			value, i, err = readDelimited(lines, i, strings.TrimPrefix(rest, `"""`), `"""`)
+		case strings.HasPrefix(rest, `"`):
+			value, i, err = readDelimited(lines, i, rest[1:], `"`)
+			if err == nil {
+				value, err = unescape(value)
+			}
+		case strings.HasPrefix(rest, `'`):
+			value, i, err = readDelimited(lines, i, rest[1:], `'`)
+		default:
+			value = rest
+			if idx := strings.Index(value, " #"); idx >= 0 {
+				value = value[:idx]
+			}
+			value = strings.TrimSpace(value)
+		}
+		if err != nil {
+			return nil, &ParseError{Line: lineNo, Err: fmt.Errorf("%s: %w", key, err)}
+		}
+
+		secrets[key] = value
+		definedAt[key] = lineNo
+	}
+
+	return secrets, nil
+}
+
+// readDelimited reads a quoted value starting with first on line i up to the closing quote, which may be on a
+// later line. It returns the value and the index of the line it ended on.
+func readDelimited(lines []string, i int, first string, quote string) (string, int, error) {
+	var sb strings.Builder
+	text := first
+	for {
+		if end := closingQuote(text, quote); end >= 0 {
+			sb.WriteString(text[:end])
+			trailing := strings.TrimSpace(text[end+len(quote):])
+			if trailing != "" && !strings.HasPrefix(trailing, "#") {
+				return "", i, fmt.Errorf("unexpected characters after the closing %s", quote)
+			}
+			return sb.String(), i, nil
+		}
+
+		sb.WriteString(text)
+		sb.WriteString("\n")
+		i++
+		if i >= len(lines) {
+			return "", i, fmt.Errorf("missing closing %s", quote)
+		}
+		text = lines[i]
+	}
+}
+
+// closingQuote finds quote in text, skipping backslash escaped double quotes
+func closingQuote(text, quote string) int {
+	if quote != `"` {
+		return strings.Index(text, quote)
+	}
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func unescape(s string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", fmt.Errorf("trailing backslash")
+		}
+		switch s[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case '"', '\\', '$', '\'':
+			sb.WriteByte(s[i])
+		case '\n':
+			// a backslash at the end of a line continues it
+		default:
+			return "", fmt.Errorf(`unknown escape sequence \%c`, s[i])
+		}
+	}
+	return sb.String(), nil
+}
+
+// ParseJSON reads an object of names to strings, numbers or booleans
+func ParseJSON(data []byte) (map[string]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var values map[string]interface{}
+	if err := decoder.Decode(&values); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return nil, &ParseError{Line: lineAt(data, syntaxErr.Offset), Err: fmt.Errorf("invalid JSON: %s", syntaxErr)}
+		}
+		return nil, errors.New("secrets must be a JSON object of names to values")
+	}
+
+	return toSecrets(values)
+}
+
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// ParseYAML reads a mapping of names to scalars. Values keep their text as written, so 0123, yes or 1.10 aren't
+// turned into numbers or booleans first.
+func ParseYAML(data []byte) (map[string]string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		// syntax errors already name the line
+		return nil, fmt.Errorf("invalid YAML: %s", strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+	if len(doc.Content) == 0 {
+		return map[string]string{}, nil
+	}
+	mapping := doc.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return nil, errors.New("secrets must be a YAML mapping of names to values")
+	}
+
+	secrets := make(map[string]string, len(mapping.Content)/2)
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		keyNode, valueNode := mapping.Content[i], mapping.Content[i+1]
+		if keyNode.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("line %d: secret names must be strings", keyNode.Line)
+		}
+		key := keyNode.Value
+		if err := ValidateKey(key); err != nil {
+			return nil, err
+		}
+
+		if valueNode.Kind == yaml.AliasNode {
+			valueNode = valueNode.Alias
+		}
+		if valueNode.Kind != yaml.ScalarNode || valueNode.Tag == "!!null" {
+			return nil, fmt.Errorf("%s: values must be strings, numbers or booleans", key)
+		}
+		secrets[key] = valueNode.Value
+	}
+	return secrets, nil
+}
+
+func toSecrets(values map[string]interface{}) (map[string]string, error) {
+	secrets := make(map[string]string, len(values))
+	for key, v := range values {
+		if err := ValidateKey(key); err != nil {
+			return nil, err
+		}
+
+		switch v := v.(type) {
+		case string:
+			secrets[key] = v
+		case json.Number:
+			secrets[key] = v.String()
+		case bool:
+			secrets[key] = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("%s: values must be strings, numbers or booleans", key)
+		}
+	}
+	return secrets, nil
+}
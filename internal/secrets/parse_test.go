@@ -0,0 +1,102 @@
+package secrets
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDotenv(t *testing.T) {
+	data := `# database
+export DATABASE_URL=postgres://u:p@host/db  # primary
+EMPTY=
+SINGLE='literal \n $HOME'
+DOUBLE="tab\tquote\" dollar\$"
+MULTI="line one
+line two"
+LEGACY="""-----BEGIN KEY-----
+abc
+-----END KEY-----"""
+HASH=abc#def
+`
+	secrets, err := ParseDotenv([]byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"DATABASE_URL": "postgres://u:p@host/db",
+		"EMPTY":        "",
+		"SINGLE":       `literal \n $HOME`,
+		"DOUBLE":       "tab\tquote\" dollar$",
+		"MULTI":        "line one\nline two",
+		"LEGACY":       "-----BEGIN KEY-----\nabc\n-----END KEY-----",
+		"HASH":         "abc#def",
+	}, secrets)
+}
+
+func TestParseDotenvErrors(t *testing.T) {
+	cases := map[string]struct {
+		data string
+		line int
+	}{
+		"missing equals":  {"A=1\nsupersecretvalue\n", 2},
+		"invalid name":    {"A=1\n\n1BAD=x\n", 3},
+		"duplicate":       {"A=1\nA=2\n", 2},
+		"unterminated":    {"A=1\nB=\"open\nstill open\n", 2},
+		"bad escape":      {`A="\q"`, 1},
+		"trailing string": {`A="x" y`, 1},
+	}
+
+	for name, c := range cases {
+		_, err := ParseDotenv([]byte(c.data))
+		require.Error(t, err, name)
+
+		var parseErr *ParseError
+		require.True(t, errors.As(err, &parseErr), name)
+		assert.Equal(t, c.line, parseErr.Line, name)
+		assert.NotContains(t, err.Error(), "supersecretvalue", name)
+	}
+}
+
+func TestParseJSONAndYAML(t *testing.T) {
+	secrets, err := Parse("secrets.json", []byte(`{"API_KEY": "abc", "PORT": 8080, "DEBUG": true}`), FormatAuto)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"API_KEY": "abc", "PORT": "8080", "DEBUG": "true"}, secrets)
+
+	secrets, err = Parse("", []byte("API_KEY: abc\nRATIO: 0.5\n"), FormatAuto)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"API_KEY": "abc", "RATIO": "0.5"}, secrets)
+
+	_, err = ParseJSON([]byte("{\n\"A\": \"x\",\n}"))
+	var parseErr *ParseError
+	require.True(t, errors.As(err, &parseErr))
+	assert.Equal(t, 3, parseErr.Line)
+
+	_, err = ParseJSON([]byte(`{"A": {"nested": "supersecretvalue"}}`))
+	assert.EqualError(t, err, "A: values must be strings, numbers or booleans")
+
+	_, err = ParseYAML([]byte("supersecretvalue"))
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "supersecretvalue")
+
+	_, err = ParseYAML([]byte("bad-name: x"))
+	assert.Error(t, err)
+
+	secrets, err = ParseYAML([]byte("PIN: 0123\nENABLED: yes\nVERSION: 1.10\nQUOTED: \"on\"\n"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"PIN": "0123", "ENABLED": "yes", "VERSION": "1.10", "QUOTED": "on"}, secrets)
+
+	_, err = ParseYAML([]byte("A:\n  - supersecretvalue\n"))
+	assert.EqualError(t, err, "A: values must be strings, numbers or booleans")
+
+	_, err = ParseYAML([]byte("A: ~\n"))
+	assert.EqualError(t, err, "A: values must be strings, numbers or booleans")
+}
+
+func TestDetectFormat(t *testing.T) {
+	assert.Equal(t, FormatDotenv, DetectFormat(".env.production", []byte("A: b")))
+	assert.Equal(t, FormatYAML, DetectFormat("secrets.yml", nil))
+	assert.Equal(t, FormatJSON, DetectFormat("", []byte(" {\"A\": 1}")))
+	assert.Equal(t, FormatDotenv, DetectFormat("", []byte("# comment\nexport A=1")))
+	assert.Equal(t, FormatYAML, DetectFormat("", []byte("A: 1")))
+}
@@ -0,0 +1,154 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReferencePrefix starts values that refer to a secret in an external secret manager, like
+// ref+vault://secret/myapp#password
+const ReferencePrefix = "ref+"
+
+// Provider reads secrets from an external secret manager
+type Provider interface {
+	// Name is the scheme of references to the provider
+	Name() string
+	// List returns every field of the secret at path
+	List(ctx context.Context, path string) (map[string]string, error)
+}
+
+// Reference points to a field of a secret kept by a provider
+type Reference struct {
+	Provider string
+	Path     string
+	Field    string
+}
+
+func (r Reference) String() string {
+	s := ReferencePrefix + r.Provider + "://" + r.Path
+	if r.Field != "" {
+		s += "#" + r.Field
+	}
+	return s
+}
+
+// IsReference reports whether value refers to an external secret rather than being one
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, ReferencePrefix)
+}
+
+// ParseReference parses values like ref+vault://secret/myapp#password
+func ParseReference(value string) (Reference, error) {
+	rest := strings.TrimPrefix(value, ReferencePrefix)
+	idx := strings.Index(rest, "://")
+	if !IsReference(value) || idx <= 0 {
+		return Reference{}, fmt.Errorf("invalid reference %q, expected ref+<provider>://<path>#<field>", value)
+	}
+
+	ref := Reference{Provider: rest[:idx]}
+	ref.Path = rest[idx+len("://"):]
+	if i := strings.LastIndex(ref.Path, "#"); i >= 0 {
+		ref.Path, ref.Field = ref.Path[:i], ref.Path[i+1:]
+	}
+	if ref.Path == "" {
+		return Reference{}, fmt.Errorf("invalid reference %q, the path is empty", value)
+	}
+	return ref, nil
+}
+
+// Providers are the secret managers references can be resolved with, by name
+type Providers map[string]Provider
+
+// NewProviders indexes providers by name
+func NewProviders(providers ...Provider) Providers {
+	ps := Providers{}
+	for _, p := range providers {
+		ps[p.Name()] = p
+	}
+	return ps
+}
+
+// DefaultProviders are the providers configured from the environment: Vault, SOPS, 1Password Connect and age
+func DefaultProviders() Providers {
+	return NewProviders(NewVaultFromEnv(), NewSops(), NewOnePasswordFromEnv(), NewAgeFromEnv())
+}
+
+// Get returns the provider called name
+func (ps Providers) Get(name string) (Provider, error) {
+	p, ok := ps[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown secrets provider %q, use one of %s", name, strings.Join(ps.Names(), ", "))
+	}
+	return p, nil
+}
+
+// Names returns the names of the providers, sorted
+func (ps Providers) Names() []string {
+	names := make([]string, 0, len(ps))
+	for name := range ps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve returns secrets with references replaced by the values they point to. Secrets at the same path are
+// read once.
+func (ps Providers) Resolve(ctx context.Context, secrets map[string]string) (map[string]string, error) {
+	type location struct{ provider, path string }
+	cache := map[location]map[string]string{}
+
+	resolved := make(map[string]string, len(secrets))
+	for key, value := range secrets {
+		if !IsReference(value) {
+			resolved[key] = value
+			continue
+		}
+
+		ref, err := ParseReference(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		p, err := ps.Get(ref.Provider)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+
+		loc := location{ref.Provider, ref.Path}
+		fields, ok := cache[loc]
+		if !ok {
+			if fields, err = p.List(ctx, ref.Path); err != nil {
+				return nil, fmt.Errorf("%s: error resolving %s: %w", key, ref, err)
+			}
+			cache[loc] = fields
+		}
+		if resolved[key], err = getField(fields, ref.Path, ref.Field); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	return resolved, nil
+}
+
+// getField picks field out of the fields of a secret, or its only field when field is empty
+func getField(fields map[string]string, path, field string) (string, error) {
+	if field == "" {
+		if len(fields) != 1 {
+			return "", fmt.Errorf("%s has %d fields, pick one with #<field>", path, len(fields))
+		}
+		for _, v := range fields {
+			return v, nil
+		}
+	}
+	v, ok := fields[field]
+	if !ok {
+		return "", fmt.Errorf("%s has no field %s", path, field)
+	}
+	return v, nil
+}
+
+var providerHTTPClient = &http.Client{Timeout: 30 * time.Second}
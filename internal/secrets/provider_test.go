@@ -0,0 +1,139 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticProvider struct {
+	values map[string]map[string]string
+	calls  int
+}
+
+func (p *staticProvider) Name() string {
+	return "static"
+}
+
+func (p *staticProvider) List(ctx context.Context, path string) (map[string]string, error) {
+	p.calls++
+	return p.values[path], nil
+}
+
+func TestParseReference(t *testing.T) {
+	ref, err := ParseReference("ref+vault://secret/myapp#password")
+	require.NoError(t, err)
+	assert.Equal(t, Reference{Provider: "vault", Path: "secret/myapp", Field: "password"}, ref)
+	assert.Equal(t, "ref+vault://secret/myapp#password", ref.String())
+
+	ref, err = ParseReference("ref+age://.env.age")
+	require.NoError(t, err)
+	assert.Equal(t, "", ref.Field)
+
+	_, err = ParseReference("ref+vault:secret")
+	assert.Error(t, err)
+	_, err = ParseReference("ref+vault://#x")
+	assert.Error(t, err)
+}
+
+func TestResolve(t *testing.T) {
+	static := &staticProvider{values: map[string]map[string]string{
+		"db":  {"user": "app", "password": "hunter2"},
+		"one": {"token": "abc"},
+	}}
+	providers := NewProviders(static)
+
+	resolved, err := providers.Resolve(context.Background(), map[string]string{
+		"PLAIN":   "value",
+		"DB_USER": "ref+static://db#user",
+		"DB_PASS": "ref+static://db#password",
+		"TOKEN":   "ref+static://one",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"PLAIN": "value", "DB_USER": "app", "DB_PASS": "hunter2", "TOKEN": "abc"}, resolved)
+	assert.Equal(t, 2, static.calls)
+
+	_, err = providers.Resolve(context.Background(), map[string]string{"X": "ref+static://db"})
+	assert.EqualError(t, err, "X: db has 2 fields, pick one with #<field>")
+
+	_, err = providers.Resolve(context.Background(), map[string]string{"X": "ref+nope://db#x"})
+	assert.EqualError(t, err, `X: unknown secrets provider "nope", use one of static`)
+}
+
+func TestVault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{"permission denied"}})
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/sys/internal/ui/mounts/kv/myapp":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"path": "kv/", "options": map[string]string{"version": "2"}}})
+		case "/v1/kv/data/myapp":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"data": map[string]interface{}{"password": "hunter2", "port": 5432}}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	v := &Vault{Addr: server.URL, Token: "s.token", client: server.Client()}
+	fields, err := v.List(context.Background(), "kv/myapp")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"password": "hunter2", "port": "5432"}, fields)
+
+	v.Token = "wrong"
+	_, err = v.List(context.Background(), "kv/myapp")
+	assert.EqualError(t, err, "vault returned 403: permission denied")
+}
+
+func TestOnePassword(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer op-token", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/v1/vaults":
+			assert.Equal(t, `name eq "Production"`, r.URL.Query().Get("filter"))
+			json.NewEncoder(w).Encode([]map[string]string{{"id": "v1"}})
+		case "/v1/vaults/v1/items":
+			json.NewEncoder(w).Encode([]map[string]string{{"id": "i1"}})
+		case "/v1/vaults/v1/items/i1":
+			json.NewEncoder(w).Encode(map[string]interface{}{"fields": []map[string]string{
+				{"id": "password", "label": "password", "value": "sk_live"},
+				{"id": "notes", "label": "notes", "value": ""},
+			}})
+		}
+	}))
+	defer server.Close()
+
+	o := &OnePassword{Host: server.URL, Token: "op-token", client: server.Client()}
+	fields, err := o.List(context.Background(), "Production/stripe")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"password": "sk_live"}, fields)
+}
+
+func TestSopsDecryptsWithCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script")
+	}
+	dir := t.TempDir()
+	script := filepath.Join(dir, "fake-sops")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\necho 'API_KEY=decrypted'\n"), 0755))
+
+	s := &Sops{Command: script}
+	fields, err := s.List(context.Background(), "secrets.env")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"API_KEY": "decrypted"}, fields)
+
+	s.Command = filepath.Join(dir, "missing")
+	_, err = s.List(context.Background(), "secrets.env")
+	assert.Error(t, err)
+}
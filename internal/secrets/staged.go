@@ -0,0 +1,99 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Staged are secret changes recorded for an app, to be applied together later. References to secret managers
+// are kept as is and resolved when applying.
+type Staged struct {
+	Set   map[string]string `json:"set,omitempty"`
+	Unset []string          `json:"unset,omitempty"`
+}
+
+func stagedPath(dir, appName string) string {
+	return filepath.Join(dir, appName+".json")
+}
+
+// LoadStaged reads the changes staged for an app in dir, which are empty when there are none
+func LoadStaged(dir, appName string) (*Staged, error) {
+	staged := &Staged{Set: map[string]string{}}
+
+	data, err := os.ReadFile(stagedPath(dir, appName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return staged, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, staged); err != nil {
+		return nil, err
+	}
+	if staged.Set == nil {
+		staged.Set = map[string]string{}
+	}
+	return staged, nil
+}
+
+// Save writes the changes to dir, readable only by the user, or removes them when there are none left
+func (s *Staged) Save(dir, appName string) error {
+	if s.Empty() {
+		return ClearStaged(dir, appName)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(stagedPath(dir, appName), data, 0600)
+}
+
+// ClearStaged removes the changes staged for an app
+func ClearStaged(dir, appName string) error {
+	err := os.Remove(stagedPath(dir, appName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Empty reports whether nothing is staged
+func (s *Staged) Empty() bool {
+	return len(s.Set) == 0 && len(s.Unset) == 0
+}
+
+// Mixed reports whether values and removals are both staged, which no single release can apply
+func (s *Staged) Mixed() bool {
+	return len(s.Set) > 0 && len(s.Unset) > 0
+}
+
+// SetValue stages setting key, replacing an unset staged before
+func (s *Staged) SetValue(key, value string) {
+	s.Set[key] = value
+	s.Unset = remove(s.Unset, key)
+}
+
+// UnsetKey stages removing key, replacing a value staged before
+func (s *Staged) UnsetKey(key string) {
+	delete(s.Set, key)
+	s.Unset = append(remove(s.Unset, key), key)
+	sort.Strings(s.Unset)
+}
+
+func remove(keys []string, key string) []string {
+	out := keys[:0]
+	for _, k := range keys {
+		if k != key {
+			out = append(out, k)
+		}
+	}
+	return out
+}
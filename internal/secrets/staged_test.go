@@ -0,0 +1,40 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaged(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "staged-secrets")
+
+	staged, err := LoadStaged(dir, "myapp")
+	require.NoError(t, err)
+	assert.True(t, staged.Empty())
+
+	staged.SetValue("DB_PASSWORD", "ref+vault://secret/db#password")
+	staged.SetValue("OLD_KEY", "x")
+	staged.UnsetKey("OLD_KEY")
+	staged.UnsetKey("LEGACY")
+	staged.SetValue("LEGACY", "back")
+	require.NoError(t, staged.Save(dir, "myapp"))
+
+	info, err := os.Stat(filepath.Join(dir, "myapp.json"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	staged, err = LoadStaged(dir, "myapp")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"DB_PASSWORD": "ref+vault://secret/db#password", "LEGACY": "back"}, staged.Set)
+	assert.Equal(t, []string{"OLD_KEY"}, staged.Unset)
+	assert.True(t, staged.Mixed())
+
+	staged = &Staged{Set: map[string]string{}}
+	require.NoError(t, staged.Save(dir, "myapp"))
+	_, err = os.Stat(filepath.Join(dir, "myapp.json"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,142 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Vault reads secrets from the KV secrets engine of HashiCorp Vault, version 1 or 2
+type Vault struct {
+	Addr      string
+	Token     string
+	Namespace string
+	client    *http.Client
+}
+
+// NewVaultFromEnv configures Vault like its CLI does, from VAULT_ADDR, VAULT_TOKEN or ~/.vault-token and
+// VAULT_NAMESPACE
+func NewVaultFromEnv() *Vault {
+	v := &Vault{
+		Addr:      os.Getenv("VAULT_ADDR"),
+		Token:     os.Getenv("VAULT_TOKEN"),
+		Namespace: os.Getenv("VAULT_NAMESPACE"),
+		client:    providerHTTPClient,
+	}
+	if v.Addr == "" {
+		v.Addr = "https://127.0.0.1:8200"
+	}
+	if v.Token == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			if token, err := os.ReadFile(filepath.Join(home, ".vault-token")); err == nil {
+				v.Token = strings.TrimSpace(string(token))
+			}
+		}
+	}
+	return v
+}
+
+func (v *Vault) Name() string {
+	return "vault"
+}
+
+// List reads the secret at path, like secret/myapp, which starts with the mount of the KV engine
+func (v *Vault) List(ctx context.Context, path string) (map[string]string, error) {
+	if v.Token == "" {
+		return nil, fmt.Errorf("no Vault token, set VAULT_TOKEN or log in with vault login")
+	}
+	path = strings.Trim(path, "/")
+
+	mount, version := v.mount(ctx, path)
+
+	var data map[string]interface{}
+	if version == "2" {
+		var resp struct {
+			Data struct {
+				Data map[string]interface{} `json:"data"`
+			} `json:"data"`
+		}
+		rest := strings.TrimPrefix(strings.TrimPrefix(path, mount), "/")
+		if err := v.get(ctx, mount+"/data/"+rest, &resp); err != nil {
+			return nil, err
+		}
+		data = resp.Data.Data
+	} else {
+		var resp struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		if err := v.get(ctx, path, &resp); err != nil {
+			return nil, err
+		}
+		data = resp.Data
+	}
+
+	return stringValues(data), nil
+}
+
+// mount returns the mount path and KV version of path, assuming version 2 at its first element when Vault
+// can't tell
+func (v *Vault) mount(ctx context.Context, path string) (string, string) {
+	var resp struct {
+		Data struct {
+			Path    string            `json:"path"`
+			Options map[string]string `json:"options"`
+		} `json:"data"`
+	}
+	if err := v.get(ctx, "sys/internal/ui/mounts/"+path, &resp); err == nil && resp.Data.Path != "" {
+		version := resp.Data.Options["version"]
+		if version == "" {
+			version = "1"
+		}
+		return strings.Trim(resp.Data.Path, "/"), version
+	}
+	return strings.SplitN(path, "/", 2)[0], "2"
+}
+
+func (v *Vault) get(ctx context.Context, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(v.Addr, "/")+"/v1/"+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	if v.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.Namespace)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Errors []string `json:"errors"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		if len(body.Errors) > 0 {
+			return fmt.Errorf("vault returned %d: %s", resp.StatusCode, strings.Join(body.Errors, ", "))
+		}
+		return fmt.Errorf("vault returned %d for %s", resp.StatusCode, path)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// stringValues turns the values of a secret into strings, encoding anything but strings as JSON
+func stringValues(values map[string]interface{}) map[string]string {
+	out := make(map[string]string, len(values))
+	for k, v := range values {
+		if s, ok := v.(string); ok {
+			out[k] = s
+			continue
+		}
+		data, _ := json.Marshal(v)
+		out[k] = string(data)
+	}
+	return out
+}
@@ -0,0 +1,128 @@
+package sourcecode
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Exclusion is a .dockerignore pattern worth adding to keep dependencies and build outputs out of the build context
+type Exclusion struct {
+	Pattern string
+	Reason  string
+}
+
+type exclusionCheck struct {
+	checks     []checkFn
+	exclusions []Exclusion
+}
+
+// exclusionChecks maps project types to the directories they generate. Dockerfiles for these projects
+// install dependencies and build inside the image, so local copies only slow down the upload.
+var exclusionChecks = []exclusionCheck{
+	{
+		checks: []checkFn{fileExists("package.json")},
+		exclusions: []Exclusion{
+			{"node_modules", "dependencies are installed during the build"},
+			{".next", "Next.js build output"},
+			{".cache", "build tool cache"},
+			{"dist", "build output"},
+			{"build", "build output"},
+			{"coverage", "test coverage reports"},
+		},
+	},
+	{
+		checks: []checkFn{fileExists("Gemfile", "config.ru")},
+		exclusions: []Exclusion{
+			{"vendor/bundle", "gems are installed during the build"},
+			{"log", "log files"},
+			{"tmp", "temporary files"},
+			{"public/packs", "compiled assets"},
+			{"public/assets", "compiled assets"},
+		},
+	},
+	{
+		checks: []checkFn{fileExists("requirements.txt", "environment.yml", "Pipfile", "pyproject.toml")},
+		exclusions: []Exclusion{
+			{".venv", "virtualenv"},
+			{"venv", "virtualenv"},
+			{"**/__pycache__", "compiled bytecode"},
+			{".pytest_cache", "test cache"},
+		},
+	},
+	{
+		checks: []checkFn{fileExists("mix.exs")},
+		exclusions: []Exclusion{
+			{"_build", "build output"},
+			{"deps", "dependencies are fetched during the build"},
+			{"assets/node_modules", "dependencies are installed during the build"},
+		},
+	},
+	{
+		checks: []checkFn{fileExists("Cargo.toml", "pom.xml", "build.gradle", "build.gradle.kts")},
+		exclusions: []Exclusion{
+			{"target", "build output"},
+			{".gradle", "gradle cache"},
+		},
+	},
+	{
+		checks: []checkFn{fileExists("go.mod")},
+		exclusions: []Exclusion{
+			{"vendor", "modules are downloaded during the build"},
+		},
+	},
+}
+
+var commonExclusions = []Exclusion{
+	{".git", "version control history"},
+	{".terraform", "terraform providers"},
+	{".DS_Store", "macOS metadata"},
+}
+
+// SuggestExclusions returns ignore patterns for directories present in sourceDir that usually don't belong in a build context
+func SuggestExclusions(sourceDir string) []Exclusion {
+	candidates := append([]Exclusion{}, commonExclusions...)
+	for _, c := range exclusionChecks {
+		if checksPass(sourceDir, c.checks...) {
+			candidates = append(candidates, c.exclusions...)
+		}
+	}
+
+	out := []Exclusion{}
+	seen := map[string]bool{}
+	for _, e := range candidates {
+		if seen[e.Pattern] || !exclusionExists(sourceDir, e.Pattern) {
+			continue
+		}
+		seen[e.Pattern] = true
+		out = append(out, e)
+	}
+
+	return out
+}
+
+var errFound = errors.New("found")
+
+func exclusionExists(sourceDir, pattern string) bool {
+	if !strings.HasPrefix(pattern, "**/") {
+		_, err := os.Stat(filepath.Join(sourceDir, filepath.FromSlash(pattern)))
+		return err == nil
+	}
+
+	// ** patterns match at any depth, so look for the name anywhere below sourceDir
+	name := strings.TrimPrefix(pattern, "**/")
+	err := filepath.Walk(sourceDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if info.Name() == name {
+			return errFound
+		}
+		if info.Name() == "node_modules" || info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return err == errFound
+}
@@ -2,17 +2,18 @@ package sourcecode
 
 import (
 	"bufio"
+	"crypto/rand"
 	"embed"
-	"io/fs"
+	"encoding/base64"
+	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
 
-	"github.com/pkg/errors"
 	"github.com/sammccord/flyctl/helpers"
 )
 
-//go:embed templates/** templates/**/.dockerignore
+//go:embed templates/** templates/**/.dockerignore scanners/*.toml
 var content embed.FS
 
 type InitCommand struct {
@@ -25,7 +26,28 @@ type Secret struct {
 	Key      string
 	Help     string
 	Generate bool
+	// Format of generated values, 64 random characters by default or base64 for 32 random bytes base64 encoded
+	Format string
+	// Prefix is prepended to generated values, like the base64: Laravel expects
+	Prefix string
+}
+
+// GenerateValue returns a random value for the secret
+func (s Secret) GenerateValue() (string, error) {
+	switch s.Format {
+	case "":
+		value, err := helpers.RandString(64)
+		return s.Prefix + value, err
+	case "base64":
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		return s.Prefix + base64.StdEncoding.EncodeToString(b), nil
+	}
+	return "", fmt.Errorf("unknown format %q for secret %s", s.Format, s.Key)
 }
+
 type SourceInfo struct {
 	Family                string
 	Version               string
@@ -64,42 +86,48 @@ type Volume struct {
 	Destination string `toml:"destination" json:"destination"`
 }
 
-func Scan(sourceDir string) (*SourceInfo, error) {
-	scanners := []sourceScanner{
-		configureRedwood,
-		/* frameworks scanners are placed before generic scanners,
-		   since they might mix languages or have a Dockerfile that
-			 doesn't work with Fly */
-		configureDockerfile,
-		configureRuby,
-		configureGo,
-		configurePhoenix,
-		configureElixir,
-		configurePython,
-		configureDeno,
-		configureRemix,
-		configureNode,
+// RepoScannersDir is where a repository keeps its own scanner manifests
+const RepoScannersDir = ".fly/scanners"
+
+// Scan detects the app in sourceDir with the first matching scanner, checking the embedded scanners,
+// those in scannerDirs and those in the repository
+func Scan(sourceDir string, scannerDirs ...string) (*SourceInfo, error) {
+	si, _, err := ScanWithTrace(sourceDir, scannerDirs...)
+	return si, err
+}
+
+// ScanWithTrace is Scan, also returning why each scanner did or didn't match
+func ScanWithTrace(sourceDir string, scannerDirs ...string) (*SourceInfo, []Trace, error) {
+	return scan(sourceDir, scannerDirs, []string{filepath.Join(sourceDir, RepoScannersDir)})
+}
+
+func scan(sourceDir string, scannerDirs, repoDirs []string) (*SourceInfo, []Trace, error) {
+	scanners, err := loadScanners(scannerDirs, repoDirs)
+	if err != nil {
+		return nil, nil, err
 	}
 
+	var si *SourceInfo
+	traces := []Trace{}
 	for _, scanner := range scanners {
-		si, err := scanner(sourceDir)
-		if err != nil {
-			return nil, err
-		}
-		if si != nil {
-			return si, nil
+		trace := scanner.Evaluate(sourceDir)
+		if trace.Matched && si == nil {
+			trace.Selected = true
+			if si, err = scanner.SourceInfo(sourceDir, &trace); err != nil {
+				return nil, nil, err
+			}
 		}
+		traces = append(traces, trace)
 	}
 
-	return nil, nil
+	return si, traces, nil
 }
 
+// SuggestAppName suggests an app name from the directory's name
 func SuggestAppName(sourceDir string) string {
-	return filepath.Base(sourceDir)
+	return sanitizeAppName(filepath.Base(sourceDir))
 }
 
-type sourceScanner func(sourceDir string) (*SourceInfo, error)
-
 func fileExists(filenames ...string) checkFn {
 	return func(dir string) bool {
 		for _, filename := range filenames {
@@ -160,298 +188,3 @@ func checksPass(sourceDir string, checks ...checkFn) bool {
 	}
 	return false
 }
-
-func configureDockerfile(sourceDir string) (*SourceInfo, error) {
-	if !checksPass(sourceDir, fileExists("Dockerfile")) {
-		return nil, nil
-	}
-
-	s := &SourceInfo{
-		DockerfilePath: filepath.Join(sourceDir, "Dockerfile"),
-		Family:         "Dockerfile",
-	}
-
-	return s, nil
-}
-
-func configureRuby(sourceDir string) (*SourceInfo, error) {
-	if !checksPass(sourceDir, fileExists("Gemfile", "config.ru")) {
-		return nil, nil
-	}
-
-	s := &SourceInfo{
-		Builder: "heroku/buildpacks:20",
-		Family:  "Ruby",
-		Port:    8080,
-		Env: map[string]string{
-			"PORT": "8080",
-		},
-	}
-
-	return s, nil
-}
-
-func configureGo(sourceDir string) (*SourceInfo, error) {
-	if !checksPass(sourceDir, fileExists("go.mod", "Gopkg.lock")) {
-		return nil, nil
-	}
-
-	s := &SourceInfo{
-		Builder:    "paketobuildpacks/builder:base",
-		Buildpacks: []string{"gcr.io/paketo-buildpacks/go"},
-		Family:     "Go",
-		Port:       8080,
-		Env: map[string]string{
-			"PORT": "8080",
-		},
-	}
-
-	return s, nil
-}
-
-func configurePython(sourceDir string) (*SourceInfo, error) {
-	if !checksPass(sourceDir, fileExists("requirements.txt", "environment.yml")) {
-		return nil, nil
-	}
-
-	s := &SourceInfo{
-		Files:   templates("templates/python"),
-		Builder: "paketobuildpacks/builder:base",
-		Family:  "Python",
-		Port:    8080,
-		Env: map[string]string{
-			"PORT": "8080",
-		},
-		SkipDeploy: true,
-		DeployDocs: `We have generated a simple Procfile for you. Modify it to fit your needs and run "fly deploy" to deploy your application.`,
-	}
-
-	return s, nil
-}
-
-func configureNode(sourceDir string) (*SourceInfo, error) {
-	if !checksPass(sourceDir, fileExists("package.json")) {
-		return nil, nil
-	}
-
-	s := &SourceInfo{
-		Builder: "heroku/buildpacks:20",
-		Family:  "NodeJS",
-		Port:    8080,
-		Env: map[string]string{
-			"PORT": "8080",
-		},
-	}
-
-	return s, nil
-}
-
-func configureDeno(sourceDir string) (*SourceInfo, error) {
-	if !checksPass(sourceDir, dirContains("*.ts", "denopkg")) {
-		return nil, nil
-	}
-
-	s := &SourceInfo{
-		Files:  templates("templates/deno"),
-		Family: "Deno",
-		Port:   8080,
-		Processes: map[string]string{
-			"app": "run --allow-net ./example.ts",
-		},
-		Env: map[string]string{
-			"PORT": "8080",
-		},
-	}
-
-	return s, nil
-}
-
-func configurePhoenix(sourceDir string) (*SourceInfo, error) {
-	// Not phoenix, move on
-	if !helpers.FileExists(filepath.Join(sourceDir, "mix.exs")) || !checksPass(sourceDir, dirContains("mix.exs", "phoenix")) {
-		return nil, nil
-	}
-
-	s := &SourceInfo{
-		Family: "Phoenix",
-		Secrets: []Secret{
-			{
-				Key:      "SECRET_KEY_BASE",
-				Help:     "Phoenix needs a random, secret key. Use the random default we've generated, or generate your own.",
-				Generate: true,
-			},
-		},
-		KillSignal: "SIGTERM",
-		Port:       8080,
-		Env: map[string]string{
-			"PORT":     "8080",
-			"PHX_HOST": "APP_FQDN",
-		},
-		DockerfileAppendix: []string{
-			"ENV ECTO_IPV6 true",
-			"ENV ERL_AFLAGS \"-proto_dist inet6_tcp\"",
-		},
-		InitCommands: []InitCommand{
-			{
-				Command:     "mix",
-				Args:        []string{"local.rebar", "--force"},
-				Description: "Preparing system for Elixir builds",
-			},
-			{
-				Command:     "mix",
-				Args:        []string{"deps.get"},
-				Description: "Installing application dependencies",
-			},
-			{
-				Command:     "mix",
-				Args:        []string{"phx.gen.release", "--docker"},
-				Description: "Running Docker release generator",
-			},
-		},
-	}
-
-	// We found Phoenix 1.6.3 or higher, so try running the Docker generator
-	if checksPass(sourceDir, dirContains("mix.exs", "phoenix.*"+regexp.QuoteMeta("1.6"))) {
-		s.DeployDocs = `
-Your Phoenix app should be ready for deployment!.
-
-If you need something else, post on our community forum at https://community.fly.io.
-
-When you're ready to deploy, use 'fly deploy --remote-only'.
-`
-	}
-	// We found Phoenix 1.6.0 - 1.6.2
-	if checksPass(sourceDir, dirContains("mix.exs", "phoenix.*"+regexp.QuoteMeta("1.6.")+"[0-2]")) {
-		s.SkipDeploy = true
-		s.DeployDocs = `
-We recommend upgrading to Phoenix 1.6.3 which includes a release configuration for Docker-based deployment.
-
-If you do upgrade, you can run 'fly launch' again to get the required deployment setup.
-
-If you don't want to uprade, you'll need to add a few files and configuration options manually.
-W've placed Dockerfile compatible with other Phoenix 1.6 apps in this directory. See
-https://hexdocs.pm/phoenix/fly.html for details, including instructions for setting up
-a Postgresql database.
-`
-	}
-
-	// Add migration task if we find ecto
-	if checksPass(sourceDir, dirContains("mix.exs", "ecto")) {
-		s.ReleaseCmd = "/app/bin/migrate"
-	}
-
-	// Ask to create a postgres database if we find the postgres adapter
-	if checksPass(sourceDir, dirContains("mix.lock", "postgrex")) {
-		s.CreatePostgresCluster = true
-	}
-	return s, nil
-}
-
-func configureElixir(sourceDir string) (*SourceInfo, error) {
-	if !helpers.FileExists(filepath.Join(sourceDir, "mix.exs")) {
-		return nil, nil
-	}
-
-	s := &SourceInfo{
-		Builder:    "heroku/buildpacks:20",
-		Buildpacks: []string{"https://cnb-shim.herokuapp.com/v1/hashnuke/elixir"},
-		Family:     "Elixir",
-		Port:       8080,
-		Env: map[string]string{
-			"PORT": "8080",
-		},
-	}
-
-	return s, nil
-}
-
-func configureRedwood(sourceDir string) (*SourceInfo, error) {
-	if !checksPass(sourceDir, fileExists("redwood.toml")) {
-		return nil, nil
-	}
-
-	s := &SourceInfo{
-		Family: "RedwoodJS",
-		Files:  templates("templates/redwood"),
-		Port:   8911,
-		Env: map[string]string{
-			"PORT": "8911",
-		},
-		Statics: []Static{
-			{
-				GuestPath: "/app/public",
-				UrlPrefix: "/",
-			},
-		},
-	}
-
-	return s, nil
-}
-
-func configureRemix(sourceDir string) (*SourceInfo, error) {
-	if !checksPass(sourceDir, fileExists("remix.config.js")) {
-		return nil, nil
-	}
-
-	env := map[string]string{
-		"PORT": "8080",
-	}
-
-	s := &SourceInfo{
-		Family: "Remix",
-		Port:   8080,
-	}
-
-	if checksPass(sourceDir+"/prisma", dirContains("*.prisma", "sqlite")) {
-		env["DATABASE_URL"] = "file:/data/sqlite.db"
-		s.Files = templates("templates/remix_prisma")
-		s.DockerCommand = "start_with_migrations.sh"
-		s.DockerEntrypoint = "sh"
-		s.Volumes = []Volume{
-			{
-				Source:      "data",
-				Destination: "/data",
-			},
-		}
-		s.Notice = "\nThis launch configuration uses SQLite on a single, dedicated volume. It will not scale beyond a single VM. Look into 'fly postgres' for a more robust production database. \n"
-	} else {
-		s.Files = templates("templates/remix")
-	}
-
-	s.Env = env
-	return s, nil
-}
-
-// templates recursively returns files from the templates directory within the named directory
-// will panic on errors since these files are embedded and should work
-func templates(name string) (files []SourceFile) {
-	err := fs.WalkDir(content, name, func(path string, d fs.DirEntry, e error) error {
-		if d.IsDir() {
-			return nil
-		}
-
-		relPath, err := filepath.Rel(name, path)
-		if err != nil {
-			return errors.Wrap(err, "error removing template prefix")
-		}
-
-		data, err := fs.ReadFile(content, path)
-		if err != nil {
-			return err
-		}
-
-		f := SourceFile{
-			Path:     relPath,
-			Contents: data,
-		}
-
-		files = append(files, f)
-		return nil
-	})
-
-	if err != nil {
-		panic(err)
-	}
-
-	return
-}
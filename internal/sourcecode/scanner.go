@@ -0,0 +1,428 @@
+package sourcecode
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+)
+
+// customPriority is the priority of scanners that don't set one and don't replace an embedded scanner, so
+// custom frameworks are recognized before the generic language scanners
+const customPriority = 100
+
+// EmbeddedSource is the Source of the scanners shipped with flyctl
+const EmbeddedSource = "embedded"
+
+// Rule is a single detection check. All of a scanner's rules must pass for it to match, the alternatives
+// within a rule, like several file names, pass if any of them does.
+type Rule struct {
+	// FileExists passes if any of the files exist in the source directory
+	FileExists []string `toml:"file_exists"`
+	// DirExists passes if any of the directories exist in the source directory
+	DirExists []string `toml:"dir_exists"`
+	// DirContains is a glob of files, relative to the source directory, any of which must match one of Patterns
+	DirContains string   `toml:"dir_contains"`
+	Patterns    []string `toml:"patterns"`
+	// Not inverts the rule
+	Not bool `toml:"not"`
+}
+
+// Profile is what a scanner contributes to the SourceInfo when it matches
+type Profile struct {
+	Family                string            `toml:"family"`
+	Version               string            `toml:"version"`
+	Dockerfile            string            `toml:"dockerfile"`
+	Builder               string            `toml:"builder"`
+	Buildpacks            []string          `toml:"buildpacks"`
+	ReleaseCmd            string            `toml:"release_cmd"`
+	DockerCommand         string            `toml:"docker_command"`
+	DockerEntrypoint      string            `toml:"docker_entrypoint"`
+	KillSignal            string            `toml:"kill_signal"`
+	Port                  int               `toml:"port"`
+	Env                   map[string]string `toml:"env"`
+	Statics               []Static          `toml:"statics"`
+	Processes             map[string]string `toml:"processes"`
+	DeployDocs            string            `toml:"deploy_docs"`
+	Notice                string            `toml:"notice"`
+	SkipDeploy            bool              `toml:"skip_deploy"`
+	Volumes               []Volume          `toml:"volumes"`
+	DockerfileAppendix    []string          `toml:"dockerfile_appendix"`
+	InitCommands          []InitCommand     `toml:"init_commands"`
+	Secrets               []Secret          `toml:"secrets"`
+	CreatePostgresCluster bool              `toml:"create_postgres_cluster"`
+	// Templates is a directory of files copied into the source directory, relative to the manifest's directory
+	Templates string `toml:"templates"`
+}
+
+// Variant adjusts the profile of a matching scanner when its rules also pass
+type Variant struct {
+	Rules []Rule `toml:"rules"`
+	Profile
+}
+
+// Manifest is a declarative scanner
+type Manifest struct {
+	Name string `toml:"name"`
+	// Priority orders scanners, the highest is checked first
+	Priority int    `toml:"priority"`
+	Rules    []Rule `toml:"rules"`
+	// When lists variants applied in order on top of the profile
+	When []Variant `toml:"when"`
+	Profile
+
+	// Source is where the manifest was loaded from
+	Source string `toml:"-"`
+
+	fsys            fs.FS
+	dir             string
+	priorityDefined bool
+}
+
+// Trace records why a scanner did or didn't match
+type Trace struct {
+	Scanner  string
+	Source   string
+	Priority int
+	Matched  bool
+	// Selected is set on the first matching scanner, the one used
+	Selected bool
+	Reasons  []string
+}
+
+// LoadScanners returns the embedded scanners, replaced or extended by the manifests in dirs, in order.
+// Directories that don't exist are skipped.
+func LoadScanners(dirs ...string) ([]*Manifest, error) {
+	return loadScanners(dirs, nil)
+}
+
+// loadScanners is LoadScanners, followed by the manifests in repoDirs. Those come with the source code being
+// scanned, so they can't run commands.
+func loadScanners(dirs, repoDirs []string) ([]*Manifest, error) {
+	manifests, err := loadManifests(content, "scanners", EmbeddedSource)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, dir := range append(append([]string{}, dirs...), repoDirs...) {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+
+		custom, err := loadManifests(os.DirFS(dir), ".", dir)
+		if err != nil {
+			return nil, err
+		}
+		if i >= len(dirs) {
+			for _, m := range custom {
+				if m.runsCommands() {
+					return nil, fmt.Errorf("scanner %s in %s can't set init_commands, only scanners in ~/.fly/scanners can run commands", m.Name, dir)
+				}
+			}
+		}
+
+	next:
+		for _, m := range custom {
+			for i, existing := range manifests {
+				if existing.Name == m.Name {
+					if !m.priorityDefined {
+						m.Priority = existing.Priority
+					}
+					manifests[i] = m
+					continue next
+				}
+			}
+			if !m.priorityDefined {
+				m.Priority = customPriority
+			}
+			manifests = append(manifests, m)
+		}
+	}
+
+	sort.SliceStable(manifests, func(i, j int) bool {
+		return manifests[i].Priority > manifests[j].Priority
+	})
+
+	return manifests, nil
+}
+
+func loadManifests(fsys fs.FS, dir string, source string) ([]*Manifest, error) {
+	names, err := fs.Glob(fsys, path.Join(dir, "*.toml"))
+	if err != nil {
+		return nil, err
+	}
+
+	manifests := []*Manifest{}
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		m := &Manifest{fsys: fsys, dir: dir, Source: source}
+		md, err := toml.Decode(string(data), m)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error parsing scanner %s", path.Join(source, path.Base(name)))
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("error parsing scanner %s: unknown key %s", path.Join(source, path.Base(name)), undecoded[0])
+		}
+
+		if m.Name == "" {
+			m.Name = strings.TrimSuffix(path.Base(name), ".toml")
+		}
+		if len(m.Rules) == 0 {
+			return nil, fmt.Errorf("scanner %s has no rules", m.Name)
+		}
+		m.priorityDefined = md.IsDefined("priority")
+
+		manifests = append(manifests, m)
+	}
+
+	return manifests, nil
+}
+
+// runsCommands reports whether the scanner, or any of its variants, sets init_commands
+func (m *Manifest) runsCommands() bool {
+	if len(m.InitCommands) > 0 {
+		return true
+	}
+	for _, v := range m.When {
+		if len(v.InitCommands) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate checks the scanner's rules against sourceDir, stopping at the first that fails
+func (m *Manifest) Evaluate(sourceDir string) Trace {
+	trace := Trace{Scanner: m.Name, Source: m.Source, Priority: m.Priority}
+	trace.Matched, trace.Reasons = evaluateRules(sourceDir, m.Rules)
+	return trace
+}
+
+// SourceInfo builds the SourceInfo of a matching scanner, applying the variants whose rules pass
+func (m *Manifest) SourceInfo(sourceDir string, trace *Trace) (*SourceInfo, error) {
+	profile := m.Profile
+	for i, variant := range m.When {
+		passed, reasons := evaluateRules(sourceDir, variant.Rules)
+		if trace != nil {
+			for _, reason := range reasons {
+				trace.Reasons = append(trace.Reasons, fmt.Sprintf("when #%d: %s", i+1, reason))
+			}
+		}
+		if passed {
+			profile = profile.merge(variant.Profile)
+		}
+	}
+
+	si := &SourceInfo{
+		Family:                profile.Family,
+		Version:               profile.Version,
+		Builder:               profile.Builder,
+		Buildpacks:            profile.Buildpacks,
+		ReleaseCmd:            profile.ReleaseCmd,
+		DockerCommand:         profile.DockerCommand,
+		DockerEntrypoint:      profile.DockerEntrypoint,
+		KillSignal:            profile.KillSignal,
+		Port:                  profile.Port,
+		Env:                   profile.Env,
+		Statics:               profile.Statics,
+		Processes:             profile.Processes,
+		DeployDocs:            profile.DeployDocs,
+		Notice:                profile.Notice,
+		SkipDeploy:            profile.SkipDeploy,
+		Volumes:               profile.Volumes,
+		DockerfileAppendix:    profile.DockerfileAppendix,
+		InitCommands:          profile.InitCommands,
+		Secrets:               profile.Secrets,
+		CreatePostgresCluster: profile.CreatePostgresCluster,
+	}
+	if si.Family == "" {
+		si.Family = m.Name
+	}
+	if profile.Dockerfile != "" {
+		si.DockerfilePath = filepath.Join(sourceDir, profile.Dockerfile)
+	}
+	if profile.Templates != "" {
+		files, err := readTemplates(m.fsys, path.Join(m.dir, profile.Templates))
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading templates of scanner %s", m.Name)
+		}
+		si.Files = files
+	}
+
+	return si, nil
+}
+
+// merge returns p with the fields set in v replacing its own, env vars and processes are merged
+func (p Profile) merge(v Profile) Profile {
+	merged := p
+	setString := func(dst *string, src string) {
+		if src != "" {
+			*dst = src
+		}
+	}
+	setString(&merged.Family, v.Family)
+	setString(&merged.Version, v.Version)
+	setString(&merged.Dockerfile, v.Dockerfile)
+	setString(&merged.Builder, v.Builder)
+	setString(&merged.ReleaseCmd, v.ReleaseCmd)
+	setString(&merged.DockerCommand, v.DockerCommand)
+	setString(&merged.DockerEntrypoint, v.DockerEntrypoint)
+	setString(&merged.KillSignal, v.KillSignal)
+	setString(&merged.DeployDocs, v.DeployDocs)
+	setString(&merged.Notice, v.Notice)
+	setString(&merged.Templates, v.Templates)
+
+	if v.Port != 0 {
+		merged.Port = v.Port
+	}
+	if v.SkipDeploy {
+		merged.SkipDeploy = true
+	}
+	if v.CreatePostgresCluster {
+		merged.CreatePostgresCluster = true
+	}
+
+	if v.Buildpacks != nil {
+		merged.Buildpacks = v.Buildpacks
+	}
+	if v.Statics != nil {
+		merged.Statics = v.Statics
+	}
+	if v.Volumes != nil {
+		merged.Volumes = v.Volumes
+	}
+	if v.DockerfileAppendix != nil {
+		merged.DockerfileAppendix = v.DockerfileAppendix
+	}
+	if v.InitCommands != nil {
+		merged.InitCommands = v.InitCommands
+	}
+	if v.Secrets != nil {
+		merged.Secrets = v.Secrets
+	}
+
+	merged.Env = mergeMaps(p.Env, v.Env)
+	merged.Processes = mergeMaps(p.Processes, v.Processes)
+
+	return merged
+}
+
+func mergeMaps(a, b map[string]string) map[string]string {
+	if len(b) == 0 {
+		return a
+	}
+	merged := map[string]string{}
+	for k, v := range a {
+		merged[k] = v
+	}
+	for k, v := range b {
+		merged[k] = v
+	}
+	return merged
+}
+
+func evaluateRules(sourceDir string, rules []Rule) (bool, []string) {
+	reasons := []string{}
+	for _, rule := range rules {
+		passed, reason := rule.evaluate(sourceDir)
+		if rule.Not {
+			passed = !passed
+			reason = "not: " + reason
+		}
+		reasons = append(reasons, reason)
+		if !passed {
+			return false, reasons
+		}
+	}
+	return true, reasons
+}
+
+// evaluate checks the rule, describing what it found
+func (r Rule) evaluate(sourceDir string) (bool, string) {
+	switch {
+	case len(r.FileExists) > 0:
+		for _, name := range r.FileExists {
+			if fileExists(name)(sourceDir) {
+				return true, fmt.Sprintf("found %s", name)
+			}
+		}
+		return false, fmt.Sprintf("none of %s exist", strings.Join(r.FileExists, ", "))
+
+	case len(r.DirExists) > 0:
+		for _, name := range r.DirExists {
+			if info, err := os.Stat(filepath.Join(sourceDir, name)); err == nil && info.IsDir() {
+				return true, fmt.Sprintf("found directory %s", name)
+			}
+		}
+		return false, fmt.Sprintf("none of the directories %s exist", strings.Join(r.DirExists, ", "))
+
+	case r.DirContains != "":
+		filenames, _ := filepath.Glob(filepath.Join(sourceDir, r.DirContains))
+		for _, pattern := range r.Patterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return false, fmt.Sprintf("invalid pattern %q: %s", pattern, err)
+			}
+			for _, filename := range filenames {
+				if fileContains(filename, pattern) {
+					rel, _ := filepath.Rel(sourceDir, filename)
+					return true, fmt.Sprintf("%s matches %q", rel, pattern)
+				}
+			}
+		}
+		if len(filenames) == 0 {
+			return false, fmt.Sprintf("no files match %s", r.DirContains)
+		}
+		return false, fmt.Sprintf("no %s file matches %s", r.DirContains, strings.Join(quoteAll(r.Patterns), " or "))
+	}
+
+	return false, "empty rule"
+}
+
+func quoteAll(values []string) []string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	return quoted
+}
+
+// readTemplates recursively returns the files in the named directory
+func readTemplates(fsys fs.FS, name string) (files []SourceFile, err error) {
+	err = fs.WalkDir(fsys, name, func(p string, d fs.DirEntry, e error) error {
+		if e != nil {
+			return e
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(name, p)
+		if err != nil {
+			return errors.Wrap(err, "error removing template prefix")
+		}
+
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, SourceFile{
+			Path:     relPath,
+			Contents: data,
+		})
+		return nil
+	})
+
+	return
+}
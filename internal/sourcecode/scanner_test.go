@@ -0,0 +1,234 @@
+package sourcecode
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+func TestEmbeddedScanners(t *testing.T) {
+	scanners, err := LoadScanners()
+	require.NoError(t, err)
+	require.NotEmpty(t, scanners)
+	assert.Equal(t, "redwood", scanners[0].Name)
+	assert.Equal(t, "node", scanners[len(scanners)-1].Name)
+}
+
+func TestScanPhoenix(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"mix.exs":  `{:phoenix, "~> 1.6.2"}, {:ecto_sql, "~> 3.6"}`,
+		"mix.lock": `"postgrex": {:hex, :postgrex, "0.15.13"}`,
+	})
+
+	si, err := Scan(dir)
+	require.NoError(t, err)
+	require.NotNil(t, si)
+	assert.Equal(t, "Phoenix", si.Family)
+	assert.Equal(t, "/app/bin/migrate", si.ReleaseCmd)
+	assert.True(t, si.SkipDeploy)
+	assert.True(t, si.CreatePostgresCluster)
+	assert.Equal(t, "APP_FQDN", si.Env["PHX_HOST"])
+	require.Len(t, si.Secrets, 1)
+	assert.Equal(t, "SECRET_KEY_BASE", si.Secrets[0].Key)
+	assert.True(t, si.Secrets[0].Generate)
+	assert.Len(t, si.InitCommands, 3)
+}
+
+func TestScanRemix(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"remix.config.js": "module.exports = {}",
+		"package.json":    "{}",
+	})
+
+	si, err := Scan(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "Remix", si.Family)
+	assert.Empty(t, si.Volumes)
+	assert.NotEmpty(t, si.Files)
+
+	writeFiles(t, dir, map[string]string{"prisma/schema.prisma": `provider = "sqlite"`})
+	si, err = Scan(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "file:/data/sqlite.db", si.Env["DATABASE_URL"])
+	assert.Equal(t, "8080", si.Env["PORT"])
+	assert.Equal(t, "start_with_migrations.sh", si.DockerCommand)
+	require.Len(t, si.Volumes, 1)
+	assert.Equal(t, "/data", si.Volumes[0].Destination)
+}
+
+func TestScanCustomScanners(t *testing.T) {
+	userDir := t.TempDir()
+	writeFiles(t, userDir, map[string]string{
+		"acme.toml": `
+family = "Acme Service"
+port = 4000
+release_cmd = "bin/acme migrate"
+templates = "acme"
+
+[[rules]]
+file_exists = ["package.json"]
+
+[[rules]]
+dir_contains = "package.json"
+patterns = ['"@acme/service"']
+`,
+		"acme/Dockerfile": "FROM registry.acme.internal/node:16",
+	})
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"package.json": `{"dependencies": {"@acme/service": "^2.0.0"}}`})
+
+	si, traces, err := ScanWithTrace(dir, userDir)
+	require.NoError(t, err)
+	assert.Equal(t, "Acme Service", si.Family)
+	assert.Equal(t, "bin/acme migrate", si.ReleaseCmd)
+	require.Len(t, si.Files, 1)
+	assert.Equal(t, "Dockerfile", si.Files[0].Path)
+
+	assert.Equal(t, "acme", traces[0].Scanner)
+	assert.True(t, traces[0].Selected)
+	assert.Equal(t, []string{"found package.json", `package.json matches "\"@acme/service\""`}, traces[0].Reasons)
+
+	for _, trace := range traces {
+		if trace.Scanner == "node" {
+			assert.True(t, trace.Matched)
+			assert.False(t, trace.Selected)
+		}
+		if trace.Scanner == "ruby" {
+			assert.False(t, trace.Matched)
+			assert.Equal(t, []string{"none of Gemfile, config.ru exist"}, trace.Reasons)
+		}
+	}
+
+	// the repository's scanners replace embedded ones with the same name
+	writeFiles(t, dir, map[string]string{
+		".fly/scanners/acme.toml": "family = \"Acme Legacy\"\n[[rules]]\nfile_exists = [\"package.json\"]\n",
+	})
+	si, err = Scan(dir, userDir)
+	require.NoError(t, err)
+	assert.Equal(t, "Acme Legacy", si.Family)
+
+	// but can't run commands, unlike the user's own
+	writeFiles(t, dir, map[string]string{
+		".fly/scanners/acme.toml": "[[rules]]\nfile_exists = [\"package.json\"]\n[[when]]\n[[when.rules]]\nfile_exists = [\"package.json\"]\n[[when.init_commands]]\ncommand = \"sh\"\n",
+	})
+	_, err = Scan(dir, userDir)
+	assert.Error(t, err)
+	_, err = Scan(t.TempDir(), filepath.Join(dir, ".fly/scanners"))
+	assert.NoError(t, err)
+}
+
+func TestLoadScannersErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"norules.toml": `family = "Nothing"`})
+	_, err := LoadScanners(dir)
+	assert.Error(t, err)
+
+	dir = t.TempDir()
+	writeFiles(t, dir, map[string]string{"typo.toml": "family = \"X\"\n[[rule]]\nfile_exists = [\"x\"]\n"})
+	_, err = LoadScanners(dir)
+	assert.Error(t, err)
+}
+
+func TestScanFrameworks(t *testing.T) {
+	cases := []struct {
+		files      map[string]string
+		family     string
+		releaseCmd string
+		secrets    []string
+		postgres   bool
+	}{
+		{
+			files: map[string]string{
+				"Gemfile":      "source 'https://rubygems.org'\ngem 'rails', '~> 7.0'\ngem \"pg\"\n",
+				"Gemfile.lock": "",
+			},
+			family:     "Rails",
+			releaseCmd: "bin/rails db:migrate",
+			secrets:    []string{"SECRET_KEY_BASE"},
+			postgres:   true,
+		},
+		{
+			files: map[string]string{
+				"manage.py":        "os.environ.setdefault('DJANGO_SETTINGS_MODULE', 'mysite.settings')",
+				"requirements.txt": "Django==4.0.4\ngunicorn\n",
+			},
+			family:     "Django",
+			releaseCmd: "python manage.py migrate --noinput",
+			secrets:    []string{"SECRET_KEY"},
+		},
+		{
+			files: map[string]string{
+				"artisan":       "#!/usr/bin/env php",
+				"composer.json": `{"require": {"php": "^8.0", "laravel/framework": "^9.2"}}`,
+			},
+			family:     "Laravel",
+			releaseCmd: "php artisan migrate --force",
+			secrets:    []string{"APP_KEY"},
+		},
+		{
+			files:  map[string]string{"Cargo.toml": "[workspace]\nmembers = [\"api\", \"worker\"]\n"},
+			family: "Rust",
+		},
+		{
+			files:  map[string]string{"pom.xml": "<artifactId>spring-boot-starter-parent</artifactId>"},
+			family: "Spring Boot",
+		},
+		{
+			files:  map[string]string{"build.gradle.kts": `id("org.springframework.boot") version "2.7.0"`},
+			family: "Spring Boot",
+		},
+	}
+
+	for _, c := range cases {
+		dir := t.TempDir()
+		writeFiles(t, dir, c.files)
+
+		si, err := Scan(dir)
+		require.NoError(t, err)
+		require.NotNil(t, si, c.family)
+		assert.Equal(t, c.family, si.Family)
+		assert.Equal(t, c.releaseCmd, si.ReleaseCmd, c.family)
+		assert.Equal(t, c.postgres, si.CreatePostgresCluster, c.family)
+		assert.Equal(t, 8080, si.Port, c.family)
+
+		keys := []string{}
+		for _, secret := range si.Secrets {
+			keys = append(keys, secret.Key)
+		}
+		assert.ElementsMatch(t, c.secrets, keys, c.family)
+
+		paths := []string{}
+		for _, f := range si.Files {
+			paths = append(paths, f.Path)
+		}
+		assert.Contains(t, paths, "Dockerfile", c.family)
+		assert.Contains(t, paths, ".dockerignore", c.family)
+	}
+}
+
+func TestSecretGenerateValue(t *testing.T) {
+	value, err := Secret{Key: "SECRET_KEY_BASE", Generate: true}.GenerateValue()
+	require.NoError(t, err)
+	assert.Len(t, value, 64)
+
+	value, err = Secret{Key: "APP_KEY", Generate: true, Format: "base64", Prefix: "base64:"}.GenerateValue()
+	require.NoError(t, err)
+	assert.Regexp(t, `^base64:[A-Za-z0-9+/]{43}=$`, value)
+
+	_, err = Secret{Key: "X", Format: "hex"}.GenerateValue()
+	assert.Error(t, err)
+}
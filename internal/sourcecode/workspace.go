@@ -0,0 +1,340 @@
+package sourcecode
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/sammccord/flyctl/helpers"
+	"gopkg.in/yaml.v2"
+)
+
+// Kinds of workspaces apps are found in
+const (
+	WorkspaceNpm        = "npm"
+	WorkspaceYarn       = "yarn"
+	WorkspacePnpm       = "pnpm"
+	WorkspaceGo         = "go"
+	WorkspaceCargo      = "cargo"
+	WorkspaceDockerfile = "dockerfile"
+)
+
+// maxWorkspaceDepth limits how deep nested go modules and Dockerfiles are looked for
+const maxWorkspaceDepth = 3
+
+// WorkspaceApp is a directory of a monorepo that could be launched as its own app
+type WorkspaceApp struct {
+	// Dir is the slash separated path relative to the repository root
+	Dir  string
+	Name string
+	// Kinds lists the workspaces the directory was found in
+	Kinds []string
+	// Dockerfile is set when the directory has its own Dockerfile
+	Dockerfile bool
+	// Launchable is set for directories that look like apps rather than libraries: they have a Dockerfile,
+	// a start script, a main package or a binary crate
+	Launchable bool
+}
+
+// BuildContext is the repository root relative to the app's directory, the build context of apps launched from a
+// monorepo so their Dockerfile can copy the workspace packages they share with other apps
+func (a WorkspaceApp) BuildContext() string {
+	rel, err := filepath.Rel(filepath.FromSlash(a.Dir), ".")
+	if err != nil {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// DetectWorkspace finds the apps of a monorepo rooted at root from npm, yarn and pnpm workspaces, go.work or
+// nested go modules, Cargo workspaces and directories with Dockerfiles. It returns nothing unless there are
+// at least two.
+func DetectWorkspace(root string) []WorkspaceApp {
+	found := map[string]*WorkspaceApp{}
+	add := func(kind string, dirs ...string) {
+	next:
+		for _, dir := range dirs {
+			dir = path.Clean(filepath.ToSlash(dir))
+			if dir == "." || strings.HasPrefix(dir, "../") {
+				continue
+			}
+			if info, err := os.Stat(filepath.Join(root, dir)); err != nil || !info.IsDir() {
+				continue
+			}
+			app, ok := found[dir]
+			if !ok {
+				app = &WorkspaceApp{Dir: dir}
+				found[dir] = app
+			}
+			for _, k := range app.Kinds {
+				if k == kind {
+					continue next
+				}
+			}
+			app.Kinds = append(app.Kinds, kind)
+		}
+	}
+
+	if patterns := npmWorkspaces(root); len(patterns) > 0 {
+		kind := WorkspaceNpm
+		if helpers.FileExists(filepath.Join(root, "yarn.lock")) {
+			kind = WorkspaceYarn
+		}
+		add(kind, expandWorkspaceGlobs(root, patterns)...)
+	}
+	if patterns := pnpmWorkspaces(root); len(patterns) > 0 {
+		add(WorkspacePnpm, expandWorkspaceGlobs(root, patterns)...)
+	}
+	add(WorkspaceGo, goModules(root)...)
+	if patterns := cargoMembers(root); len(patterns) > 0 {
+		add(WorkspaceCargo, expandWorkspaceGlobs(root, patterns)...)
+	}
+	add(WorkspaceDockerfile, findNested(root, "Dockerfile")...)
+
+	if len(found) < 2 {
+		return nil
+	}
+
+	repoName := SuggestAppName(root)
+	apps := []WorkspaceApp{}
+	for _, app := range found {
+		dir := filepath.Join(root, filepath.FromSlash(app.Dir))
+		app.Dockerfile = helpers.FileExists(filepath.Join(dir, "Dockerfile"))
+		app.Launchable = app.Dockerfile || isLaunchable(dir)
+
+		name := packageName(dir)
+		if name == "" {
+			name = path.Base(app.Dir)
+		}
+		app.Name = sanitizeAppName(repoName + "-" + name)
+
+		apps = append(apps, *app)
+	}
+	sort.Slice(apps, func(i, j int) bool { return apps[i].Dir < apps[j].Dir })
+
+	return apps
+}
+
+var invalidAppNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// sanitizeAppName lowercases name and replaces anything but letters, digits and dashes with dashes
+func sanitizeAppName(name string) string {
+	name = invalidAppNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	return strings.Trim(name, "-")
+}
+
+func readPackageJSON(dir string) map[string]interface{} {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return nil
+	}
+	pkg := map[string]interface{}{}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil
+	}
+	return pkg
+}
+
+// npmWorkspaces returns the workspaces globs of package.json, as a list or under packages like yarn allows
+func npmWorkspaces(root string) []string {
+	pkg := readPackageJSON(root)
+	var workspaces interface{} = pkg["workspaces"]
+	if m, ok := workspaces.(map[string]interface{}); ok {
+		workspaces = m["packages"]
+	}
+	return stringList(workspaces)
+}
+
+func pnpmWorkspaces(root string) []string {
+	data, err := os.ReadFile(filepath.Join(root, "pnpm-workspace.yaml"))
+	if err != nil {
+		return nil
+	}
+	var ws struct {
+		Packages []string `yaml:"packages"`
+	}
+	if err := yaml.Unmarshal(data, &ws); err != nil {
+		return nil
+	}
+	return ws.Packages
+}
+
+func cargoMembers(root string) []string {
+	var manifest struct {
+		Workspace struct {
+			Members []string `toml:"members"`
+		} `toml:"workspace"`
+	}
+	if _, err := toml.DecodeFile(filepath.Join(root, "Cargo.toml"), &manifest); err != nil {
+		return nil
+	}
+	return manifest.Workspace.Members
+}
+
+var goWorkUse = regexp.MustCompile(`^\s*(?:use\s+)?(?:\(\s*)?([^\s()]+)\s*\)?\s*$`)
+
+// goModules returns the modules used in go.work, or the nested modules when there isn't one
+func goModules(root string) []string {
+	f, err := os.Open(filepath.Join(root, "go.work"))
+	if err != nil {
+		return findNested(root, "go.mod")
+	}
+	defer f.Close()
+
+	dirs := []string{}
+	inUse := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.SplitN(scanner.Text(), "//", 2)[0])
+		switch {
+		case strings.HasPrefix(line, "use") && strings.HasSuffix(line, "("):
+			inUse = true
+		case inUse && line == ")":
+			inUse = false
+		case inUse || strings.HasPrefix(line, "use "):
+			if m := goWorkUse.FindStringSubmatch(line); m != nil {
+				dirs = append(dirs, m[1])
+			}
+		}
+	}
+	return dirs
+}
+
+var skippedWorkspaceDirs = map[string]bool{
+	".git": true, "node_modules": true, "vendor": true, "target": true, "dist": true, "build": true,
+}
+
+// findNested returns the directories below root, but not root itself, that contain filename
+func findNested(root, filename string) []string {
+	dirs := []string{}
+	filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, p)
+		if info.IsDir() {
+			if rel != "." && (skippedWorkspaceDirs[info.Name()] || strings.HasPrefix(info.Name(), ".") ||
+				strings.Count(filepath.ToSlash(rel), "/") >= maxWorkspaceDepth) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Name() == filename && filepath.Dir(rel) != "." {
+			dirs = append(dirs, filepath.Dir(rel))
+		}
+		return nil
+	})
+	return dirs
+}
+
+// expandWorkspaceGlobs resolves workspace patterns like packages/* to directories, ignoring ! exclusions
+// and treating ** like *
+func expandWorkspaceGlobs(root string, patterns []string) []string {
+	excluded := map[string]bool{}
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "!") {
+			for _, dir := range globDirs(root, strings.TrimPrefix(pattern, "!")) {
+				excluded[dir] = true
+			}
+		}
+	}
+
+	dirs := []string{}
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "!") {
+			continue
+		}
+		for _, dir := range globDirs(root, pattern) {
+			if !excluded[dir] {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	return dirs
+}
+
+func globDirs(root, pattern string) []string {
+	pattern = strings.ReplaceAll(strings.TrimPrefix(pattern, "./"), "**", "*")
+	matches, _ := filepath.Glob(filepath.Join(root, filepath.FromSlash(pattern)))
+
+	dirs := []string{}
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && info.IsDir() {
+			rel, _ := filepath.Rel(root, match)
+			dirs = append(dirs, filepath.ToSlash(rel))
+		}
+	}
+	return dirs
+}
+
+func stringList(v interface{}) []string {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	strs := []string{}
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
+
+var goModule = regexp.MustCompile(`(?m)^module\s+(\S+)`)
+
+// packageName returns the name from package.json without its scope, Cargo.toml or the last element of the
+// go module path
+func packageName(dir string) string {
+	if name, ok := readPackageJSON(dir)["name"].(string); ok && name != "" {
+		return name[strings.LastIndex(name, "/")+1:]
+	}
+
+	var cargo struct {
+		Package struct {
+			Name string `toml:"name"`
+		} `toml:"package"`
+	}
+	if _, err := toml.DecodeFile(filepath.Join(dir, "Cargo.toml"), &cargo); err == nil && cargo.Package.Name != "" {
+		return cargo.Package.Name
+	}
+
+	if m := goModule.FindStringSubmatch(readString(filepath.Join(dir, "go.mod"))); m != nil {
+		return path.Base(m[1])
+	}
+
+	return ""
+}
+
+// isLaunchable guesses whether dir is an app: a package with a start script, a go main package or a binary crate
+func isLaunchable(dir string) bool {
+	if scripts, ok := readPackageJSON(dir)["scripts"].(map[string]interface{}); ok && scripts["start"] != nil {
+		return true
+	}
+
+	if helpers.FileExists(filepath.Join(dir, "go.mod")) {
+		if dirContains("*.go", `^package main\b`)(dir) || dirContains("cmd/*/*.go", `^package main\b`)(dir) {
+			return true
+		}
+	}
+
+	if helpers.FileExists(filepath.Join(dir, "src", "main.rs")) {
+		return true
+	}
+	if info, err := os.Stat(filepath.Join(dir, "src", "bin")); err == nil && info.IsDir() {
+		return true
+	}
+
+	return false
+}
+
+func readString(path string) string {
+	data, _ := os.ReadFile(path)
+	return string(data)
+}
@@ -0,0 +1,74 @@
+package sourcecode
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectWorkspace(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "Acme_Platform")
+	writeFiles(t, root, map[string]string{
+		"package.json":              `{"private": true, "workspaces": {"packages": ["apps/*", "packages/*", "!packages/legacy"]}}`,
+		"yarn.lock":                 "",
+		"apps/web/package.json":     `{"name": "@acme/web", "scripts": {"start": "next start"}}`,
+		"packages/ui/package.json":  `{"name": "@acme/ui"}`,
+		"packages/legacy/README.md": "",
+		"go.work":                   "go 1.18\n\nuse (\n\t./services/billing // payments\n\t./libs/auth\n)\n",
+		"services/billing/go.mod":   "module github.com/acme/platform/services/billing\n",
+		"services/billing/main.go":  "package main\n",
+		"libs/auth/go.mod":          "module github.com/acme/platform/libs/auth\n",
+		"libs/auth/auth.go":         "package auth\n",
+		"Cargo.toml":                "[workspace]\nmembers = [\"crates/*\"]\n",
+		"crates/worker/Cargo.toml":  "[package]\nname = \"acme-worker\"\n",
+		"crates/worker/src/main.rs": "fn main() {}",
+		"tools/proxy/Dockerfile":    "FROM nginx",
+		"node_modules/x/Dockerfile": "FROM scratch",
+	})
+
+	apps := DetectWorkspace(root)
+	require.Len(t, apps, 6)
+
+	byDir := map[string]WorkspaceApp{}
+	for _, app := range apps {
+		byDir[app.Dir] = app
+	}
+
+	assert.Equal(t, []string{WorkspaceYarn}, byDir["apps/web"].Kinds)
+	assert.Equal(t, "acme-platform-web", byDir["apps/web"].Name)
+	assert.True(t, byDir["apps/web"].Launchable)
+
+	assert.False(t, byDir["packages/ui"].Launchable)
+	assert.NotContains(t, byDir, "packages/legacy")
+
+	assert.Equal(t, []string{WorkspaceGo}, byDir["services/billing"].Kinds)
+	assert.Equal(t, "acme-platform-billing", byDir["services/billing"].Name)
+	assert.True(t, byDir["services/billing"].Launchable)
+	assert.False(t, byDir["libs/auth"].Launchable)
+
+	assert.Equal(t, "acme-platform-acme-worker", byDir["crates/worker"].Name)
+	assert.True(t, byDir["crates/worker"].Launchable)
+
+	assert.True(t, byDir["tools/proxy"].Dockerfile)
+	assert.Equal(t, []string{WorkspaceDockerfile}, byDir["tools/proxy"].Kinds)
+
+	assert.False(t, byDir["apps/web"].Dockerfile)
+
+	assert.Equal(t, "../..", byDir["tools/proxy"].BuildContext())
+	assert.Equal(t, "../..", byDir["apps/web"].BuildContext())
+}
+
+func TestDetectWorkspaceSingleApp(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"Dockerfile":        "FROM alpine",
+		"docker/Dockerfile": "FROM alpine",
+	})
+	assert.Nil(t, DetectWorkspace(root))
+}
+
+func TestSuggestAppName(t *testing.T) {
+	assert.Equal(t, "my-cool-app", SuggestAppName("/src/My_Cool.App"))
+}
@@ -3,6 +3,7 @@ package ssh
 import (
 	"context"
 	"errors"
+	"io"
 	"log"
 	"net"
 
@@ -91,3 +92,34 @@ func (c *Client) Shell(ctx context.Context, term *Terminal, cmd string) error {
 
 	return term.attach(ctx, sess, cmd)
 }
+
+// Run runs cmd without a terminal, so binary data passes through stdin and stdout unchanged
+func (c *Client) Run(ctx context.Context, cmd string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if c.client == nil {
+		if err := c.Connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	sess, err := c.client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	sess.Stdin = stdin
+	sess.Stdout = stdout
+	sess.Stderr = stderr
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			sess.Close()
+		case <-done:
+		}
+	}()
+
+	return sess.Run(cmd)
+}